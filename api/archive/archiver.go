@@ -0,0 +1,392 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"hcall/api/config"
+	"hcall/api/logger"
+	"hcall/api/models"
+	"hcall/api/repository"
+	"hcall/api/storage"
+
+	"github.com/google/uuid"
+)
+
+// batchSize is the number of tickets written to each archive file
+const batchSize = 100
+
+// Archiver exports the tickets removed by the retention worker to archive files before deleting
+// them, and brings archived tickets back
+type Archiver struct {
+	dir         string
+	ticketRepo  *repository.TicketRepository
+	userRepo    *repository.UserRepository
+	queueRepo   *repository.QueueRepository
+	tagRepo     *repository.TagRepository
+	archiveRepo *repository.ArchiveRepository
+}
+
+func NewArchiver() *Archiver {
+	return &Archiver{
+		dir:         config.AppConfig.ArchivePath,
+		ticketRepo:  repository.NewTicketRepository(),
+		userRepo:    repository.NewUserRepository(),
+		queueRepo:   repository.NewQueueRepository(),
+		tagRepo:     repository.NewTagRepository(),
+		archiveRepo: repository.NewArchiveRepository(),
+	}
+}
+
+// ArchiveTickets exports the tickets with the status created before the given date and deletes them.
+// Each batch is written to its own file and the tickets are only deleted once the file is on disk
+// and indexed. A ticket that can't be exported, like one with an image whose content can't be read,
+// is logged and kept for the next run while the others are archived. It returns how many tickets
+// were archived.
+func (a *Archiver) ArchiveTickets(status models.TicketStatus, before time.Time) (int, error) {
+	archived := 0
+	var skipped []string
+	runName := "tickets-" + time.Now().UTC().Format("20060102-150405")
+
+	for batch := 1; ; batch++ {
+		tickets, err := a.ticketRepo.GetTicketsToArchive(status, before, batchSize, skipped)
+		if err != nil {
+			return archived, err
+		}
+
+		if len(tickets) == 0 {
+			break
+		}
+
+		count, batchSkipped, err := a.archiveBatch(fmt.Sprintf("%s-%03d", runName, batch), tickets)
+		archived += count
+		skipped = append(skipped, batchSkipped...)
+		if err != nil {
+			return archived, err
+		}
+	}
+
+	if len(skipped) > 0 {
+		logger.Warning("Archiver: Some tickets could not be archived", map[string]interface{}{
+			"skipped":    len(skipped),
+			"ticket_ids": skipped,
+		})
+	}
+	return archived, nil
+}
+
+// archiveBatch writes the tickets to a new archive file, indexes them and deletes them. It returns
+// how many tickets were archived and the ids of the tickets that couldn't be exported.
+func (a *Archiver) archiveBatch(name string, tickets []models.Ticket) (int, []string, error) {
+	writer, err := NewWriter(a.dir, name)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	now := time.Now()
+	entries := make([]models.ArchivedTicket, 0, len(tickets))
+	ids := make([]string, 0, len(tickets))
+	var skipped []string
+	for i := range tickets {
+		ticket := &tickets[i]
+		record, err := a.newRecord(ticket)
+		if err != nil {
+			logger.Error("Archiver: Failed to export ticket", map[string]interface{}{
+				"ticket_id": ticket.ID,
+				"error":     err.Error(),
+			})
+			skipped = append(skipped, ticket.ID)
+			continue
+		}
+
+		line, err := writer.Write(record)
+		if err != nil {
+			writer.Abort()
+			return 0, skipped, fmt.Errorf("ticket %s: %w", ticket.ID, err)
+		}
+
+		entries = append(entries, models.ArchivedTicket{
+			TicketID:        ticket.ID,
+			Name:            ticket.Name,
+			AuthorEmail:     ticket.AuthorEmail,
+			Status:          ticket.Status,
+			Queue:           ticket.QueueName(),
+			Tags:            strings.Join(ticket.TagNames(), ","),
+			TicketCreatedAt: ticket.CreatedAt,
+			File:            writer.Name(),
+			Line:            line,
+			ArchivedAt:      now,
+		})
+		ids = append(ids, ticket.ID)
+	}
+
+	// No file is left behind for a batch where no ticket could be exported
+	if len(ids) == 0 {
+		writer.Abort()
+		return 0, skipped, nil
+	}
+
+	if err := writer.Close(); err != nil {
+		return 0, skipped, fmt.Errorf("failed to write archive %s: %w", writer.Name(), err)
+	}
+
+	if err := a.archiveRepo.SaveEntries(entries); err != nil {
+		return 0, skipped, err
+	}
+
+	storageKeys, err := a.ticketRepo.PurgeArchivedTickets(ids)
+	if err != nil {
+		return 0, skipped, err
+	}
+
+	// The images content is in the archive now
+	storage.DeleteAll(context.Background(), storageKeys)
+	return len(ids), skipped, nil
+}
+
+// newRecord converts a ticket, with its images content, to its archive record
+func (a *Archiver) newRecord(ticket *models.Ticket) (*models.ArchiveRecord, error) {
+	record := &models.ArchiveRecord{
+		ID:                 ticket.ID,
+		Name:               ticket.Name,
+		Explanation:        ticket.Explanation,
+		Status:             ticket.Status,
+		Priority:           ticket.Priority,
+		SLAState:           ticket.SLAState,
+		AuthorEmail:        ticket.AuthorEmail,
+		Queue:              ticket.QueueName(),
+		Tags:               ticket.TagNames(),
+		CustomFields:       ticket.CustomFields,
+		FirstResponseDueAt: ticket.FirstResponseDueAt,
+		ResolutionDueAt:    ticket.ResolutionDueAt,
+		FirstResponseAt:    ticket.FirstResponseAt,
+		ResolvedAt:         ticket.ResolvedAt,
+		CreatedAt:          ticket.CreatedAt,
+		UpdatedAt:          ticket.UpdatedAt,
+		Messages:           make([]models.ArchiveMessage, 0, len(ticket.History)),
+		Images:             make([]models.ArchiveImage, 0, len(ticket.Images)),
+		Revisions:          make([]models.ArchiveRevision, 0, len(ticket.Revisions)),
+	}
+
+	if ticket.Assignee != nil {
+		record.AssigneeEmail = ticket.Assignee.Email
+	}
+
+	for _, message := range ticket.History {
+		record.Messages = append(record.Messages, models.ArchiveMessage{
+			AuthorID:   message.AuthorID,
+			AuthorName: message.AuthorName,
+			AuthorRole: message.AuthorRole,
+			Visibility: message.Visibility,
+			Message:    message.Message,
+			EditedAt:   message.EditedAt,
+			CreatedAt:  message.CreatedAt,
+		})
+	}
+
+	for i := range ticket.Images {
+		image := &ticket.Images[i]
+		content, err := readImage(image)
+		if err != nil {
+			return nil, fmt.Errorf("image %s: %w", image.ID, err)
+		}
+
+		record.Images = append(record.Images, models.ArchiveImage{
+			ID:          image.ID,
+			Name:        image.Name,
+			ContentType: image.ContentType,
+			Content:     content,
+			UploadedAt:  image.UploadedAt,
+		})
+	}
+
+	for _, revision := range ticket.Revisions {
+		record.Revisions = append(record.Revisions, models.ArchiveRevision{
+			PreviousName:        revision.PreviousName,
+			Name:                revision.Name,
+			PreviousExplanation: revision.PreviousExplanation,
+			Explanation:         revision.Explanation,
+			Diff:                revision.Diff,
+			ActorID:             revision.ActorID,
+			ActorName:           revision.ActorName,
+			ActorRole:           revision.ActorRole,
+			CreatedAt:           revision.CreatedAt,
+		})
+	}
+
+	changes, err := a.ticketRepo.GetStatusChanges(ticket.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	record.StatusChanges = make([]models.ArchiveStatusChange, 0, len(changes))
+	for _, change := range changes {
+		record.StatusChanges = append(record.StatusChanges, models.ArchiveStatusChange{
+			FromStatus: change.FromStatus,
+			ToStatus:   change.ToStatus,
+			Reason:     change.Reason,
+			ActorID:    change.ActorID,
+			ActorEmail: change.ActorEmail,
+			CreatedAt:  change.CreatedAt,
+		})
+	}
+
+	return record, nil
+}
+
+// readImage reads the content of an image from the blob store, or from the database for the
+// images the migrate-images command didn't move yet
+func readImage(image *models.Image) ([]byte, error) {
+	if !image.IsStored() {
+		content := image.Base64
+		if i := strings.Index(content, ";base64,"); i >= 0 && strings.HasPrefix(content, "data:") {
+			content = content[i+len(";base64,"):]
+		}
+		return base64.StdEncoding.DecodeString(strings.TrimSpace(content))
+	}
+
+	reader, err := storage.Store.Get(context.Background(), image.StorageKey)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// GetRecord reads an archived ticket from its archive file
+func (a *Archiver) GetRecord(ticketID string) (*models.ArchiveRecord, error) {
+	entry, err := a.archiveRepo.GetEntry(ticketID)
+	if err != nil {
+		return nil, err
+	}
+
+	return ReadRecord(a.dir, entry.File, entry.Line)
+}
+
+// Import brings an archived ticket back with its conversation and images. The author must still be
+// registered, the queue, assignee and status change actors that don't exist anymore are dropped.
+func (a *Archiver) Import(ticketID string) (*models.Ticket, error) {
+	record, err := a.GetRecord(ticketID)
+	if err != nil {
+		return nil, err
+	}
+
+	author, err := a.userRepo.FindByEmail(record.AuthorEmail)
+	if err != nil {
+		return nil, errors.New("archived ticket author not found")
+	}
+
+	ticket := &models.Ticket{
+		ID:                 record.ID,
+		Name:               record.Name,
+		Explanation:        record.Explanation,
+		Status:             record.Status,
+		AuthorID:           author.ID,
+		AuthorEmail:        author.Email,
+		CustomFields:       record.CustomFields,
+		Priority:           record.Priority,
+		SLAState:           record.SLAState,
+		FirstResponseDueAt: record.FirstResponseDueAt,
+		ResolutionDueAt:    record.ResolutionDueAt,
+		FirstResponseAt:    record.FirstResponseAt,
+		ResolvedAt:         record.ResolvedAt,
+		CreatedAt:          record.CreatedAt,
+		UpdatedAt:          record.UpdatedAt,
+	}
+
+	if record.AssigneeEmail != "" {
+		if assignee, err := a.userRepo.FindByEmail(record.AssigneeEmail); err == nil && assignee.Role != models.UserRole {
+			ticket.AssigneeID = &assignee.ID
+		}
+	}
+
+	if record.Queue != "" {
+		if queue, err := a.queueRepo.GetQueueByName(record.Queue); err == nil {
+			ticket.QueueID = &queue.ID
+		}
+	}
+
+	if len(record.Tags) > 0 {
+		if ticket.Tags, err = a.tagRepo.GetOrCreateTags(record.Tags); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, message := range record.Messages {
+		ticket.History = append(ticket.History, models.TicketHistory{
+			AuthorID:   message.AuthorID,
+			AuthorName: message.AuthorName,
+			AuthorRole: message.AuthorRole,
+			Visibility: message.Visibility,
+			Message:    message.Message,
+			EditedAt:   message.EditedAt,
+			CreatedAt:  message.CreatedAt,
+		})
+	}
+
+	for _, revision := range record.Revisions {
+		ticket.Revisions = append(ticket.Revisions, models.TicketRevision{
+			PreviousName:        revision.PreviousName,
+			Name:                revision.Name,
+			PreviousExplanation: revision.PreviousExplanation,
+			Explanation:         revision.Explanation,
+			Diff:                revision.Diff,
+			ActorID:             revision.ActorID,
+			ActorName:           revision.ActorName,
+			ActorRole:           revision.ActorRole,
+			CreatedAt:           revision.CreatedAt,
+		})
+	}
+
+	changes := make([]models.TicketStatusChange, 0, len(record.StatusChanges))
+	for _, change := range record.StatusChanges {
+		changes = append(changes, models.TicketStatusChange{
+			FromStatus: change.FromStatus,
+			ToStatus:   change.ToStatus,
+			Reason:     change.Reason,
+			ActorID:    change.ActorID,
+			ActorEmail: change.ActorEmail,
+			CreatedAt:  change.CreatedAt,
+		})
+	}
+
+	// The images content goes back to the blob store first, it is dropped again if the import fails
+	ctx := context.Background()
+	var storageKeys []string
+	for _, image := range record.Images {
+		key := "tickets/" + ticket.ID + "/" + uuid.New().String()
+		size := int64(len(image.Content))
+		if err := storage.Store.Put(ctx, key, bytes.NewReader(image.Content), size, image.ContentType); err != nil {
+			storage.DeleteAll(ctx, storageKeys)
+			return nil, fmt.Errorf("failed to store image: %w", err)
+		}
+		storageKeys = append(storageKeys, key)
+
+		ticket.Images = append(ticket.Images, models.Image{
+			ID:          image.ID,
+			Name:        image.Name,
+			ContentType: image.ContentType,
+			Size:        size,
+			StorageKey:  key,
+			UploadedAt:  image.UploadedAt,
+		})
+	}
+
+	if err := a.ticketRepo.ImportTicket(ticket, changes); err != nil {
+		storage.DeleteAll(ctx, storageKeys)
+		return nil, err
+	}
+
+	if err := a.archiveRepo.MarkRestored(ticket.ID, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return ticket, nil
+}
@@ -0,0 +1,137 @@
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"hcall/api/models"
+)
+
+// fileExtension is the extension of the archive files, gzip compressed JSON lines
+const fileExtension = ".jsonl.gz"
+
+// Writer writes tickets to a new archive file. The file only shows up under its final
+// name once it is closed, so a half written archive is never indexed.
+type Writer struct {
+	name    string
+	path    string
+	file    *os.File
+	gzip    *gzip.Writer
+	encoder *json.Encoder
+	lines   int
+}
+
+// NewWriter creates the archive file named after the given name in the directory
+func NewWriter(dir, name string) (*Writer, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	path := filepath.Join(dir, name+fileExtension)
+	file, err := os.OpenFile(path+".part", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive file: %w", err)
+	}
+
+	compressed := gzip.NewWriter(file)
+	return &Writer{
+		name:    name + fileExtension,
+		path:    path,
+		file:    file,
+		gzip:    compressed,
+		encoder: json.NewEncoder(compressed),
+	}, nil
+}
+
+// Name is the name of the archive file, as saved in the archive index
+func (w *Writer) Name() string {
+	return w.name
+}
+
+// Write appends a ticket to the archive and returns its line number
+func (w *Writer) Write(record *models.ArchiveRecord) (int, error) {
+	// The encoder ends each record with a newline
+	if err := w.encoder.Encode(record); err != nil {
+		return 0, err
+	}
+	w.lines++
+	return w.lines, nil
+}
+
+// Close flushes the archive to the disk and gives it its final name
+func (w *Writer) Close() error {
+	if err := w.gzip.Close(); err != nil {
+		w.Abort()
+		return err
+	}
+
+	if err := w.file.Sync(); err != nil {
+		w.Abort()
+		return err
+	}
+
+	if err := w.file.Close(); err != nil {
+		os.Remove(w.file.Name())
+		return err
+	}
+
+	return os.Rename(w.file.Name(), w.path)
+}
+
+// Abort drops the archive file being written
+func (w *Writer) Abort() {
+	w.file.Close()
+	os.Remove(w.file.Name())
+}
+
+// ReadRecord reads the ticket at the given line of an archive file of the directory
+func ReadRecord(dir, name string, line int) (*models.ArchiveRecord, error) {
+	// Names come from the archive index, make sure they can't leave the directory
+	if name != filepath.Base(name) || !strings.HasSuffix(name, fileExtension) {
+		return nil, errors.New("invalid archive file")
+	}
+
+	file, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, errors.New("archive file not found")
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	compressed, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive file: %w", err)
+	}
+	defer compressed.Close()
+
+	// Records can hold large images, read whole lines instead of using a bounded scanner
+	reader := bufio.NewReader(compressed)
+	for current := 1; ; current++ {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && !(errors.Is(err, io.EOF) && len(data) > 0) {
+			if errors.Is(err, io.EOF) {
+				return nil, errors.New("ticket not found in archive file")
+			}
+			return nil, err
+		}
+
+		if current < line {
+			continue
+		}
+
+		var record models.ArchiveRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return nil, fmt.Errorf("invalid archive record: %w", err)
+		}
+		return &record, nil
+	}
+}
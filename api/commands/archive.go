@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"hcall/api/logger"
+	"hcall/api/services"
+	"hcall/api/utils"
+)
+
+// SearchArchive prints the archived tickets matching the filters
+func SearchArchive(args []string) error {
+	flags := flag.NewFlagSet("archive-search", flag.ContinueOnError)
+	var query utils.SearchArchiveQuery
+	flags.StringVar(&query.Query, "q", "", "part of the ticket name or ID")
+	flags.StringVar(&query.Author, "author", "", "email of the ticket author")
+	status := flags.String("status", "", "comma separated ticket statuses")
+	flags.StringVar(&query.Queue, "queue", "", "queue name")
+	flags.StringVar(&query.Tag, "tag", "", "tag name")
+	flags.StringVar(&query.ArchivedFrom, "from", "", "archived on or after this day (YYYY-MM-DD)")
+	flags.StringVar(&query.ArchivedTo, "to", "", "archived on or before this day (YYYY-MM-DD)")
+	flags.IntVar(&query.Limit, "limit", 50, "maximum number of tickets printed")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *status != "" {
+		query.Status = []string{*status}
+	}
+
+	entries, page, err := services.NewArchiveService().SearchArchive(query)
+	if err != nil {
+		return err
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(out, "TICKET\tNAME\tAUTHOR\tSTATUS\tARCHIVED\tFILE\tRESTORED")
+	for _, entry := range entries {
+		restored := "-"
+		if entry.RestoredAt != nil {
+			restored = entry.RestoredAt.Format("2006-01-02")
+		}
+		fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\t%s:%d\t%s\n", entry.TicketID, entry.Name, entry.AuthorEmail,
+			entry.Status, entry.ArchivedAt.Format("2006-01-02"), entry.File, entry.Line, restored)
+	}
+	if err := out.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("%d of %d archived tickets\n", len(entries), page.Total)
+	return nil
+}
+
+// ImportArchive brings the archived tickets given as arguments back into the tickets
+func ImportArchive(args []string) error {
+	flags := flag.NewFlagSet("archive-import", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		return errors.New("usage: archive-import <ticket_id>...")
+	}
+
+	archiveService := services.NewArchiveService()
+	for _, ticketID := range flags.Args() {
+		if _, err := archiveService.ImportTicket(ticketID); err != nil {
+			return fmt.Errorf("ticket %s: %w", ticketID, err)
+		}
+
+		logger.Info("Commands: Archived ticket imported", map[string]interface{}{
+			"ticket_id": ticketID,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Command is a maintenance task run from the command line instead of starting the server
+type Command struct {
+	Description string
+	Run         func(args []string) error
+}
+
+var registry = map[string]Command{
+	"recompute-counters": {
+		Description: "Rebuild the ticket counters from the tickets",
+		Run:         RecomputeCounters,
+	},
+	"archive-search": {
+		Description: "Search the tickets exported to the archive by the retention worker",
+		Run:         SearchArchive,
+	},
+	"archive-import": {
+		Description: "Bring archived tickets back into the tickets",
+		Run:         ImportArchive,
+	},
+	"import-tickets": {
+		Description: "Import the tickets of a CSV or JSON lines file from another helpdesk",
+		Run:         ImportTickets,
+	},
+	"migrate-images": {
+		Description: "Move images still stored as base64 in the database to the blob store",
+		Run:         MigrateImages,
+	},
+}
+
+// Run executes the command named by the first argument
+func Run(args []string) error {
+	command, ok := registry[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q, available commands: %s", args[0], available())
+	}
+	return command.Run(args[1:])
+}
+
+func available() string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := ""
+	for i, name := range names {
+		if i > 0 {
+			list += ", "
+		}
+		list += name
+	}
+	return list
+}
@@ -0,0 +1,16 @@
+package commands
+
+import (
+	"hcall/api/logger"
+	"hcall/api/services"
+)
+
+// RecomputeCounters rebuilds the ticket counters, fixing the drift of older versions
+func RecomputeCounters(args []string) error {
+	if err := services.NewTicketService().RecomputeCounters(); err != nil {
+		return err
+	}
+
+	logger.Info("Commands: Ticket counters recomputed", nil)
+	return nil
+}
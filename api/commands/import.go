@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"hcall/api/logger"
+	"hcall/api/models"
+	"hcall/api/services"
+)
+
+// ImportTickets imports the tickets of a CSV or JSON lines file exported from another helpdesk
+func ImportTickets(args []string) error {
+	flags := flag.NewFlagSet("import-tickets", flag.ContinueOnError)
+	var options services.ImportOptions
+	flags.StringVar(&options.Format, "format", "", "csv or ndjson (default: from the file extension)")
+	flags.BoolVar(&options.Placeholders, "placeholders", false, "create users for the unknown authors")
+	flags.BoolVar(&options.DryRun, "dry-run", false, "only check the file, nothing is imported")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("usage: import-tickets [-format csv|ndjson] [-placeholders] [-dry-run] <file>")
+	}
+
+	name := flags.Arg(0)
+	if options.Format == "" {
+		options.Format = services.ImportFormat(name)
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	report, err := services.NewImportService().ImportTickets(file, options)
+	if report != nil {
+		if err := printImportReport(report); err != nil {
+			return err
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Commands: Tickets imported", map[string]interface{}{
+		"file":     name,
+		"dry_run":  report.DryRun,
+		"imported": report.Imported,
+		"failed":   report.Failed,
+	})
+	return nil
+}
+
+// printImportReport prints the rows that weren't imported and the totals of an import
+func printImportReport(report *models.ImportReport) error {
+	if len(report.Errors) > 0 {
+		out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(out, "LINE\tERROR")
+		for _, rowErr := range report.Errors {
+			fmt.Fprintf(out, "%d\t%s\n", rowErr.Line, rowErr.Error)
+		}
+		if err := out.Flush(); err != nil {
+			return err
+		}
+	}
+
+	for _, email := range report.Placeholders {
+		fmt.Printf("placeholder user: %s\n", email)
+	}
+
+	verb := "imported"
+	if report.DryRun {
+		verb = "can be imported"
+	}
+	fmt.Printf("%d of %d rows %s, %d failed\n", report.Imported, report.Rows, verb, report.Failed)
+	return nil
+}
@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+
+	"hcall/api/logger"
+	"hcall/api/services"
+)
+
+// MigrateImages moves the legacy base64 images to the blob store in batches. The images that
+// can't be moved are skipped for the rest of the run and reported at the end.
+func MigrateImages(args []string) error {
+	flags := flag.NewFlagSet("migrate-images", flag.ContinueOnError)
+	batchSize := flags.Int("batch", 100, "number of images migrated per batch")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	ticketService := services.NewTicketService()
+
+	total := 0
+	var failed []string
+	for {
+		migrated, batchFailed, err := ticketService.MigrateLegacyImages(*batchSize, failed)
+		total += migrated
+		failed = append(failed, batchFailed...)
+		if err != nil {
+			return err
+		}
+
+		if migrated == 0 && len(batchFailed) == 0 {
+			break
+		}
+
+		logger.Info("Commands: Images batch migrated", map[string]interface{}{
+			"migrated": migrated,
+			"failed":   len(batchFailed),
+			"total":    total,
+		})
+	}
+
+	if len(failed) > 0 {
+		logger.Warning("Commands: Images migration finished with failures", map[string]interface{}{
+			"total":     total,
+			"failed":    len(failed),
+			"image_ids": failed,
+		})
+		return fmt.Errorf("%d images could not be migrated", len(failed))
+	}
+
+	logger.Info("Commands: Images migration finished", map[string]interface{}{
+		"total": total,
+	})
+	return nil
+}
@@ -3,12 +3,24 @@ package config
 import (
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
+// searchLanguagePattern matches the names of the PostgreSQL text search configurations
+var searchLanguagePattern = regexp.MustCompile(`^[a-z_]+$`)
+
+// SLAPolicy holds the response targets, in hours, for a ticket priority
+type SLAPolicy struct {
+	FirstResponseHours int
+	ResolutionHours    int
+}
+
 type Config struct {
 	DBHost         string
 	DBPort         string
@@ -31,10 +43,92 @@ type Config struct {
 	WorkerTicketRemoveAfter int
 	WorkerTicketStatus      string
 
+	// SLA
+	SLAPolicies       map[string]SLAPolicy
+	SLAWarningMinutes int
+	WorkerSLALooptime int
+
+	// Directory of the archive files written by the ticket retention worker
+	ArchivePath string
+
+	// Trash, deleted tickets and users are purged once they are older than the retention
+	TrashRetentionDays  int
+	WorkerTrashLooptime int
+
+	// Blob storage for ticket attachments
+	StorageDriver    string
+	StorageLocalPath string
+	S3Endpoint       string
+	S3Region         string
+	S3Bucket         string
+	S3AccessKey      string
+	S3SecretKey      string
+	S3PathStyle      bool
+
+	// Pagination of the listings
+	PageDefaultLimit int
+	PageMaxLimit     int
+
+	// Text search configuration of PostgreSQL used to index the tickets (simple, english, portuguese...)
+	SearchLanguage string
+
+	// Limits of the images attached to a ticket
+	ImageMaxFileSizeMB   int
+	ImageMaxTicketSizeMB int
+	ImageMaxPerTicket    int
+
+	// Largest ticket import file accepted by the import endpoint
+	ImportMaxFileSizeMB int
+
 	Port string
 
-	JWTSecret          string
-	JWTExpirationHours int
+	JWTSecret string
+
+	// Sessions: access tokens are short-lived, the refresh token of the session gets new ones
+	JWTAccessMinutes      int
+	RefreshTokenDays      int
+	WorkerSessionLooptime int
+
+	// Mail delivery: "smtp", or "log" to only write the messages to the logs
+	MailDriver   string
+	MailFrom     string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+
+	// Email verification and password reset, the links of the emails point to the frontend at AppURL
+	AppURL                    string
+	EmailVerificationRequired bool
+	EmailVerificationHours    int
+	PasswordResetMinutes      int
+	// Verification and reset emails sent to an address per window
+	EmailRateLimit         int
+	EmailRateWindowMinutes int
+
+	// Two-factor authentication: the roles that must use it, and the limits of the second login step
+	MFARequiredRoles    string
+	MFAIssuer           string
+	MFAChallengeMinutes int
+	MFAMaxAttempts      int
+	MFARecoveryCodes    int
+
+	// OpenID Connect single sign-on: the identity provider at OIDCIssuer logs users in with the
+	// authorization code flow and PKCE, users are provisioned on their first login
+	OIDCEnabled       bool
+	OIDCIssuer        string
+	OIDCClientID      string
+	OIDCClientSecret  string // empty for a public client, PKCE protects the flow
+	OIDCRedirectURL   string
+	OIDCScopes        string
+	OIDCGroupsClaim   string
+	OIDCGroupRoles    string // e.g. helpdesk-admins:admin,staff:user
+	OIDCDefaultRole   string // role of the users without a mapped group, "none" to refuse them
+	OIDCAutoProvision bool
+	OIDCTrustMFA      bool // the identity provider enforces a second factor, none is asked again
+	OIDCStateMinutes  int
+	// Domains whose users must log in with single sign-on, their password is refused
+	PasswordLoginDisabledDomains string
 
 	// Rate Limiting
 	RateLimitRequests int
@@ -74,10 +168,81 @@ func LoadConfig() error {
 		WorkerTicketRemoveAfter: getEnvInt("WORKER_TICKET_REMOVE_AFTER", 10),
 		WorkerTicketStatus:      getEnv("WORKER_TICKET_REMOVE_STATUS", "conclued"),
 
+		SLAPolicies: map[string]SLAPolicy{
+			"low":    getEnvSLAPolicy("LOW", 24, 120),
+			"normal": getEnvSLAPolicy("NORMAL", 8, 48),
+			"high":   getEnvSLAPolicy("HIGH", 4, 24),
+			"urgent": getEnvSLAPolicy("URGENT", 1, 8),
+		},
+		SLAWarningMinutes: getEnvInt("SLA_WARNING_MINUTES", 60),
+		WorkerSLALooptime: getEnvInt("WORKER_SLA_LOOPTIME", 5),
+
+		ArchivePath: getEnv("ARCHIVE_PATH", "./data/archive"),
+
+		TrashRetentionDays:  getEnvInt("TRASH_RETENTION_DAYS", 30),
+		WorkerTrashLooptime: getEnvInt("WORKER_TRASH_LOOPTIME", 24),
+
+		StorageDriver:    getEnv("STORAGE_DRIVER", "local"),
+		StorageLocalPath: getEnv("STORAGE_LOCAL_PATH", "./data/blobs"),
+		S3Endpoint:       getEnv("S3_ENDPOINT", ""),
+		S3Region:         getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:         getEnv("S3_BUCKET", ""),
+		S3AccessKey:      getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:      getEnv("S3_SECRET_KEY", ""),
+		S3PathStyle:      getEnvBool("S3_PATH_STYLE", true),
+
+		PageDefaultLimit: getEnvInt("PAGE_DEFAULT_LIMIT", 50),
+		PageMaxLimit:     getEnvInt("PAGE_MAX_LIMIT", 200),
+
+		SearchLanguage: getEnv("SEARCH_LANGUAGE", "simple"),
+
+		ImageMaxFileSizeMB:   getEnvInt("IMAGE_MAX_FILE_SIZE_MB", 5),
+		ImageMaxTicketSizeMB: getEnvInt("IMAGE_MAX_TICKET_SIZE_MB", 20),
+		ImageMaxPerTicket:    getEnvInt("IMAGE_MAX_PER_TICKET", 10),
+
+		ImportMaxFileSizeMB: getEnvInt("IMPORT_MAX_FILE_SIZE_MB", 50),
+
 		Port: getEnv("PORT", "8080"),
 
-		JWTSecret:          getEnv("JWT_SECRET", "default_jwt_secret_change_this_in_production"),
-		JWTExpirationHours: getEnvInt("JWT_EXPIRATION_HOURS", 24),
+		JWTSecret: getEnv("JWT_SECRET", "default_jwt_secret_change_this_in_production"),
+
+		JWTAccessMinutes:      getEnvInt("JWT_ACCESS_MINUTES", 15),
+		RefreshTokenDays:      getEnvInt("REFRESH_TOKEN_DAYS", 30),
+		WorkerSessionLooptime: getEnvInt("WORKER_SESSION_LOOPTIME", 6),
+
+		MailDriver:   getEnv("MAIL_DRIVER", "log"),
+		MailFrom:     getEnv("MAIL_FROM", "HCall <no-reply@localhost>"),
+		SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:     getEnvInt("SMTP_PORT", 1025),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+
+		AppURL:                    getEnv("APP_URL", "http://localhost:3000"),
+		EmailVerificationRequired: getEnvBool("EMAIL_VERIFICATION_REQUIRED", true),
+		EmailVerificationHours:    getEnvInt("EMAIL_VERIFICATION_HOURS", 48),
+		PasswordResetMinutes:      getEnvInt("PASSWORD_RESET_MINUTES", 60),
+		EmailRateLimit:            getEnvInt("EMAIL_RATE_LIMIT", 3),
+		EmailRateWindowMinutes:    getEnvInt("EMAIL_RATE_WINDOW_MINUTES", 60),
+
+		MFARequiredRoles:    getEnv("MFA_REQUIRED_ROLES", "admin,master"),
+		MFAIssuer:           getEnv("MFA_ISSUER", "HCall"),
+		MFAChallengeMinutes: getEnvInt("MFA_CHALLENGE_MINUTES", 5),
+		MFAMaxAttempts:      getEnvInt("MFA_MAX_ATTEMPTS", 5),
+		MFARecoveryCodes:    getEnvInt("MFA_RECOVERY_CODES", 10),
+
+		OIDCEnabled:                  getEnvBool("OIDC_ENABLED", false),
+		OIDCIssuer:                   strings.TrimSuffix(getEnv("OIDC_ISSUER", ""), "/"),
+		OIDCClientID:                 getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:             getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:              getEnv("OIDC_REDIRECT_URL", strings.TrimSuffix(getEnv("APP_URL", "http://localhost:3000"), "/")+"/auth/oidc/callback"),
+		OIDCScopes:                   getEnv("OIDC_SCOPES", "openid email profile"),
+		OIDCGroupsClaim:              getEnv("OIDC_GROUPS_CLAIM", "groups"),
+		OIDCGroupRoles:               getEnv("OIDC_GROUP_ROLES", ""),
+		OIDCDefaultRole:              getEnv("OIDC_DEFAULT_ROLE", "user"),
+		OIDCAutoProvision:            getEnvBool("OIDC_AUTO_PROVISION", true),
+		OIDCTrustMFA:                 getEnvBool("OIDC_TRUST_MFA", false),
+		OIDCStateMinutes:             getEnvInt("OIDC_STATE_MINUTES", 10),
+		PasswordLoginDisabledDomains: getEnv("PASSWORD_LOGIN_DISABLED_DOMAINS", ""),
 
 		RateLimitRequests: getEnvInt("RATE_LIMIT_REQUESTS", 100),
 		RateLimitWindow:   getEnvInt("RATE_LIMIT_WINDOW", 60),
@@ -108,6 +273,51 @@ func (c *Config) Validate() error {
 	if c.JWTSecret == "" {
 		return errors.New("JWT_SECRET is required")
 	}
+	if c.StorageDriver != "local" && c.StorageDriver != "s3" {
+		return errors.New("STORAGE_DRIVER must be local or s3")
+	}
+	if c.PageDefaultLimit <= 0 || c.PageMaxLimit < c.PageDefaultLimit {
+		return errors.New("PAGE_DEFAULT_LIMIT must be greater than zero and not above PAGE_MAX_LIMIT")
+	}
+	if !searchLanguagePattern.MatchString(c.SearchLanguage) {
+		return errors.New("SEARCH_LANGUAGE must be the name of a PostgreSQL text search configuration")
+	}
+	if c.ImageMaxFileSizeMB <= 0 || c.ImageMaxTicketSizeMB <= 0 || c.ImageMaxPerTicket <= 0 {
+		return errors.New("image limits must be greater than zero")
+	}
+	if c.JWTAccessMinutes <= 0 || c.RefreshTokenDays <= 0 || c.WorkerSessionLooptime <= 0 {
+		return errors.New("JWT_ACCESS_MINUTES, REFRESH_TOKEN_DAYS and WORKER_SESSION_LOOPTIME must be greater than zero")
+	}
+	if c.MailDriver != "smtp" && c.MailDriver != "log" {
+		return errors.New("MAIL_DRIVER must be smtp or log")
+	}
+	if c.EmailVerificationHours <= 0 || c.PasswordResetMinutes <= 0 || c.EmailRateLimit <= 0 || c.EmailRateWindowMinutes <= 0 {
+		return errors.New("EMAIL_VERIFICATION_HOURS, PASSWORD_RESET_MINUTES, EMAIL_RATE_LIMIT and EMAIL_RATE_WINDOW_MINUTES must be greater than zero")
+	}
+	if c.MFAChallengeMinutes <= 0 || c.MFAMaxAttempts <= 0 || c.MFARecoveryCodes <= 0 {
+		return errors.New("MFA_CHALLENGE_MINUTES, MFA_MAX_ATTEMPTS and MFA_RECOVERY_CODES must be greater than zero")
+	}
+	for _, role := range strings.Split(c.MFARequiredRoles, ",") {
+		switch strings.TrimSpace(role) {
+		case "", "user", "admin", "master":
+		default:
+			return fmt.Errorf("MFA_REQUIRED_ROLES has an unknown role %q", role)
+		}
+	}
+	if err := c.validateOIDC(); err != nil {
+		return err
+	}
+	if c.ImportMaxFileSizeMB <= 0 {
+		return errors.New("IMPORT_MAX_FILE_SIZE_MB must be greater than zero")
+	}
+	if c.TrashRetentionDays <= 0 || c.WorkerTrashLooptime <= 0 {
+		return errors.New("TRASH_RETENTION_DAYS and WORKER_TRASH_LOOPTIME must be greater than zero")
+	}
+	for priority, policy := range c.SLAPolicies {
+		if policy.FirstResponseHours <= 0 || policy.ResolutionHours <= 0 {
+			return fmt.Errorf("SLA targets for %s priority must be greater than zero", priority)
+		}
+	}
 	return nil
 }
 
@@ -142,3 +352,116 @@ func getEnvBool(key string, defaultValue bool) bool {
 	}
 	return boolValue
 }
+
+// getEnvSLAPolicy reads the SLA_<PRIORITY>_FIRST_RESPONSE_HOURS and SLA_<PRIORITY>_RESOLUTION_HOURS variables
+func getEnvSLAPolicy(priority string, firstResponseHours, resolutionHours int) SLAPolicy {
+	return SLAPolicy{
+		FirstResponseHours: getEnvInt("SLA_"+priority+"_FIRST_RESPONSE_HOURS", firstResponseHours),
+		ResolutionHours:    getEnvInt("SLA_"+priority+"_RESOLUTION_HOURS", resolutionHours),
+	}
+}
+
+// ImageMaxFileSize is the largest image accepted, in bytes
+func (c *Config) ImageMaxFileSize() int64 {
+	return int64(c.ImageMaxFileSizeMB) << 20
+}
+
+// ImageMaxTicketSize is the largest total size of the images of a ticket, in bytes
+func (c *Config) ImageMaxTicketSize() int64 {
+	return int64(c.ImageMaxTicketSizeMB) << 20
+}
+
+// ImportMaxFileSize is the largest ticket import file accepted, in bytes
+func (c *Config) ImportMaxFileSize() int64 {
+	return int64(c.ImportMaxFileSizeMB) << 20
+}
+
+// MFARequired tells whether the users of the role must use two-factor authentication
+func (c *Config) MFARequired(role string) bool {
+	for _, required := range strings.Split(c.MFARequiredRoles, ",") {
+		if strings.TrimSpace(required) == role {
+			return true
+		}
+	}
+	return false
+}
+
+// validateOIDC checks the single sign-on settings, only when it is enabled
+func (c *Config) validateOIDC() error {
+	if !c.OIDCEnabled {
+		if strings.TrimSpace(c.PasswordLoginDisabledDomains) != "" {
+			return errors.New("PASSWORD_LOGIN_DISABLED_DOMAINS requires OIDC_ENABLED")
+		}
+		return nil
+	}
+
+	issuer, err := url.Parse(c.OIDCIssuer)
+	if err != nil || (issuer.Scheme != "https" && issuer.Scheme != "http") || issuer.Host == "" {
+		return errors.New("OIDC_ISSUER must be the URL of the identity provider")
+	}
+	if c.OIDCClientID == "" {
+		return errors.New("OIDC_CLIENT_ID is required")
+	}
+	if _, err := url.ParseRequestURI(c.OIDCRedirectURL); err != nil {
+		return errors.New("OIDC_REDIRECT_URL must be a URL")
+	}
+	if !strings.Contains(" "+c.OIDCScopes+" ", " openid ") {
+		return errors.New("OIDC_SCOPES must include openid")
+	}
+	if c.OIDCStateMinutes <= 0 {
+		return errors.New("OIDC_STATE_MINUTES must be greater than zero")
+	}
+
+	// Single sign-on never gives the master role
+	switch c.OIDCDefaultRole {
+	case "none", "user", "admin":
+	default:
+		return fmt.Errorf("OIDC_DEFAULT_ROLE has an unsupported role %q", c.OIDCDefaultRole)
+	}
+	for _, mapping := range strings.Split(c.OIDCGroupRoles, ",") {
+		if strings.TrimSpace(mapping) == "" {
+			continue
+		}
+		group, role, ok := strings.Cut(mapping, ":")
+		role = strings.TrimSpace(role)
+		if !ok || strings.TrimSpace(group) == "" || (role != "user" && role != "admin") {
+			return fmt.Errorf("OIDC_GROUP_ROLES has an invalid mapping %q, use group:user or group:admin", mapping)
+		}
+	}
+	return nil
+}
+
+// OIDCRole gives the role of a single sign-on user from its groups, the highest mapped role wins.
+// It is empty when no group is mapped and the default role is none.
+func (c *Config) OIDCRole(groups []string) string {
+	role := c.OIDCDefaultRole
+	if role == "none" {
+		role = ""
+	}
+	for _, mapping := range strings.Split(c.OIDCGroupRoles, ",") {
+		group, mapped, _ := strings.Cut(mapping, ":")
+		group, mapped = strings.TrimSpace(group), strings.TrimSpace(mapped)
+		for _, g := range groups {
+			if g == group && (role == "" || mapped == "admin") {
+				role = mapped
+			}
+		}
+	}
+	return role
+}
+
+// PasswordLoginDisabled tells whether the users with the email must log in with single sign-on
+func (c *Config) PasswordLoginDisabled(email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+
+	domain := strings.ToLower(email[at+1:])
+	for _, disabled := range strings.Split(c.PasswordLoginDisabledDomains, ",") {
+		if strings.ToLower(strings.TrimSpace(disabled)) == domain {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOIDCRole(t *testing.T) {
+	cfg := &Config{
+		OIDCGroupRoles:  "staff:user, helpdesk-admins:admin",
+		OIDCDefaultRole: "none",
+	}
+
+	tests := []struct {
+		groups []string
+		role   string
+	}{
+		{groups: []string{"staff"}, role: "user"},
+		{groups: []string{"helpdesk-admins"}, role: "admin"},
+		// The admin role wins over the user role, whatever the order of the groups
+		{groups: []string{"staff", "helpdesk-admins"}, role: "admin"},
+		{groups: []string{"helpdesk-admins", "staff"}, role: "admin"},
+		{groups: []string{"sales"}, role: ""},
+		{groups: nil, role: ""},
+		// Group names are matched exactly
+		{groups: []string{"Staff", "helpdesk-admins-old"}, role: ""},
+	}
+	for _, test := range tests {
+		if role := cfg.OIDCRole(test.groups); role != test.role {
+			t.Errorf("OIDCRole(%q) is %q, want %q", test.groups, role, test.role)
+		}
+	}
+
+	// Users in no mapped group get the default role, a mapped group can still raise it
+	cfg.OIDCDefaultRole = "user"
+	if role := cfg.OIDCRole([]string{"sales"}); role != "user" {
+		t.Errorf("OIDCRole without a mapped group is %q, want the default user", role)
+	}
+	if role := cfg.OIDCRole([]string{"sales", "helpdesk-admins"}); role != "admin" {
+		t.Errorf("OIDCRole with an admin group is %q, want admin", role)
+	}
+}
+
+func TestValidateOIDC(t *testing.T) {
+	valid := func() Config {
+		return Config{
+			OIDCEnabled:      true,
+			OIDCIssuer:       "https://login.example.com/realms/hcall",
+			OIDCClientID:     "hcall",
+			OIDCRedirectURL:  "https://hcall.example.com/sso/callback",
+			OIDCScopes:       "openid email profile",
+			OIDCStateMinutes: 10,
+			OIDCDefaultRole:  "user",
+			OIDCGroupRoles:   "staff:user,helpdesk-admins:admin",
+		}
+	}
+
+	cfg := valid()
+	if err := cfg.validateOIDC(); err != nil {
+		t.Fatalf("valid configuration refused: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		edit func(cfg *Config)
+		err  string
+	}{
+		{"issuer without scheme", func(cfg *Config) { cfg.OIDCIssuer = "login.example.com" }, "OIDC_ISSUER"},
+		{"no client id", func(cfg *Config) { cfg.OIDCClientID = "" }, "OIDC_CLIENT_ID"},
+		{"no openid scope", func(cfg *Config) { cfg.OIDCScopes = "email profile" }, "OIDC_SCOPES"},
+		{"master default role", func(cfg *Config) { cfg.OIDCDefaultRole = "master" }, "OIDC_DEFAULT_ROLE"},
+		{"master group role", func(cfg *Config) { cfg.OIDCGroupRoles = "root:master" }, "OIDC_GROUP_ROLES"},
+		{"mapping without role", func(cfg *Config) { cfg.OIDCGroupRoles = "staff" }, "OIDC_GROUP_ROLES"},
+		{"password login disabled without oidc", func(cfg *Config) {
+			cfg.OIDCEnabled = false
+			cfg.PasswordLoginDisabledDomains = "example.com"
+		}, "PASSWORD_LOGIN_DISABLED_DOMAINS"},
+	}
+	for _, test := range tests {
+		cfg := valid()
+		test.edit(&cfg)
+		if err := cfg.validateOIDC(); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: validateOIDC returned %v, want an error about %s", test.name, err, test.err)
+		}
+	}
+}
+
+func TestPasswordLoginDisabled(t *testing.T) {
+	cfg := &Config{PasswordLoginDisabledDomains: "example.com, Corp.Example.org"}
+
+	tests := map[string]bool{
+		"janesmith@example.com":       true,
+		"janesmith@EXAMPLE.com":       true,
+		"johndoe@corp.example.org":    true,
+		"johndoe@sub.example.com":     false,
+		"johndoe@example.com.evil.io": false,
+		"not-an-email":                false,
+	}
+	for email, disabled := range tests {
+		if got := cfg.PasswordLoginDisabled(email); got != disabled {
+			t.Errorf("PasswordLoginDisabled(%q) is %v, want %v", email, got, disabled)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"hcall/api/dictionaries"
+	"hcall/api/logger"
+	"hcall/api/services"
+	"hcall/api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ArchiveController struct {
+	archiveService *services.ArchiveService
+}
+
+func NewArchiveController() *ArchiveController {
+	return &ArchiveController{
+		archiveService: services.NewArchiveService(),
+	}
+}
+
+// SearchArchive searches the index of the archived tickets
+func (c *ArchiveController) SearchArchive(ctx *gin.Context) {
+	var query utils.SearchArchiveQuery
+
+	// Bind query parameters to struct
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	entries, page, err := c.archiveService.SearchArchive(query)
+	if err != nil {
+		if message := listingErrorMessage(err); message != "" {
+			utils.SendError(ctx, utils.CodeInvalidInput, message, err)
+			return
+		}
+
+		logger.Error("Archive Controller: Failed to search archive", map[string]interface{}{
+			"query": query.Query,
+			"error": err.Error(),
+		})
+		utils.SendError(ctx, utils.CodeInternalError, utils.MsgInternalError, err)
+		return
+	}
+
+	utils.SendSuccess(ctx, dictionaries.ArchiveSearchSuccess, gin.H{
+		"tickets":    entries,
+		"pagination": page,
+	})
+}
+
+// GetArchivedTicket returns an archived ticket as written in its archive file
+func (c *ArchiveController) GetArchivedTicket(ctx *gin.Context) {
+	ticketID := ctx.Query("ticket_id")
+	if ticketID == "" {
+		utils.SendError(ctx, utils.CodeInvalidInput, "Ticket ID is required", nil)
+		return
+	}
+
+	record, err := c.archiveService.GetArchivedTicket(ticketID)
+	if err != nil {
+		logger.Error("Archive Controller: Failed to read archived ticket", map[string]interface{}{
+			"ticket_id": ticketID,
+			"error":     err.Error(),
+		})
+		sendArchiveError(ctx, err, utils.MsgInternalError)
+		return
+	}
+
+	utils.SendSuccess(ctx, dictionaries.ArchivedTicketFound, gin.H{
+		"ticket": record,
+	})
+}
+
+// ImportArchivedTicket brings an archived ticket back into the tickets
+func (c *ArchiveController) ImportArchivedTicket(ctx *gin.Context) {
+	var request utils.ImportArchivedTicketRequest
+
+	// Bind request body to struct
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	ticket, err := c.archiveService.ImportTicket(request.TicketID)
+	if err != nil {
+		logger.Error("Archive Controller: Failed to import archived ticket", map[string]interface{}{
+			"ticket_id": request.TicketID,
+			"error":     err.Error(),
+		})
+		sendArchiveError(ctx, err, dictionaries.ArchiveImportFailed)
+		return
+	}
+
+	logger.Info("Archive Controller: Archived ticket imported successfully", map[string]interface{}{
+		"ticket_id": ticket.ID,
+	})
+
+	utils.SendSuccess(ctx, dictionaries.ArchivedTicketImported, gin.H{
+		"ticket_id": ticket.ID,
+	})
+}
+
+// sendArchiveError answers a failed archive operation
+func sendArchiveError(ctx *gin.Context, err error, fallback string) {
+	switch err.Error() {
+	case "ticket not found in archive", "ticket not found in archive file":
+		utils.SendError(ctx, utils.CodeNotFound, dictionaries.ArchivedTicketNotFound, err)
+	case "archive file not found", "invalid archive file":
+		utils.SendError(ctx, utils.CodeInternalError, dictionaries.ArchiveFileUnavailable, err)
+	case "archived ticket author not found":
+		utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.ArchiveAuthorNotFound, err)
+	case "ticket already exists":
+		utils.SendError(ctx, utils.CodeDuplicateEntry, dictionaries.TicketAlreadyExists, err)
+	default:
+		utils.SendError(ctx, utils.CodeInternalError, fallback, err)
+	}
+}
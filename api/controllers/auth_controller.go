@@ -1,7 +1,9 @@
 package controllers
 
 import (
+	"hcall/api/dictionaries"
 	"hcall/api/logger"
+	"hcall/api/models"
 	"hcall/api/services"
 	"hcall/api/utils"
 
@@ -18,6 +20,14 @@ func NewAuthController() *AuthController {
 	}
 }
 
+// sessionClient describes the device a request opens a session from
+func sessionClient(ctx *gin.Context) services.Client {
+	return services.Client{
+		UserAgent: ctx.GetHeader("User-Agent"),
+		IP:        ctx.ClientIP(),
+	}
+}
+
 // Register handles user registration
 func (c *AuthController) Register(ctx *gin.Context) {
 	var request utils.RegisterRequest
@@ -29,12 +39,16 @@ func (c *AuthController) Register(ctx *gin.Context) {
 	}
 
 	// Call the service
-	user, token, err := c.authService.Register(request.Username, request.Email, request.Password)
+	user, tokens, err := c.authService.Register(request.Username, request.Email, request.Password, sessionClient(ctx))
 	if err != nil {
 		logger.Error("Auth Controller: Registration failed", map[string]interface{}{
 			"email": request.Email,
 			"error": err.Error(),
 		})
+		if err.Error() == "password login disabled" {
+			utils.SendError(ctx, utils.CodeForbidden, dictionaries.PasswordLoginDisabled, err)
+			return
+		}
 		utils.SendError(ctx, utils.CodeInvalidInput, "Registration failed", err)
 		return
 	}
@@ -44,9 +58,22 @@ func (c *AuthController) Register(ctx *gin.Context) {
 		"role":  user.Role,
 	})
 
+	// No session until the email is verified
+	if tokens == nil {
+		utils.SendSuccess(ctx, dictionaries.VerificationEmailRequired, gin.H{
+			"user": gin.H{
+				"email": user.Email,
+				"role":  user.Role,
+			},
+		})
+		return
+	}
+
 	// Return success
 	utils.SendSuccess(ctx, "Registration successful", gin.H{
-		"token": token,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_at":    tokens.ExpiresAt,
 		"user": gin.H{
 			"email": user.Email,
 			"role":  user.Role,
@@ -65,13 +92,37 @@ func (c *AuthController) Login(ctx *gin.Context) {
 	}
 
 	// Call the service
-	user, token, err := c.authService.Login(request.Email, request.Password)
+	user, tokens, challenge, err := c.authService.Login(request.Email, request.Password, sessionClient(ctx))
 	if err != nil {
 		logger.Error("Auth Controller: Login failed", map[string]interface{}{
 			"email": request.Email,
 			"error": err.Error(),
 		})
-		utils.SendError(ctx, utils.CodeUnauthorized, utils.MsgInvalidCredentials, err)
+		switch err.Error() {
+		case "email not verified":
+			utils.SendError(ctx, utils.CodeForbidden, dictionaries.EmailNotVerified, err)
+		case "password login disabled":
+			utils.SendError(ctx, utils.CodeForbidden, dictionaries.PasswordLoginDisabled, err)
+		case "too many mfa attempts":
+			utils.SendError(ctx, utils.CodeTooManyRequests, dictionaries.TooManyMFAAttempts, err)
+		default:
+			utils.SendError(ctx, utils.CodeUnauthorized, utils.MsgInvalidCredentials, err)
+		}
+		return
+	}
+
+	sendLogin(ctx, user, tokens, challenge)
+}
+
+// sendLogin answers a login with the tokens of the session, or with the challenge of the second
+// factor asked before the tokens are given
+func sendLogin(ctx *gin.Context, user *models.User, tokens *models.TokenPair, challenge *models.MFAChallengeResponse) {
+	if challenge != nil {
+		message := dictionaries.MFACodeRequired
+		if challenge.EnrollmentRequired {
+			message = dictionaries.MFAEnrollmentRequired
+		}
+		utils.SendSuccess(ctx, message, challenge)
 		return
 	}
 
@@ -82,7 +133,9 @@ func (c *AuthController) Login(ctx *gin.Context) {
 
 	// Return success
 	utils.SendSuccess(ctx, "Login successful", gin.H{
-		"token": token,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_at":    tokens.ExpiresAt,
 		"user": gin.H{
 			"email": user.Email,
 			"role":  user.Role,
@@ -101,7 +154,7 @@ func (c *AuthController) CreateMaster(ctx *gin.Context) {
 	}
 
 	// Call the service
-	master, token, err := c.authService.CreateMaster(request.Email, request.Password)
+	master, tokens, err := c.authService.CreateMaster(request.Email, request.Password, sessionClient(ctx))
 	if err != nil {
 		logger.Error("Auth Controller: Master user creation failed", map[string]interface{}{
 			"email": request.Email,
@@ -118,7 +171,9 @@ func (c *AuthController) CreateMaster(ctx *gin.Context) {
 
 	// Return success
 	utils.SendSuccess(ctx, "Master user created successfully", gin.H{
-		"token": token,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_at":    tokens.ExpiresAt,
 		"user": gin.H{
 			"email": master.Email,
 			"role":  master.Role,
@@ -158,3 +213,196 @@ func (c *AuthController) DeleteMaster(ctx *gin.Context) {
 	// Return success
 	utils.SendSuccess(ctx, "Master user deleted successfully", nil)
 }
+
+// Refresh gives new tokens to the session of a refresh token
+func (c *AuthController) Refresh(ctx *gin.Context) {
+	var request utils.RefreshTokenRequest
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	tokens, err := c.authService.Refresh(request.RefreshToken)
+	if err != nil {
+		switch err.Error() {
+		case "invalid refresh token":
+			utils.SendError(ctx, utils.CodeUnauthorized, dictionaries.InvalidRefreshToken, err)
+		case "refresh token reused":
+			logger.Warning("Auth Controller: Refresh token reused, session revoked", map[string]interface{}{
+				"ip": ctx.ClientIP(),
+			})
+			utils.SendError(ctx, utils.CodeUnauthorized, dictionaries.RefreshTokenReused, err)
+		default:
+			utils.SendError(ctx, utils.CodeInternalError, utils.MsgInternalError, err)
+		}
+		return
+	}
+
+	utils.SendSuccess(ctx, dictionaries.TokenRefreshedSuccess, tokens)
+}
+
+// Logout revokes the session of the request
+func (c *AuthController) Logout(ctx *gin.Context) {
+	userID, _ := ctx.Get("userId")
+	sessionID, _ := ctx.Get("sessionId")
+
+	if err := c.authService.Logout(sessionID.(string), userID.(uint)); err != nil {
+		if err.Error() == "session not found" {
+			utils.SendError(ctx, utils.CodeNotFound, dictionaries.SessionNotFound, err)
+			return
+		}
+		utils.SendError(ctx, utils.CodeInternalError, utils.MsgInternalError, err)
+		return
+	}
+
+	utils.SendSuccess(ctx, dictionaries.LoggedOutSuccess, nil)
+}
+
+// GetSessions lists the active sessions of the user of the request
+func (c *AuthController) GetSessions(ctx *gin.Context) {
+	userID, _ := ctx.Get("userId")
+	sessionID, _ := ctx.Get("sessionId")
+
+	sessions, err := c.authService.GetSessions(userID.(uint), sessionID.(string))
+	if err != nil {
+		utils.SendError(ctx, utils.CodeInternalError, utils.MsgInternalError, err)
+		return
+	}
+
+	utils.SendSuccess(ctx, dictionaries.SessionsRetrieved, sessions)
+}
+
+// RevokeSessions revokes every session of the user of the request, the current one included
+func (c *AuthController) RevokeSessions(ctx *gin.Context) {
+	userID, _ := ctx.Get("userId")
+
+	revoked, err := c.authService.RevokeSessions(userID.(uint))
+	if err != nil {
+		utils.SendError(ctx, utils.CodeInternalError, utils.MsgInternalError, err)
+		return
+	}
+
+	utils.SendSuccess(ctx, dictionaries.SessionsRevokedSuccess, gin.H{"revoked": revoked})
+}
+
+// RevokeUserSessions revokes every session of another user
+func (c *AuthController) RevokeUserSessions(ctx *gin.Context) {
+	var request utils.RevokeUserSessionsRequest
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	userRole, _ := ctx.Get("userRole")
+	revoked, err := c.authService.RevokeUserSessions(request.Email, userRole.(models.Role))
+	if err != nil {
+		switch err.Error() {
+		case "user not found":
+			utils.SendError(ctx, utils.CodeNotFound, dictionaries.UserNotFound, err)
+		case "only a master can revoke the sessions of a master":
+			utils.SendError(ctx, utils.CodeForbidden, dictionaries.CannotRevokeMasterSessions, err)
+		default:
+			utils.SendError(ctx, utils.CodeInternalError, utils.MsgInternalError, err)
+		}
+		return
+	}
+
+	logger.Info("Auth Controller: User sessions revoked", map[string]interface{}{
+		"email":   request.Email,
+		"revoked": revoked,
+	})
+
+	utils.SendSuccess(ctx, dictionaries.SessionsRevokedSuccess, gin.H{"revoked": revoked})
+}
+
+// emailRequestError answers a failed request to send a verification or reset email
+func emailRequestError(ctx *gin.Context, err error) {
+	if err.Error() == "too many email requests" {
+		utils.SendError(ctx, utils.CodeTooManyRequests, dictionaries.TooManyEmailRequests, err)
+		return
+	}
+	utils.SendError(ctx, utils.CodeInternalError, utils.MsgInternalError, err)
+}
+
+// RequestEmailVerification sends a new verification email, the answer is the same whether the
+// email is registered or not
+func (c *AuthController) RequestEmailVerification(ctx *gin.Context) {
+	var request utils.EmailRequest
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	if err := c.authService.RequestEmailVerification(request.Email); err != nil {
+		emailRequestError(ctx, err)
+		return
+	}
+
+	utils.SendSuccess(ctx, dictionaries.VerificationEmailSent, nil)
+}
+
+// VerifyEmail verifies an email with the token of a verification email
+func (c *AuthController) VerifyEmail(ctx *gin.Context) {
+	var request utils.EmailTokenRequest
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	if err := c.authService.VerifyEmail(request.Token); err != nil {
+		if err.Error() == "invalid email token" {
+			utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.InvalidEmailToken, err)
+			return
+		}
+		utils.SendError(ctx, utils.CodeInternalError, utils.MsgInternalError, err)
+		return
+	}
+
+	utils.SendSuccess(ctx, dictionaries.EmailVerifiedSuccess, nil)
+}
+
+// RequestPasswordReset sends a password reset email, the answer is the same whether the email is
+// registered or not
+func (c *AuthController) RequestPasswordReset(ctx *gin.Context) {
+	var request utils.EmailRequest
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	if err := c.authService.RequestPasswordReset(request.Email); err != nil {
+		emailRequestError(ctx, err)
+		return
+	}
+
+	utils.SendSuccess(ctx, dictionaries.PasswordResetEmailSent, nil)
+}
+
+// ResetPassword sets a new password with the token of a password reset email
+func (c *AuthController) ResetPassword(ctx *gin.Context) {
+	var request utils.ResetPasswordRequest
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	if err := c.authService.ResetPassword(request.Token, request.Password); err != nil {
+		logger.Error("Auth Controller: Password reset failed", map[string]interface{}{
+			"error": err.Error(),
+		})
+		if err.Error() == "invalid email token" {
+			utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.InvalidEmailToken, err)
+			return
+		}
+		utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.PasswordResetFailed, err)
+		return
+	}
+
+	utils.SendSuccess(ctx, dictionaries.PasswordResetSuccess, nil)
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"hcall/api/config"
+	"hcall/api/dictionaries"
+	"hcall/api/logger"
+	"hcall/api/services"
+	"hcall/api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ImportController struct {
+	importService *services.ImportService
+}
+
+func NewImportController() *ImportController {
+	return &ImportController{
+		importService: services.NewImportService(),
+	}
+}
+
+// ImportTickets imports the tickets of a CSV or JSON lines file sent in the file field of a multipart form
+func (c *ImportController) ImportTickets(ctx *gin.Context) {
+	// Leave some room for the form fields and the multipart boundaries
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, config.AppConfig.ImportMaxFileSize()+1<<20)
+
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.ImportFileTooLarge, err)
+			return
+		}
+		utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.ImportFileRequired, err)
+		return
+	}
+
+	var request utils.ImportTicketsRequest
+	if err := ctx.ShouldBind(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	options := services.ImportOptions{
+		Format:       request.Format,
+		Placeholders: request.Placeholders,
+		DryRun:       request.DryRun,
+	}
+	if options.Format == "" {
+		options.Format = services.ImportFormat(header.Filename)
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		utils.SendError(ctx, utils.CodeInternalError, dictionaries.TicketImportFailed, err)
+		return
+	}
+	defer file.Close()
+
+	userID, _ := ctx.Get("userId")
+
+	report, err := c.importService.ImportTickets(file, options)
+	if err != nil {
+		logger.Error("Import Controller: Failed to import tickets", map[string]interface{}{
+			"file":    header.Filename,
+			"user_id": userID,
+			"error":   err.Error(),
+		})
+
+		switch err.Error() {
+		case "invalid import format", "invalid import header", "invalid import file":
+			// The rows before a broken part of the file are imported, the report tells which
+			utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.InvalidImportFile, gin.H{
+				"error":  err.Error(),
+				"report": report,
+			})
+		default:
+			utils.SendError(ctx, utils.CodeInternalError, dictionaries.TicketImportFailed, gin.H{
+				"error":  err.Error(),
+				"report": report,
+			})
+		}
+		return
+	}
+
+	logger.Info("Import Controller: Tickets imported", map[string]interface{}{
+		"file":     header.Filename,
+		"user_id":  userID,
+		"dry_run":  report.DryRun,
+		"imported": report.Imported,
+		"failed":   report.Failed,
+	})
+
+	message := dictionaries.TicketsImported
+	if report.DryRun {
+		message = dictionaries.ImportDryRunPassed
+	}
+	utils.SendSuccess(ctx, message, gin.H{
+		"report": report,
+	})
+}
@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"hcall/api/dictionaries"
+	"hcall/api/logger"
+	"hcall/api/models"
+	"hcall/api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mfaError answers a failed two-factor authentication request
+func mfaError(ctx *gin.Context, err error) {
+	switch err.Error() {
+	case "invalid mfa token":
+		utils.SendError(ctx, utils.CodeUnauthorized, dictionaries.InvalidMFAToken, err)
+	case "invalid mfa code":
+		utils.SendError(ctx, utils.CodeUnauthorized, dictionaries.InvalidMFACode, err)
+	case "password is incorrect":
+		utils.SendError(ctx, utils.CodeUnauthorized, utils.MsgInvalidCredentials, err)
+	case "mfa enrollment required":
+		utils.SendError(ctx, utils.CodeForbidden, dictionaries.MFAEnrollmentRequired, err)
+	case "mfa already enabled":
+		utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.MFAAlreadyEnabled, err)
+	case "mfa not set up":
+		utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.MFANotSetUp, err)
+	case "mfa not enabled", "mfa not found":
+		utils.SendError(ctx, utils.CodeNotFound, dictionaries.MFANotEnabled, err)
+	case "mfa required for role":
+		utils.SendError(ctx, utils.CodeForbidden, dictionaries.MFARequiredForRole, err)
+	case "user not found":
+		utils.SendError(ctx, utils.CodeNotFound, dictionaries.UserNotFound, err)
+	case "only a master can reset the mfa of a master":
+		utils.SendError(ctx, utils.CodeForbidden, dictionaries.CannotResetMasterMFA, err)
+	default:
+		utils.SendError(ctx, utils.CodeInternalError, utils.MsgInternalError, err)
+	}
+}
+
+// EnrollMFA sets up a second factor during a login that requires one
+func (c *AuthController) EnrollMFA(ctx *gin.Context) {
+	var request utils.MFAEnrollRequest
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	setup, err := c.authService.EnrollMFAChallenge(request.MFAToken)
+	if err != nil {
+		mfaError(ctx, err)
+		return
+	}
+
+	utils.SendSuccess(ctx, dictionaries.MFASetupCreated, setup)
+}
+
+// VerifyMFA answers the login challenge with a code of the second factor and gives the tokens
+func (c *AuthController) VerifyMFA(ctx *gin.Context) {
+	var request utils.MFAVerifyRequest
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	user, tokens, recoveryCodes, err := c.authService.VerifyMFAChallenge(request.MFAToken, request.Code, sessionClient(ctx))
+	if err != nil {
+		logger.Warning("Auth Controller: Second factor refused", map[string]interface{}{
+			"ip":    ctx.ClientIP(),
+			"error": err.Error(),
+		})
+		mfaError(ctx, err)
+		return
+	}
+
+	logger.Info("Auth Controller: User logged in successfully", map[string]interface{}{
+		"email": user.Email,
+		"role":  user.Role,
+	})
+
+	response := gin.H{
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_at":    tokens.ExpiresAt,
+		"user": gin.H{
+			"email": user.Email,
+			"role":  user.Role,
+		},
+	}
+	// Shown only once, when the login enrolled the second factor
+	if recoveryCodes != nil {
+		response["recovery_codes"] = recoveryCodes
+	}
+
+	utils.SendSuccess(ctx, "Login successful", response)
+}
+
+// GetMFAStatus tells whether the user of the request has a second factor
+func (c *AuthController) GetMFAStatus(ctx *gin.Context) {
+	userID, _ := ctx.Get("userId")
+	userRole, _ := ctx.Get("userRole")
+
+	status, err := c.authService.GetMFAStatus(userID.(uint), userRole.(models.Role))
+	if err != nil {
+		mfaError(ctx, err)
+		return
+	}
+
+	utils.SendSuccess(ctx, dictionaries.MFAStatusRetrieved, status)
+}
+
+// SetupMFA gives the user of the request a new second factor to set up in an authenticator app
+func (c *AuthController) SetupMFA(ctx *gin.Context) {
+	userID, _ := ctx.Get("userId")
+
+	setup, err := c.authService.SetupMFA(userID.(uint))
+	if err != nil {
+		mfaError(ctx, err)
+		return
+	}
+
+	utils.SendSuccess(ctx, dictionaries.MFASetupCreated, setup)
+}
+
+// ActivateMFA enables the second factor set up by the user of the request
+func (c *AuthController) ActivateMFA(ctx *gin.Context) {
+	var request utils.MFACodeRequest
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	userID, _ := ctx.Get("userId")
+	recoveryCodes, err := c.authService.ActivateMFA(userID.(uint), request.Code)
+	if err != nil {
+		mfaError(ctx, err)
+		return
+	}
+
+	utils.SendSuccess(ctx, dictionaries.MFAEnabledSuccess, gin.H{"recovery_codes": recoveryCodes})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user of the request
+func (c *AuthController) RegenerateRecoveryCodes(ctx *gin.Context) {
+	var request utils.MFACodeRequest
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	userID, _ := ctx.Get("userId")
+	recoveryCodes, err := c.authService.RegenerateRecoveryCodes(userID.(uint), request.Code)
+	if err != nil {
+		mfaError(ctx, err)
+		return
+	}
+
+	utils.SendSuccess(ctx, dictionaries.RecoveryCodesRegenerated, gin.H{"recovery_codes": recoveryCodes})
+}
+
+// DisableMFA removes the second factor of the user of the request
+func (c *AuthController) DisableMFA(ctx *gin.Context) {
+	var request utils.MFADisableRequest
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	userID, _ := ctx.Get("userId")
+	if err := c.authService.DisableMFA(userID.(uint), request.Password, request.Code); err != nil {
+		mfaError(ctx, err)
+		return
+	}
+
+	utils.SendSuccess(ctx, dictionaries.MFADisabledSuccess, nil)
+}
+
+// ResetUserMFA removes the second factor of another user, who sets up a new one at the next login
+// when its role requires it
+func (c *AuthController) ResetUserMFA(ctx *gin.Context) {
+	var request utils.ResetUserMFARequest
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	userRole, _ := ctx.Get("userRole")
+	if err := c.authService.ResetUserMFA(request.Email, userRole.(models.Role)); err != nil {
+		mfaError(ctx, err)
+		return
+	}
+
+	userEmail, _ := ctx.Get("userEmail")
+	logger.Info("Auth Controller: User second factor reset", map[string]interface{}{
+		"email":    request.Email,
+		"reset_by": userEmail,
+	})
+
+	utils.SendSuccess(ctx, dictionaries.MFAResetSuccess, nil)
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"hcall/api/dictionaries"
+	"hcall/api/logger"
+	"hcall/api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oidcError answers a failed single sign-on request
+func oidcError(ctx *gin.Context, err error) {
+	switch err.Error() {
+	case "oidc not enabled":
+		utils.SendError(ctx, utils.CodeNotFound, dictionaries.OIDCNotEnabled, err)
+	case "oidc provider unavailable":
+		utils.SendError(ctx, utils.CodeInternalError, dictionaries.OIDCProviderUnavailable, err)
+	case "invalid oidc state":
+		utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.InvalidOIDCState, err)
+	case "oidc login failed":
+		utils.SendError(ctx, utils.CodeUnauthorized, dictionaries.OIDCLoginFailed, err)
+	case "oidc email not verified":
+		utils.SendError(ctx, utils.CodeForbidden, dictionaries.OIDCEmailNotVerified, err)
+	case "oidc email missing":
+		utils.SendError(ctx, utils.CodeForbidden, dictionaries.OIDCEmailMissing, err)
+	case "no role for oidc groups":
+		utils.SendError(ctx, utils.CodeForbidden, dictionaries.OIDCNoRole, err)
+	case "oidc provisioning disabled":
+		utils.SendError(ctx, utils.CodeForbidden, dictionaries.OIDCProvisioningOff, err)
+	case "oidc account disabled":
+		utils.SendError(ctx, utils.CodeForbidden, dictionaries.OIDCAccountDisabled, err)
+	case "oidc login not allowed":
+		utils.SendError(ctx, utils.CodeForbidden, dictionaries.OIDCLoginNotAllowed, err)
+	case "too many mfa attempts":
+		utils.SendError(ctx, utils.CodeTooManyRequests, dictionaries.TooManyMFAAttempts, err)
+	default:
+		utils.SendError(ctx, utils.CodeInternalError, utils.MsgInternalError, err)
+	}
+}
+
+// StartOIDCLogin starts a single sign-on login, the client sends the user to the authorization URL
+// and keeps the token for the callback
+func (c *AuthController) StartOIDCLogin(ctx *gin.Context) {
+	authorization, err := c.authService.StartOIDCLogin(ctx.Request.Context())
+	if err != nil {
+		oidcError(ctx, err)
+		return
+	}
+
+	utils.SendSuccess(ctx, dictionaries.OIDCLoginStarted, authorization)
+}
+
+// OIDCCallback finishes a single sign-on login with the code the identity provider redirected the
+// user back with, and answers like Login
+func (c *AuthController) OIDCCallback(ctx *gin.Context) {
+	var request utils.OIDCCallbackRequest
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	user, tokens, challenge, err := c.authService.OIDCLogin(ctx.Request.Context(), request.Code, request.State, request.Token, sessionClient(ctx))
+	if err != nil {
+		logger.Warning("Auth Controller: Single sign-on login failed", map[string]interface{}{
+			"ip":    ctx.ClientIP(),
+			"error": err.Error(),
+		})
+		oidcError(ctx, err)
+		return
+	}
+
+	sendLogin(ctx, user, tokens, challenge)
+}
@@ -0,0 +1,243 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+
+	"hcall/api/dictionaries"
+	"hcall/api/logger"
+	"hcall/api/models"
+	"hcall/api/services"
+	"hcall/api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type QueueController struct {
+	queueService *services.QueueService
+}
+
+func NewQueueController() *QueueController {
+	return &QueueController{
+		queueService: services.NewQueueService(),
+	}
+}
+
+// GetQueues lists the queues a ticket can be created in
+func (c *QueueController) GetQueues(ctx *gin.Context) {
+	queues, err := c.queueService.GetQueues()
+	if err != nil {
+		logger.Error("Queue Controller: Failed to list queues", map[string]interface{}{
+			"error": err.Error(),
+		})
+		utils.SendError(ctx, utils.CodeInternalError, utils.MsgInternalError, err)
+		return
+	}
+
+	if queues == nil {
+		queues = []models.Queue{}
+	}
+
+	utils.SendSuccess(ctx, dictionaries.QueuesListedSuccess, gin.H{
+		"queues": queues,
+	})
+}
+
+func (c *QueueController) CreateQueue(ctx *gin.Context) {
+	var request utils.CreateQueueRequest
+
+	// Bind request body to struct
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	// Call the service
+	queue, err := c.queueService.CreateQueue(request.Name, request.Description, request.IsDefault)
+	if err != nil {
+		logger.Error("Queue Controller: Queue creation failed", map[string]interface{}{
+			"name":  request.Name,
+			"error": err.Error(),
+		})
+		if err.Error() == "queue already exists" {
+			utils.SendError(ctx, utils.CodeDuplicateEntry, dictionaries.QueueAlreadyExists, err)
+			return
+		}
+		utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.QueueCreationFailed, err)
+		return
+	}
+
+	logger.Info("Queue Controller: Queue created successfully", map[string]interface{}{
+		"queue_id": queue.ID,
+		"name":     queue.Name,
+	})
+
+	utils.SendSuccess(ctx, dictionaries.QueueCreatedSuccess, gin.H{
+		"queue": queue,
+	})
+}
+
+// UpdateFieldSchema replaces the custom fields of the tickets of a queue
+func (c *QueueController) UpdateFieldSchema(ctx *gin.Context) {
+	var request utils.UpdateQueueFieldsRequest
+
+	// Bind request body to struct
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	userID, _ := ctx.Get("userId")
+	userRole, _ := ctx.Get("userRole")
+
+	// Call the service
+	err := c.queueService.UpdateFieldSchema(request.QueueID, request.FieldSchema, userID.(uint), userRole.(models.Role))
+	if err != nil {
+		logger.Error("Queue Controller: Failed to update queue custom fields", map[string]interface{}{
+			"queue_id": request.QueueID,
+			"error":    err.Error(),
+		})
+		switch {
+		case errors.Is(err, services.ErrInvalidFieldSchema):
+			utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.InvalidFieldSchema, err)
+		case err.Error() == "you don't have access to this queue":
+			utils.SendError(ctx, utils.CodeForbidden, dictionaries.QueueAccessDenied, err)
+		default:
+			c.sendMemberError(ctx, err)
+		}
+		return
+	}
+
+	logger.Info("Queue Controller: Queue custom fields updated successfully", map[string]interface{}{
+		"queue_id": request.QueueID,
+	})
+
+	utils.SendSuccess(ctx, dictionaries.QueueFieldsUpdated, nil)
+}
+
+func (c *QueueController) DeleteQueue(ctx *gin.Context) {
+	var request utils.DeleteQueueRequest
+
+	// Bind request body to struct
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	// Call the service
+	if err := c.queueService.DeleteQueue(request.QueueID); err != nil {
+		logger.Error("Queue Controller: Queue deletion failed", map[string]interface{}{
+			"queue_id": request.QueueID,
+			"error":    err.Error(),
+		})
+		switch err.Error() {
+		case "queue not found":
+			utils.SendError(ctx, utils.CodeNotFound, dictionaries.QueueNotFound, err)
+		case "cannot delete queue with existing tickets":
+			utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.QueueNotEmpty, err)
+		default:
+			utils.SendError(ctx, utils.CodeInternalError, dictionaries.QueueDeletionFailed, err)
+		}
+		return
+	}
+
+	logger.Info("Queue Controller: Queue deleted successfully", map[string]interface{}{
+		"queue_id": request.QueueID,
+	})
+
+	utils.SendSuccess(ctx, dictionaries.QueueDeletedSuccess, nil)
+}
+
+func (c *QueueController) AddMember(ctx *gin.Context) {
+	var request utils.QueueMemberRequest
+
+	// Bind request body to struct
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	// Call the service
+	if err := c.queueService.AddMember(request.QueueID, request.Email); err != nil {
+		logger.Error("Queue Controller: Failed to add queue member", map[string]interface{}{
+			"queue_id": request.QueueID,
+			"email":    request.Email,
+			"error":    err.Error(),
+		})
+		c.sendMemberError(ctx, err)
+		return
+	}
+
+	logger.Info("Queue Controller: Queue member added successfully", map[string]interface{}{
+		"queue_id": request.QueueID,
+		"email":    request.Email,
+	})
+
+	utils.SendSuccess(ctx, dictionaries.QueueMemberAdded, nil)
+}
+
+func (c *QueueController) RemoveMember(ctx *gin.Context) {
+	var request utils.QueueMemberRequest
+
+	// Bind request body to struct
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	// Call the service
+	if err := c.queueService.RemoveMember(request.QueueID, request.Email); err != nil {
+		logger.Error("Queue Controller: Failed to remove queue member", map[string]interface{}{
+			"queue_id": request.QueueID,
+			"email":    request.Email,
+			"error":    err.Error(),
+		})
+		c.sendMemberError(ctx, err)
+		return
+	}
+
+	logger.Info("Queue Controller: Queue member removed successfully", map[string]interface{}{
+		"queue_id": request.QueueID,
+		"email":    request.Email,
+	})
+
+	utils.SendSuccess(ctx, dictionaries.QueueMemberRemoved, nil)
+}
+
+// GetMembers lists the admins working a queue
+func (c *QueueController) GetMembers(ctx *gin.Context) {
+	queueID, err := strconv.ParseUint(ctx.Query("queue_id"), 10, 64)
+	if err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, "Queue ID is required", nil)
+		return
+	}
+
+	// Call the service
+	members, err := c.queueService.GetMembers(uint(queueID))
+	if err != nil {
+		logger.Error("Queue Controller: Failed to list queue members", map[string]interface{}{
+			"queue_id": queueID,
+			"error":    err.Error(),
+		})
+		c.sendMemberError(ctx, err)
+		return
+	}
+
+	utils.SendSuccess(ctx, dictionaries.QueueMembersListed, gin.H{
+		"members": members,
+	})
+}
+
+// sendMemberError answers a failed queue membership operation
+func (c *QueueController) sendMemberError(ctx *gin.Context, err error) {
+	switch err.Error() {
+	case "queue not found":
+		utils.SendError(ctx, utils.CodeNotFound, dictionaries.QueueNotFound, err)
+	case "user not found":
+		utils.SendError(ctx, utils.CodeNotFound, dictionaries.UserNotFound, err)
+	case "only admin users can be queue members", "user is not a member of the queue":
+		utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.QueueMemberFailed, err)
+	default:
+		utils.SendError(ctx, utils.CodeInternalError, utils.MsgInternalError, err)
+	}
+}
@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"hcall/api/dictionaries"
+	"hcall/api/logger"
+	"hcall/api/models"
+	"hcall/api/services"
+	"hcall/api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ServiceAccountController struct {
+	serviceAccountService *services.ServiceAccountService
+}
+
+func NewServiceAccountController() *ServiceAccountController {
+	return &ServiceAccountController{
+		serviceAccountService: services.NewServiceAccountService(),
+	}
+}
+
+// serviceAccountError answers a failed service account or API key request
+func serviceAccountError(ctx *gin.Context, err error) {
+	switch err.Error() {
+	case "service account not found":
+		utils.SendError(ctx, utils.CodeNotFound, dictionaries.ServiceAccountNotFound, err)
+	case "service account name is required", "invalid service account role":
+		utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.InvalidServiceAccount, err)
+	case "invalid api key scope":
+		utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.InvalidAPIKeyScope, err)
+	case "api key name is required":
+		utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.InvalidAPIKeyName, err)
+	case "api key not found":
+		utils.SendError(ctx, utils.CodeNotFound, dictionaries.APIKeyNotFound, err)
+	default:
+		utils.SendError(ctx, utils.CodeInternalError, utils.MsgInternalError, err)
+	}
+}
+
+// CreateServiceAccount creates a service account for a machine integration
+func (c *ServiceAccountController) CreateServiceAccount(ctx *gin.Context) {
+	var request utils.CreateServiceAccountRequest
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	user, err := c.serviceAccountService.CreateServiceAccount(request.Name, request.Role)
+	if err != nil {
+		serviceAccountError(ctx, err)
+		return
+	}
+
+	logger.Info("Service Account Controller: Service account created", map[string]interface{}{
+		"email": user.Email,
+		"role":  user.Role,
+	})
+
+	utils.SendSuccess(ctx, dictionaries.ServiceAccountCreated, gin.H{
+		"user": user.ToResponse(false),
+	})
+}
+
+// GetServiceAccounts lists the service accounts
+func (c *ServiceAccountController) GetServiceAccounts(ctx *gin.Context) {
+	users, err := c.serviceAccountService.GetServiceAccounts()
+	if err != nil {
+		serviceAccountError(ctx, err)
+		return
+	}
+
+	responseUsers := make([]models.ResponseUser, len(users))
+	for i, user := range users {
+		responseUsers[i] = user.ToResponse(false)
+	}
+
+	utils.SendSuccess(ctx, dictionaries.ServiceAccountsListed, gin.H{
+		"users": responseUsers,
+	})
+}
+
+// CreateAPIKey creates an API key for a service account, the key is only shown in this response
+func (c *ServiceAccountController) CreateAPIKey(ctx *gin.Context) {
+	var request utils.CreateAPIKeyRequest
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	userID, _ := ctx.Get("userId")
+
+	key, err := c.serviceAccountService.CreateKey(request.Email, request.Name, request.Scopes, request.ExpiresInDays, userID.(uint))
+	if err != nil {
+		serviceAccountError(ctx, err)
+		return
+	}
+
+	logger.Info("Service Account Controller: API key created", map[string]interface{}{
+		"email":      request.Email,
+		"api_key_id": key.ID,
+		"scopes":     key.Scopes,
+		"created_by": userID,
+	})
+
+	utils.SendSuccess(ctx, dictionaries.APIKeyCreated, key)
+}
+
+// GetAPIKeys lists the API keys of a service account
+func (c *ServiceAccountController) GetAPIKeys(ctx *gin.Context) {
+	var query utils.APIKeysQuery
+
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	keys, err := c.serviceAccountService.GetKeys(query.Email)
+	if err != nil {
+		serviceAccountError(ctx, err)
+		return
+	}
+
+	utils.SendSuccess(ctx, dictionaries.APIKeysListed, gin.H{
+		"api_keys": keys,
+	})
+}
+
+// RevokeAPIKey revokes an API key of a service account
+func (c *ServiceAccountController) RevokeAPIKey(ctx *gin.Context) {
+	var request utils.RevokeAPIKeyRequest
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	if err := c.serviceAccountService.RevokeKey(request.Email, request.KeyID); err != nil {
+		serviceAccountError(ctx, err)
+		return
+	}
+
+	userID, _ := ctx.Get("userId")
+	logger.Info("Service Account Controller: API key revoked", map[string]interface{}{
+		"email":      request.Email,
+		"api_key_id": request.KeyID,
+		"revoked_by": userID,
+	})
+
+	utils.SendSuccess(ctx, dictionaries.APIKeyRevoked, nil)
+}
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"hcall/api/dictionaries"
+	"hcall/api/logger"
+	"hcall/api/models"
+	"hcall/api/services"
+	"hcall/api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TagController struct {
+	tagService *services.TagService
+}
+
+func NewTagController() *TagController {
+	return &TagController{
+		tagService: services.NewTagService(),
+	}
+}
+
+// GetTags lists the tags with the number of tickets labeled with each one
+func (c *TagController) GetTags(ctx *gin.Context) {
+	tags, err := c.tagService.GetTags()
+	if err != nil {
+		logger.Error("Tag Controller: Failed to list tags", map[string]interface{}{
+			"error": err.Error(),
+		})
+		utils.SendError(ctx, utils.CodeInternalError, utils.MsgInternalError, err)
+		return
+	}
+
+	if tags == nil {
+		tags = []models.TagUsage{}
+	}
+
+	utils.SendSuccess(ctx, dictionaries.TagsListedSuccess, gin.H{
+		"tags": tags,
+	})
+}
+
+func (c *TagController) RenameTag(ctx *gin.Context) {
+	var request utils.RenameTagRequest
+
+	// Bind request body to struct
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	// Call the service
+	if err := c.tagService.RenameTag(request.Name, request.NewName); err != nil {
+		logger.Error("Tag Controller: Failed to rename tag", map[string]interface{}{
+			"tag":      request.Name,
+			"new_name": request.NewName,
+			"error":    err.Error(),
+		})
+		sendTagError(ctx, err, dictionaries.TagRenameFailed)
+		return
+	}
+
+	logger.Info("Tag Controller: Tag renamed successfully", map[string]interface{}{
+		"tag":      request.Name,
+		"new_name": request.NewName,
+	})
+
+	utils.SendSuccess(ctx, dictionaries.TagRenamed, nil)
+}
+
+func (c *TagController) MergeTags(ctx *gin.Context) {
+	var request utils.MergeTagsRequest
+
+	// Bind request body to struct
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	// Call the service
+	if err := c.tagService.MergeTags(request.Source, request.Target); err != nil {
+		logger.Error("Tag Controller: Failed to merge tags", map[string]interface{}{
+			"source": request.Source,
+			"target": request.Target,
+			"error":  err.Error(),
+		})
+		sendTagError(ctx, err, dictionaries.TagMergeFailed)
+		return
+	}
+
+	logger.Info("Tag Controller: Tags merged successfully", map[string]interface{}{
+		"source": request.Source,
+		"target": request.Target,
+	})
+
+	utils.SendSuccess(ctx, dictionaries.TagsMerged, nil)
+}
+
+// sendTagError answers a failed tag operation
+func sendTagError(ctx *gin.Context, err error, fallback string) {
+	switch err.Error() {
+	case "tag not found":
+		utils.SendError(ctx, utils.CodeNotFound, dictionaries.TagNotFound, err)
+	case "ticket not found":
+		utils.SendError(ctx, utils.CodeNotFound, dictionaries.TicketNotFound, err)
+	case "tag already exists":
+		utils.SendError(ctx, utils.CodeDuplicateEntry, dictionaries.TagAlreadyExists, err)
+	case "invalid tag name":
+		utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.InvalidTagName, err)
+	default:
+		utils.SendError(ctx, utils.CodeInvalidInput, fallback, err)
+	}
+}
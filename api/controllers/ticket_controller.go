@@ -1,6 +1,15 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"time"
+
+	"hcall/api/config"
 	"hcall/api/dictionaries"
 	"hcall/api/logger"
 	"hcall/api/models"
@@ -22,11 +31,44 @@ func NewTicketController() *TicketController {
 
 func (c *TicketController) CreateTicket(ctx *gin.Context) {
 	var request utils.CreateTicketRequest
+	var images []services.ImageUpload
 
-	// Bind request body to struct
-	if err := ctx.ShouldBindJSON(&request); err != nil {
-		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
-		return
+	// Tickets are created from a JSON body with base64 images or from a multipart form with image files
+	if ctx.ContentType() == "multipart/form-data" {
+		limitMultipartBody(ctx)
+
+		uploads, closeAll, err := multipartImages(ctx, "ticket_images")
+		if err != nil {
+			utils.SendError(ctx, utils.CodeInvalidInput, imageErrorMessage(err, dictionaries.ImageUploadFailed), err)
+			return
+		}
+		defer closeAll()
+		images = uploads
+
+		if err := ctx.ShouldBind(&request); err != nil {
+			utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+			return
+		}
+	} else {
+		limitJSONBody(ctx)
+
+		// Bind request body to struct
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.ImageTooLarge, err)
+				return
+			}
+			utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+			return
+		}
+
+		uploads, err := services.ImagesFromDTO(request.Images)
+		if err != nil {
+			utils.SendError(ctx, utils.CodeInvalidInput, imageErrorMessage(err, dictionaries.InvalidImageContent), err)
+			return
+		}
+		images = uploads
 	}
 
 	// Get user ID and email
@@ -39,7 +81,10 @@ func (c *TicketController) CreateTicket(ctx *gin.Context) {
 		userEmail.(string),
 		request.Name,
 		request.Explanation,
-		request.Images,
+		request.Priority,
+		request.QueueID,
+		request.CustomFields,
+		images,
 	)
 
 	if err != nil {
@@ -49,7 +94,15 @@ func (c *TicketController) CreateTicket(ctx *gin.Context) {
 			"name":    request.Name,
 			"error":   err.Error(),
 		})
-		utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.TicketCreationFailed, err)
+		if err.Error() == "queue not found" {
+			utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.QueueNotFound, err)
+			return
+		}
+		if errors.Is(err, services.ErrInvalidCustomField) {
+			utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.InvalidCustomFields, err)
+			return
+		}
+		utils.SendError(ctx, utils.CodeInvalidInput, imageErrorMessage(err, dictionaries.TicketCreationFailed), err)
 		return
 	}
 
@@ -63,33 +116,150 @@ func (c *TicketController) CreateTicket(ctx *gin.Context) {
 	utils.SendSuccess(ctx, dictionaries.TicketCreatedSuccess, nil)
 }
 
+// UploadTicketImages attaches image files sent as a multipart form to an existing ticket
+func (c *TicketController) UploadTicketImages(ctx *gin.Context) {
+	limitMultipartBody(ctx)
+
+	// Parse the form first so a body above the limit is reported as such
+	uploads, closeAll, err := multipartImages(ctx, "ticket_images")
+	if err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, imageErrorMessage(err, dictionaries.ImageUploadFailed), err)
+		return
+	}
+	defer closeAll()
+
+	var request utils.UploadTicketImagesRequest
+	if err := ctx.ShouldBind(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	if len(uploads) == 0 {
+		utils.SendError(ctx, utils.CodeInvalidInput, "At least one image is required", nil)
+		return
+	}
+
+	userID, _ := ctx.Get("userId")
+	userRole, _ := ctx.Get("userRole")
+
+	// Call the service
+	images, err := c.ticketService.AddTicketImages(request.TicketID, userID.(uint), userRole.(models.Role), uploads)
+	if err != nil {
+		logger.Error("Ticket Controller: Failed to upload ticket images", map[string]interface{}{
+			"ticket_id": request.TicketID,
+			"user_id":   userID,
+			"error":     err.Error(),
+		})
+		utils.SendError(ctx, utils.CodeInvalidInput, imageErrorMessage(err, dictionaries.ImageUploadFailed), err)
+		return
+	}
+
+	logger.Info("Ticket Controller: Ticket images uploaded successfully", map[string]interface{}{
+		"ticket_id": request.TicketID,
+		"user_id":   userID,
+		"images":    len(images),
+	})
+
+	utils.SendSuccess(ctx, dictionaries.ImageUploadedSuccess, gin.H{
+		"images": images,
+	})
+}
+
+// limitMultipartBody refuses request bodies bigger than the images a ticket can hold
+func limitMultipartBody(ctx *gin.Context) {
+	// Leave some room for the form fields and the multipart boundaries
+	maxBody := config.AppConfig.ImageMaxTicketSize() + 1<<20
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxBody)
+}
+
+// limitJSONBody refuses JSON bodies bigger than the base64 images a ticket can hold, base64 takes
+// 4 bytes for every 3 of the images
+func limitJSONBody(ctx *gin.Context) {
+	// Leave some room for the other fields
+	maxBody := config.AppConfig.ImageMaxTicketSize()*4/3 + 1<<20
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxBody)
+}
+
+// multipartImages opens the files sent in a multipart form field, the returned function closes them
+func multipartImages(ctx *gin.Context, field string) ([]services.ImageUpload, func(), error) {
+	form, err := ctx.MultipartForm()
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, func() {}, services.ErrImageTooLarge
+		}
+		return nil, func() {}, err
+	}
+
+	files := make([]multipart.File, 0, len(form.File[field]))
+	closeAll := func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}
+
+	uploads := make([]services.ImageUpload, 0, len(form.File[field]))
+	for _, header := range form.File[field] {
+		file, err := header.Open()
+		if err != nil {
+			closeAll()
+			return nil, func() {}, err
+		}
+		files = append(files, file)
+
+		uploads = append(uploads, services.ImageUpload{
+			Name:    header.Filename,
+			Size:    header.Size,
+			Content: file,
+		})
+	}
+
+	return uploads, closeAll, nil
+}
+
+// imageErrorMessage picks the message of the image errors, or the fallback for any other error
+func imageErrorMessage(err error, fallback string) string {
+	switch {
+	case errors.Is(err, services.ErrImageTooLarge):
+		return dictionaries.ImageTooLarge
+	case errors.Is(err, services.ErrInvalidImageFormat):
+		return dictionaries.InvalidImageFormat
+	case errors.Is(err, services.ErrTooManyImages):
+		return dictionaries.TooManyImages
+	default:
+		return fallback
+	}
+}
+
 func (c *TicketController) GetTickets(ctx *gin.Context) {
-	author := ctx.Query("author")
-	status := ctx.Query("status")
-	date := ctx.Query("date")
-	name := ctx.Query("name")
+	var query utils.FetchTicketsQuery
+
+	// Bind query parameters to struct
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	userID, _ := ctx.Get("userId")
+	userRole, _ := ctx.Get("userRole")
 
 	// Call the service
-	tickets, err := c.ticketService.GetTickets(author, status, date, name)
+	tickets, page, err := c.ticketService.GetTickets(query, userID.(uint), userRole.(models.Role))
 
 	if err != nil {
-		if err.Error() == "Invalid date format" {
-			logger.Error("Ticket Controller: Invalid date format in ticket query", map[string]interface{}{
-				"date":  date,
-				"error": err.Error(),
-			})
-			utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.InvalidDateFormat, err)
+		if message := listingErrorMessage(err); message != "" {
+			utils.SendError(ctx, utils.CodeInvalidInput, message, err)
 			return
 		}
 
 		logger.Error("Ticket Controller: Failed to get tickets", map[string]interface{}{
-			"author": author,
-			"status": status,
-			"date":   date,
-			"name":   name,
+			"author": query.Author,
+			"status": query.Status,
+			"date":   query.DateFrom,
+			"name":   query.Name,
 			"error":  err.Error(),
 		})
-		utils.SendError(ctx, utils.CodeNotFound, dictionaries.NoTicketsFound, err)
+		utils.SendError(ctx, utils.CodeInternalError, utils.MsgInternalError, err)
 		return
 	}
 
@@ -110,10 +280,132 @@ func (c *TicketController) GetTickets(ctx *gin.Context) {
 	}
 
 	utils.SendSuccess(ctx, "Tickets found", gin.H{
-		"tickets": responseTickets,
+		"tickets":    responseTickets,
+		"pagination": page,
+	})
+}
+
+// ExportTickets streams the tickets matching the listing filters as CSV or JSON lines
+func (c *TicketController) ExportTickets(ctx *gin.Context) {
+	var query utils.ExportTicketsQuery
+
+	// Bind query parameters to struct
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	userID, _ := ctx.Get("userId")
+	userRole, _ := ctx.Get("userRole")
+
+	export, err := c.ticketService.NewTicketExport(query, userID.(uint), userRole.(models.Role))
+	if err != nil {
+		if message := listingErrorMessage(err); message != "" {
+			utils.SendError(ctx, utils.CodeInvalidInput, message, err)
+			return
+		}
+
+		logger.Error("Ticket Controller: Failed to prepare ticket export", map[string]interface{}{
+			"error": err.Error(),
+		})
+		utils.SendError(ctx, utils.CodeInternalError, utils.MsgInternalError, err)
+		return
+	}
+
+	ctx.Header("Content-Type", export.ContentType())
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, export.FileName(time.Now())))
+	ctx.Status(http.StatusOK)
+
+	// The status is already sent once the export streams, a failure can only cut it short
+	count, err := export.Write(ctx.Writer, ctx.Writer.Flush)
+	if err != nil {
+		logger.Error("Ticket Controller: Ticket export interrupted", map[string]interface{}{
+			"user_id": userID,
+			"written": count,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.Info("Ticket Controller: Tickets exported successfully", map[string]interface{}{
+		"user_id": userID,
+		"count":   count,
+	})
+}
+
+// SearchTickets runs a full-text search over the tickets, combined with the listing filters
+func (c *TicketController) SearchTickets(ctx *gin.Context) {
+	var query utils.SearchTicketsQuery
+
+	// Bind query parameters to struct
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	userID, _ := ctx.Get("userId")
+	userRole, _ := ctx.Get("userRole")
+
+	// Call the service
+	tickets, highlights, page, err := c.ticketService.SearchTickets(query, userID.(uint), userRole.(models.Role))
+	if err != nil {
+		if message := listingErrorMessage(err); message != "" {
+			utils.SendError(ctx, utils.CodeInvalidInput, message, err)
+			return
+		}
+
+		logger.Error("Ticket Controller: Failed to search tickets", map[string]interface{}{
+			"query": query.Query,
+			"error": err.Error(),
+		})
+		utils.SendError(ctx, utils.CodeInternalError, utils.MsgInternalError, err)
+		return
+	}
+
+	// Convert to response format
+	results := make([]models.TicketSearchResponse, len(tickets))
+	for i, ticket := range tickets {
+		username, err := c.ticketService.GetUserUsername(ticket.AuthorID)
+		if err != nil {
+			username = "Unknown User"
+		}
+
+		results[i] = models.TicketSearchResponse{
+			BasicTicketResponse: ticket.ToBasicResponse(username),
+			Rank:                ticket.SearchRank,
+			Highlight:           highlights[ticket.ID],
+		}
+	}
+
+	utils.SendSuccess(ctx, dictionaries.TicketSearchSuccess, gin.H{
+		"tickets":    results,
+		"pagination": page,
 	})
 }
 
+// listingErrorMessage gives the message of a ticket listing refused because of its parameters,
+// or an empty string for any other error
+func listingErrorMessage(err error) string {
+	switch {
+	case services.IsPaginationError(err):
+		return dictionaries.InvalidPagination
+	case services.IsTicketFilterError(err):
+		return dictionaries.InvalidTicketFilter
+	case err.Error() == "invalid assigned filter":
+		return dictionaries.InvalidAssignedFilter
+	case err.Error() == "invalid date format":
+		return dictionaries.InvalidDateFormat
+	case err.Error() == "invalid date range":
+		return dictionaries.InvalidDateRange
+	case err.Error() == "invalid export columns":
+		return dictionaries.InvalidExportColumns
+	case err.Error() == "search text is required":
+		return dictionaries.SearchTextRequired
+	default:
+		return ""
+	}
+}
+
 func (c *TicketController) GetTicketDetails(ctx *gin.Context) {
 	ticketID := ctx.Query("ticket_id")
 	if ticketID == "" {
@@ -121,8 +413,11 @@ func (c *TicketController) GetTicketDetails(ctx *gin.Context) {
 		return
 	}
 
+	userID, _ := ctx.Get("userId")
+	userRole, _ := ctx.Get("userRole")
+
 	// Call the service
-	ticket, err := c.ticketService.GetTicketDetails(ticketID)
+	ticket, err := c.ticketService.GetTicketDetails(ticketID, userID.(uint), userRole.(models.Role))
 	if err != nil {
 		logger.Error("Ticket Controller: Failed to get ticket details", map[string]interface{}{
 			"ticket_id": ticketID,
@@ -163,8 +458,13 @@ func (c *TicketController) UpdateTicketStatus(ctx *gin.Context) {
 		return
 	}
 
+	// Get user ID, email and role
+	userID, _ := ctx.Get("userId")
+	userEmail, _ := ctx.Get("userEmail")
+	userRole, _ := ctx.Get("userRole")
+
 	// Call the service
-	err := c.ticketService.UpdateTicketStatus(request.TicketID, request.Status)
+	err := c.ticketService.UpdateTicketStatus(request.TicketID, request.Status, request.Reason, userID.(uint), userEmail.(string), userRole.(models.Role))
 	if err != nil {
 		logger.Error("Ticket Controller: Failed to update ticket status", map[string]interface{}{
 			"ticket_id": request.TicketID,
@@ -183,6 +483,36 @@ func (c *TicketController) UpdateTicketStatus(ctx *gin.Context) {
 	utils.SendSuccess(ctx, dictionaries.TicketStatusUpdated, nil)
 }
 
+func (c *TicketController) GetStatusTimeline(ctx *gin.Context) {
+	ticketID := ctx.Query("ticket_id")
+	if ticketID == "" {
+		utils.SendError(ctx, utils.CodeInvalidInput, "Ticket ID is required", nil)
+		return
+	}
+
+	userID, _ := ctx.Get("userId")
+	userRole, _ := ctx.Get("userRole")
+
+	// Call the service
+	timeline, err := c.ticketService.GetStatusTimeline(ticketID, userID.(uint), userRole.(models.Role))
+	if err != nil {
+		logger.Error("Ticket Controller: Failed to get ticket status timeline", map[string]interface{}{
+			"ticket_id": ticketID,
+			"error":     err.Error(),
+		})
+		utils.SendError(ctx, utils.CodeNotFound, dictionaries.TicketNotFound, err)
+		return
+	}
+
+	if timeline == nil {
+		timeline = []models.TicketStatusChange{}
+	}
+
+	utils.SendSuccess(ctx, dictionaries.TicketTimelineFound, gin.H{
+		"timeline": timeline,
+	})
+}
+
 func (c *TicketController) UpdateTicketHistory(ctx *gin.Context) {
 	var request utils.UpdateTicketHistoryRequest
 
@@ -192,8 +522,11 @@ func (c *TicketController) UpdateTicketHistory(ctx *gin.Context) {
 		return
 	}
 
+	userID, _ := ctx.Get("userId")
+	userRole, _ := ctx.Get("userRole")
+
 	// Call the service
-	err := c.ticketService.AddTicketHistory(request.TicketID, request.Message)
+	err := c.ticketService.AddTicketHistory(request.TicketID, request.Message, request.Internal, userID.(uint), userRole.(models.Role))
 	if err != nil {
 		logger.Error("Ticket Controller: Failed to update ticket history", map[string]interface{}{
 			"ticket_id": request.TicketID,
@@ -210,8 +543,8 @@ func (c *TicketController) UpdateTicketHistory(ctx *gin.Context) {
 	utils.SendSuccess(ctx, dictionaries.TicketHistoryAdded, nil)
 }
 
-func (c *TicketController) DeleteTicket(ctx *gin.Context) {
-	var request utils.RemoveTicketRequest
+func (c *TicketController) UpdateTicketPriority(ctx *gin.Context) {
+	var request utils.UpdateTicketPriorityRequest
 
 	// Bind request body to struct
 	if err := ctx.ShouldBindJSON(&request); err != nil {
@@ -219,52 +552,699 @@ func (c *TicketController) DeleteTicket(ctx *gin.Context) {
 		return
 	}
 
-	// Get user ID and role
 	userID, _ := ctx.Get("userId")
 	userRole, _ := ctx.Get("userRole")
 
 	// Call the service
-	err := c.ticketService.DeleteTicket(request.TicketID, userID.(uint), userRole.(models.Role))
+	err := c.ticketService.UpdateTicketPriority(request.TicketID, request.Priority, userID.(uint), userRole.(models.Role))
 	if err != nil {
-		logger.Error("Ticket Controller: Failed to delete ticket", map[string]interface{}{
+		logger.Error("Ticket Controller: Failed to update ticket priority", map[string]interface{}{
+			"ticket_id": request.TicketID,
+			"priority":  request.Priority,
+			"error":     err.Error(),
+		})
+		utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.TicketPriorityUpdateFailed, err)
+		return
+	}
+
+	logger.Info("Ticket Controller: Ticket priority updated successfully", map[string]interface{}{
+		"ticket_id": request.TicketID,
+		"priority":  request.Priority,
+	})
+
+	utils.SendSuccess(ctx, dictionaries.TicketPriorityUpdated, nil)
+}
+
+func (c *TicketController) ReplyToTicket(ctx *gin.Context) {
+	var request utils.ReplyTicketRequest
+
+	// Bind request body to struct
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	userID, _ := ctx.Get("userId")
+
+	// Call the service
+	err := c.ticketService.ReplyToOwnTicket(request.TicketID, request.Message, userID.(uint))
+	if err != nil {
+		logger.Error("Ticket Controller: Failed to reply to ticket", map[string]interface{}{
 			"ticket_id": request.TicketID,
 			"user_id":   userID,
-			"role":      userRole,
 			"error":     err.Error(),
 		})
-		utils.SendError(ctx, utils.CodeForbidden, dictionaries.NoPermissionToDelete, err)
+		if err.Error() == "ticket not found" {
+			utils.SendError(ctx, utils.CodeNotFound, dictionaries.TicketNotFound, err)
+			return
+		}
+		utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.TicketHistoryAddFailed, err)
 		return
 	}
 
-	logger.Info("Ticket Controller: Ticket deleted successfully", map[string]interface{}{
+	logger.Info("Ticket Controller: Requester replied to ticket", map[string]interface{}{
 		"ticket_id": request.TicketID,
 		"user_id":   userID,
 	})
 
-	utils.SendSuccess(ctx, dictionaries.TicketDeletedSuccess, nil)
+	utils.SendSuccess(ctx, dictionaries.TicketHistoryAdded, nil)
 }
 
-func (c *TicketController) CountTicket(ctx *gin.Context) {
-	count, err := c.ticketService.GetCounters()
+func (c *TicketController) EditTicketMessage(ctx *gin.Context) {
+	var request utils.EditTicketMessageRequest
+
+	// Bind request body to struct
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	userID, _ := ctx.Get("userId")
+	userEmail, _ := ctx.Get("userEmail")
+
+	// Call the service
+	err := c.ticketService.EditTicketMessage(request.MessageID, request.Message, userID.(uint), userEmail.(string))
 	if err != nil {
-		logger.Error("Ticket Controller: Failed to get ticket counters", map[string]interface{}{
-			"error": err.Error(),
+		logger.Error("Ticket Controller: Failed to edit ticket message", map[string]interface{}{
+			"message_id": request.MessageID,
+			"user_id":    userID,
+			"error":      err.Error(),
 		})
-		utils.SendError(ctx, utils.CodeNotFound, dictionaries.TicketNotFound, err)
+		if err.Error() == "message not found" {
+			utils.SendError(ctx, utils.CodeNotFound, dictionaries.TicketMessageNotFound, err)
+			return
+		}
+		utils.SendError(ctx, utils.CodeForbidden, dictionaries.TicketMessageEditFailed, err)
 		return
 	}
 
-	logger.Info("Ticket Controller: Ticket counters retrieved successfully", map[string]interface{}{
-		"total":    count.Total,
-		"pending":  count.Pending,
-		"doing":    count.Doing,
-		"conclued": count.Conclued,
+	logger.Info("Ticket Controller: Ticket message edited successfully", map[string]interface{}{
+		"message_id": request.MessageID,
+		"user_id":    userID,
 	})
 
-	utils.SendSuccess(ctx, "Ticket counters retrieved", gin.H{
-		"total":    count.Total,
-		"pending":  count.Pending,
-		"doing":    count.Doing,
-		"conclued": count.Conclued,
-	})
+	utils.SendSuccess(ctx, dictionaries.TicketMessageEdited, nil)
+}
+
+func (c *TicketController) DeleteTicketMessage(ctx *gin.Context) {
+	var request utils.DeleteTicketMessageRequest
+
+	// Bind request body to struct
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	userID, _ := ctx.Get("userId")
+	userEmail, _ := ctx.Get("userEmail")
+	userRole, _ := ctx.Get("userRole")
+
+	// Call the service
+	err := c.ticketService.DeleteTicketMessage(request.MessageID, userID.(uint), userEmail.(string), userRole.(models.Role))
+	if err != nil {
+		logger.Error("Ticket Controller: Failed to delete ticket message", map[string]interface{}{
+			"message_id": request.MessageID,
+			"user_id":    userID,
+			"error":      err.Error(),
+		})
+		if err.Error() == "message not found" {
+			utils.SendError(ctx, utils.CodeNotFound, dictionaries.TicketMessageNotFound, err)
+			return
+		}
+		utils.SendError(ctx, utils.CodeForbidden, dictionaries.TicketMessageDeleteFailed, err)
+		return
+	}
+
+	logger.Info("Ticket Controller: Ticket message deleted successfully", map[string]interface{}{
+		"message_id": request.MessageID,
+		"user_id":    userID,
+	})
+
+	utils.SendSuccess(ctx, dictionaries.TicketMessageDeleted, nil)
+}
+
+func (c *TicketController) GetMessageAudit(ctx *gin.Context) {
+	messageID, err := strconv.ParseUint(ctx.Query("message_id"), 10, 64)
+	if err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, "Message ID is required", nil)
+		return
+	}
+
+	userID, _ := ctx.Get("userId")
+	userRole, _ := ctx.Get("userRole")
+
+	// Call the service
+	revisions, err := c.ticketService.GetMessageAudit(uint(messageID), userID.(uint), userRole.(models.Role))
+	if err != nil {
+		logger.Error("Ticket Controller: Failed to get ticket message audit", map[string]interface{}{
+			"message_id": messageID,
+			"error":      err.Error(),
+		})
+		if err.Error() == "ticket message not found" || err.Error() == "ticket not found" {
+			utils.SendError(ctx, utils.CodeNotFound, dictionaries.TicketMessageNotFound, err)
+			return
+		}
+		utils.SendError(ctx, utils.CodeInternalError, utils.MsgInternalError, err)
+		return
+	}
+
+	if revisions == nil {
+		revisions = []models.TicketHistoryRevision{}
+	}
+
+	utils.SendSuccess(ctx, dictionaries.TicketMessageAuditFound, gin.H{
+		"revisions": revisions,
+	})
+}
+
+func (c *TicketController) AssignTicket(ctx *gin.Context) {
+	var request utils.AssignTicketRequest
+
+	// Bind request body to struct
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	userID, _ := ctx.Get("userId")
+	userRole, _ := ctx.Get("userRole")
+
+	// Call the service
+	err := c.ticketService.AssignTicket(request.TicketID, request.AssigneeEmail, userID.(uint), userRole.(models.Role))
+	if err != nil {
+		logger.Error("Ticket Controller: Failed to assign ticket", map[string]interface{}{
+			"ticket_id": request.TicketID,
+			"assignee":  request.AssigneeEmail,
+			"error":     err.Error(),
+		})
+		utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.TicketAssignFailed, err)
+		return
+	}
+
+	logger.Info("Ticket Controller: Ticket assigned successfully", map[string]interface{}{
+		"ticket_id": request.TicketID,
+		"assignee":  request.AssigneeEmail,
+	})
+
+	utils.SendSuccess(ctx, dictionaries.TicketAssigned, nil)
+}
+
+func (c *TicketController) UnassignTicket(ctx *gin.Context) {
+	var request utils.UnassignTicketRequest
+
+	// Bind request body to struct
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	userID, _ := ctx.Get("userId")
+	userRole, _ := ctx.Get("userRole")
+
+	// Call the service
+	err := c.ticketService.UnassignTicket(request.TicketID, userID.(uint), userRole.(models.Role))
+	if err != nil {
+		logger.Error("Ticket Controller: Failed to unassign ticket", map[string]interface{}{
+			"ticket_id": request.TicketID,
+			"error":     err.Error(),
+		})
+		utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.TicketUnassignFailed, err)
+		return
+	}
+
+	logger.Info("Ticket Controller: Ticket unassigned successfully", map[string]interface{}{
+		"ticket_id": request.TicketID,
+	})
+
+	utils.SendSuccess(ctx, dictionaries.TicketUnassigned, nil)
+}
+
+func (c *TicketController) GetWorkloads(ctx *gin.Context) {
+	userID, _ := ctx.Get("userId")
+	userRole, _ := ctx.Get("userRole")
+
+	workloads, unassigned, err := c.ticketService.GetWorkloads(userID.(uint), userRole.(models.Role))
+	if err != nil {
+		logger.Error("Ticket Controller: Failed to get agent workloads", map[string]interface{}{
+			"error": err.Error(),
+		})
+		utils.SendError(ctx, utils.CodeInternalError, utils.MsgInternalError, err)
+		return
+	}
+
+	if workloads == nil {
+		workloads = []models.AgentWorkload{}
+	}
+
+	utils.SendSuccess(ctx, dictionaries.WorkloadsListed, gin.H{
+		"agents":     workloads,
+		"unassigned": unassigned,
+	})
+}
+
+// GetMetrics computes the first response, resolution and backlog metrics of the helpdesk
+func (c *TicketController) GetMetrics(ctx *gin.Context) {
+	var query utils.TicketMetricsQuery
+
+	// Bind query parameters to struct
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	userID, _ := ctx.Get("userId")
+	userRole, _ := ctx.Get("userRole")
+
+	metrics, err := c.ticketService.GetMetrics(query, userID.(uint), userRole.(models.Role))
+	if err != nil {
+		if message := listingErrorMessage(err); message != "" {
+			utils.SendError(ctx, utils.CodeInvalidInput, message, err)
+			return
+		}
+
+		logger.Error("Ticket Controller: Failed to compute metrics", map[string]interface{}{
+			"group_by": query.GroupBy,
+			"error":    err.Error(),
+		})
+		utils.SendError(ctx, utils.CodeInternalError, utils.MsgInternalError, err)
+		return
+	}
+
+	utils.SendSuccess(ctx, dictionaries.MetricsComputed, gin.H{
+		"metrics": metrics,
+	})
+}
+
+func (c *TicketController) DeleteTicket(ctx *gin.Context) {
+	var request utils.RemoveTicketRequest
+
+	// Bind request body to struct
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	// Get user ID and role
+	userID, _ := ctx.Get("userId")
+	userRole, _ := ctx.Get("userRole")
+
+	// Call the service
+	err := c.ticketService.DeleteTicket(request.TicketID, userID.(uint), userRole.(models.Role))
+	if err != nil {
+		logger.Error("Ticket Controller: Failed to delete ticket", map[string]interface{}{
+			"ticket_id": request.TicketID,
+			"user_id":   userID,
+			"role":      userRole,
+			"error":     err.Error(),
+		})
+		utils.SendError(ctx, utils.CodeForbidden, dictionaries.NoPermissionToDelete, err)
+		return
+	}
+
+	logger.Info("Ticket Controller: Ticket deleted successfully", map[string]interface{}{
+		"ticket_id": request.TicketID,
+		"user_id":   userID,
+	})
+
+	utils.SendSuccess(ctx, dictionaries.TicketDeletedSuccess, nil)
+}
+
+// CountTicket gets the stored ticket counters, or the counters of a queue when queue_id is given
+func (c *TicketController) CountTicket(ctx *gin.Context) {
+	var count *models.Counters
+	var err error
+
+	if queueParam := ctx.Query("queue_id"); queueParam != "" {
+		queueID, parseErr := strconv.ParseUint(queueParam, 10, 64)
+		if parseErr != nil {
+			utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.QueueNotFound, parseErr)
+			return
+		}
+
+		userID, _ := ctx.Get("userId")
+		userRole, _ := ctx.Get("userRole")
+
+		count, err = c.ticketService.GetQueueCounters(uint(queueID), userID.(uint), userRole.(models.Role))
+		if err != nil {
+			switch err.Error() {
+			case "you don't have access to this queue":
+				utils.SendError(ctx, utils.CodeForbidden, dictionaries.QueueAccessDenied, err)
+				return
+			case "queue not found":
+				utils.SendError(ctx, utils.CodeNotFound, dictionaries.QueueNotFound, err)
+				return
+			}
+		}
+	} else {
+		count, err = c.ticketService.GetCounters()
+	}
+
+	if err != nil {
+		logger.Error("Ticket Controller: Failed to get ticket counters", map[string]interface{}{
+			"error": err.Error(),
+		})
+		utils.SendError(ctx, utils.CodeNotFound, dictionaries.TicketNotFound, err)
+		return
+	}
+
+	logger.Info("Ticket Controller: Ticket counters retrieved successfully", map[string]interface{}{
+		"total":    count.Total,
+		"pending":  count.Pending,
+		"doing":    count.Doing,
+		"conclued": count.Conclued,
+	})
+
+	utils.SendSuccess(ctx, "Ticket counters retrieved", gin.H{
+		"total":     count.Total,
+		"pending":   count.Pending,
+		"doing":     count.Doing,
+		"on_hold":   count.OnHold,
+		"conclued":  count.Conclued,
+		"cancelled": count.Cancelled,
+		"reopened":  count.Reopened,
+	})
+}
+
+// GetAuthorCounters gets the ticket counters of each author
+func (c *TicketController) GetAuthorCounters(ctx *gin.Context) {
+	c.sendUserCounters(ctx, models.AuthorCounters)
+}
+
+// GetAgentCounters gets the counters of the tickets assigned to each agent
+func (c *TicketController) GetAgentCounters(ctx *gin.Context) {
+	c.sendUserCounters(ctx, models.AssigneeCounters)
+}
+
+func (c *TicketController) sendUserCounters(ctx *gin.Context, scope models.CounterScope) {
+	userID, _ := ctx.Get("userId")
+	userRole, _ := ctx.Get("userRole")
+
+	counters, err := c.ticketService.GetUserCounters(scope, userID.(uint), userRole.(models.Role))
+	if err != nil {
+		logger.Error("Ticket Controller: Failed to get user counters", map[string]interface{}{
+			"scope": scope,
+			"error": err.Error(),
+		})
+		utils.SendError(ctx, utils.CodeInternalError, utils.MsgInternalError, err)
+		return
+	}
+
+	utils.SendSuccess(ctx, dictionaries.CountersListed, gin.H{
+		"counters": counters,
+	})
+}
+
+// RecomputeCounters rebuilds the ticket counters from the tickets
+func (c *TicketController) RecomputeCounters(ctx *gin.Context) {
+	if err := c.ticketService.RecomputeCounters(); err != nil {
+		logger.Error("Ticket Controller: Failed to recompute counters", map[string]interface{}{
+			"error": err.Error(),
+		})
+		utils.SendError(ctx, utils.CodeInternalError, dictionaries.CountersRecomputeFailed, err)
+		return
+	}
+
+	userID, _ := ctx.Get("userId")
+	logger.Info("Ticket Controller: Ticket counters recomputed", map[string]interface{}{
+		"user_id": userID,
+	})
+
+	utils.SendSuccess(ctx, dictionaries.CountersRecomputed, nil)
+}
+
+func (c *TicketController) GetMyTickets(ctx *gin.Context) {
+	var query utils.FetchMyTicketsQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	userID, _ := ctx.Get("userId")
+
+	// Call the service
+	tickets, page, err := c.ticketService.GetOwnTickets(userID.(uint), query)
+	if err != nil {
+		if services.IsPaginationError(err) {
+			utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.InvalidPagination, err)
+			return
+		}
+
+		if services.IsTicketFilterError(err) {
+			utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.InvalidTicketFilter, err)
+			return
+		}
+
+		logger.Error("Ticket Controller: Failed to get user tickets", map[string]interface{}{
+			"user_id": userID,
+			"status":  query.Status,
+			"error":   err.Error(),
+		})
+		utils.SendError(ctx, utils.CodeInternalError, utils.MsgInternalError, err)
+		return
+	}
+
+	logger.Info("Ticket Controller: User tickets retrieved successfully", map[string]interface{}{
+		"user_id": userID,
+		"count":   len(tickets),
+	})
+
+	// Convert to response format
+	responseTickets := make([]models.OwnerTicketResponse, len(tickets))
+	for i, ticket := range tickets {
+		responseTickets[i] = ticket.ToOwnerResponse()
+	}
+
+	utils.SendSuccess(ctx, dictionaries.TicketsListedSuccess, gin.H{
+		"tickets":    responseTickets,
+		"pagination": page,
+	})
+}
+
+func (c *TicketController) GetMyTicketDetails(ctx *gin.Context) {
+	ticketID := ctx.Query("ticket_id")
+	if ticketID == "" {
+		utils.SendError(ctx, utils.CodeInvalidInput, "Ticket ID is required", nil)
+		return
+	}
+
+	userID, _ := ctx.Get("userId")
+
+	// Call the service
+	ticket, err := c.ticketService.GetOwnTicketDetails(ticketID, userID.(uint))
+	if err != nil {
+		logger.Error("Ticket Controller: Failed to get user ticket details", map[string]interface{}{
+			"ticket_id": ticketID,
+			"user_id":   userID,
+			"error":     err.Error(),
+		})
+		utils.SendError(ctx, utils.CodeNotFound, dictionaries.TicketNotFound, err)
+		return
+	}
+
+	logger.Info("Ticket Controller: User ticket details retrieved successfully", map[string]interface{}{
+		"ticket_id": ticketID,
+		"user_id":   userID,
+	})
+
+	// The author email is only shown to admins
+	utils.SendSuccess(ctx, dictionaries.TicketFoundSuccess, gin.H{
+		"ticket": ticket.ToDetailedResponse(false),
+	})
+}
+
+func (c *TicketController) GetMyTicketImages(ctx *gin.Context) {
+	ticketID := ctx.Query("ticket_id")
+	if ticketID == "" {
+		utils.SendError(ctx, utils.CodeInvalidInput, "Ticket ID is required", nil)
+		return
+	}
+
+	userID, _ := ctx.Get("userId")
+
+	// Call the service
+	images, err := c.ticketService.GetOwnTicketImages(ticketID, userID.(uint))
+	if err != nil {
+		logger.Error("Ticket Controller: Failed to get user ticket images", map[string]interface{}{
+			"ticket_id": ticketID,
+			"user_id":   userID,
+			"error":     err.Error(),
+		})
+		utils.SendError(ctx, utils.CodeNotFound, dictionaries.TicketNotFound, err)
+		return
+	}
+
+	if images == nil {
+		images = []models.Image{}
+	}
+
+	utils.SendSuccess(ctx, "Ticket images found", gin.H{
+		"images": images,
+	})
+}
+
+// DownloadImage streams the content of a ticket image, to agents or to the author of the ticket
+func (c *TicketController) DownloadImage(ctx *gin.Context) {
+	imageID := ctx.Query("image_id")
+	if imageID == "" {
+		utils.SendError(ctx, utils.CodeInvalidInput, "Image ID is required", nil)
+		return
+	}
+
+	userID, _ := ctx.Get("userId")
+	userRole, _ := ctx.Get("userRole")
+
+	// Call the service
+	image, content, err := c.ticketService.OpenImage(imageID, userID.(uint), userRole.(models.Role))
+	if err != nil {
+		logger.Error("Ticket Controller: Failed to open ticket image", map[string]interface{}{
+			"image_id": imageID,
+			"user_id":  userID,
+			"error":    err.Error(),
+		})
+		utils.SendError(ctx, utils.CodeNotFound, dictionaries.ImageNotFound, err)
+		return
+	}
+	defer content.Close()
+
+	ctx.DataFromReader(http.StatusOK, image.Size, image.ContentType, content, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("inline", map[string]string{"filename": image.Name}),
+		"Cache-Control":       "private, max-age=0",
+	})
+}
+
+func (c *TicketController) GetMyTicketHistory(ctx *gin.Context) {
+	ticketID := ctx.Query("ticket_id")
+	if ticketID == "" {
+		utils.SendError(ctx, utils.CodeInvalidInput, "Ticket ID is required", nil)
+		return
+	}
+
+	userID, _ := ctx.Get("userId")
+
+	// Call the service
+	history, err := c.ticketService.GetOwnTicketHistory(ticketID, userID.(uint))
+	if err != nil {
+		logger.Error("Ticket Controller: Failed to get user ticket history", map[string]interface{}{
+			"ticket_id": ticketID,
+			"user_id":   userID,
+			"error":     err.Error(),
+		})
+		utils.SendError(ctx, utils.CodeNotFound, dictionaries.TicketNotFound, err)
+		return
+	}
+
+	if history == nil {
+		history = []models.TicketHistory{}
+	}
+
+	utils.SendSuccess(ctx, "Ticket history found", gin.H{
+		"history": history,
+	})
+}
+
+func (c *TicketController) AddTicketTags(ctx *gin.Context) {
+	var request utils.TicketTagsRequest
+
+	// Bind request body to struct
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	userID, _ := ctx.Get("userId")
+	userRole, _ := ctx.Get("userRole")
+
+	// Call the service
+	if err := c.ticketService.AddTicketTags(request.TicketID, request.Tags, userID.(uint), userRole.(models.Role)); err != nil {
+		logger.Error("Ticket Controller: Failed to add ticket tags", map[string]interface{}{
+			"ticket_id": request.TicketID,
+			"tags":      request.Tags,
+			"error":     err.Error(),
+		})
+		sendTagError(ctx, err, dictionaries.TicketTagsFailed)
+		return
+	}
+
+	logger.Info("Ticket Controller: Ticket tags added successfully", map[string]interface{}{
+		"ticket_id": request.TicketID,
+		"tags":      request.Tags,
+	})
+
+	utils.SendSuccess(ctx, dictionaries.TicketTagsAdded, nil)
+}
+
+func (c *TicketController) RemoveTicketTags(ctx *gin.Context) {
+	var request utils.TicketTagsRequest
+
+	// Bind request body to struct
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	userID, _ := ctx.Get("userId")
+	userRole, _ := ctx.Get("userRole")
+
+	// Call the service
+	if err := c.ticketService.RemoveTicketTags(request.TicketID, request.Tags, userID.(uint), userRole.(models.Role)); err != nil {
+		logger.Error("Ticket Controller: Failed to remove ticket tags", map[string]interface{}{
+			"ticket_id": request.TicketID,
+			"tags":      request.Tags,
+			"error":     err.Error(),
+		})
+		sendTagError(ctx, err, dictionaries.TicketTagsFailed)
+		return
+	}
+
+	logger.Info("Ticket Controller: Ticket tags removed successfully", map[string]interface{}{
+		"ticket_id": request.TicketID,
+		"tags":      request.Tags,
+	})
+
+	utils.SendSuccess(ctx, dictionaries.TicketTagsRemoved, nil)
+}
+
+// EditTicketContent changes the name and explanation of a ticket, keeping the previous content as a revision
+func (c *TicketController) EditTicketContent(ctx *gin.Context) {
+	var request utils.EditTicketContentRequest
+
+	// Bind request body to struct
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	if request.Name == nil && request.Explanation == nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, "Ticket name or explanation is required", nil)
+		return
+	}
+
+	userID, _ := ctx.Get("userId")
+	userRole, _ := ctx.Get("userRole")
+
+	// Call the service
+	err := c.ticketService.EditTicketContent(request.TicketID, request.Name, request.Explanation, userID.(uint), userRole.(models.Role))
+	if err != nil {
+		logger.Error("Ticket Controller: Failed to edit ticket content", map[string]interface{}{
+			"ticket_id": request.TicketID,
+			"user_id":   userID,
+			"error":     err.Error(),
+		})
+		switch err.Error() {
+		case "ticket not found":
+			utils.SendError(ctx, utils.CodeNotFound, dictionaries.TicketNotFound, err)
+		case "ticket can only be edited while pending", "ticket changed while it was being edited":
+			utils.SendError(ctx, utils.CodeForbidden, dictionaries.TicketNotEditable, err)
+		default:
+			utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.TicketContentEditFailed, err)
+		}
+		return
+	}
+
+	logger.Info("Ticket Controller: Ticket content edited successfully", map[string]interface{}{
+		"ticket_id": request.TicketID,
+		"user_id":   userID,
+	})
+
+	utils.SendSuccess(ctx, dictionaries.TicketContentEdited, nil)
 }
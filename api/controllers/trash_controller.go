@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+
+	"hcall/api/dictionaries"
+	"hcall/api/logger"
+	"hcall/api/models"
+	"hcall/api/services"
+	"hcall/api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TrashController struct {
+	trashService *services.TrashService
+}
+
+func NewTrashController() *TrashController {
+	return &TrashController{
+		trashService: services.NewTrashService(),
+	}
+}
+
+// GetTrashedTickets lists the tickets in the trash
+func (c *TrashController) GetTrashedTickets(ctx *gin.Context) {
+	var query utils.PageQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	userID, _ := ctx.Get("userId")
+	userRole, _ := ctx.Get("userRole")
+
+	tickets, page, err := c.trashService.GetTrashedTickets(query, userID.(uint), userRole.(models.Role))
+	if err != nil {
+		if services.IsPaginationError(err) {
+			utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.InvalidPagination, err)
+			return
+		}
+
+		logger.Error("Trash Controller: Failed to list trashed tickets", map[string]interface{}{
+			"error": err.Error(),
+		})
+		utils.SendError(ctx, utils.CodeInternalError, utils.MsgInternalError, err)
+		return
+	}
+
+	responseTickets := make([]models.TrashedTicketResponse, len(tickets))
+	for i, ticket := range tickets {
+		username, err := c.trashService.GetUserUsername(ticket.AuthorID)
+		if err != nil {
+			username = "Unknown User"
+		}
+		responseTickets[i] = ticket.ToTrashedResponse(username)
+	}
+
+	utils.SendSuccess(ctx, dictionaries.TrashListedSuccess, gin.H{
+		"tickets":    responseTickets,
+		"pagination": page,
+	})
+}
+
+// GetTrashedUsers lists the users in the trash
+func (c *TrashController) GetTrashedUsers(ctx *gin.Context) {
+	var query utils.PageQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	users, page, err := c.trashService.GetTrashedUsers(query)
+	if err != nil {
+		if services.IsPaginationError(err) {
+			utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.InvalidPagination, err)
+			return
+		}
+
+		logger.Error("Trash Controller: Failed to list trashed users", map[string]interface{}{
+			"error": err.Error(),
+		})
+		utils.SendError(ctx, utils.CodeInternalError, utils.MsgInternalError, err)
+		return
+	}
+
+	responseUsers := make([]models.TrashedUserResponse, len(users))
+	for i, user := range users {
+		responseUsers[i] = user.ToTrashedResponse()
+	}
+
+	utils.SendSuccess(ctx, dictionaries.TrashListedSuccess, gin.H{
+		"users":      responseUsers,
+		"pagination": page,
+	})
+}
+
+// Restore brings a ticket or a user back from the trash
+func (c *TrashController) Restore(ctx *gin.Context) {
+	var request utils.TrashItemRequest
+
+	// Bind request body to struct
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	if (request.TicketID == "") == (request.Email == "") {
+		utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.TrashItemRequired, nil)
+		return
+	}
+
+	userID, _ := ctx.Get("userId")
+	userRole, _ := ctx.Get("userRole")
+
+	var err error
+	if request.TicketID != "" {
+		err = c.trashService.RestoreTicket(request.TicketID, userID.(uint), userRole.(models.Role))
+	} else {
+		err = c.trashService.RestoreUser(request.Email)
+	}
+
+	if err != nil {
+		logger.Error("Trash Controller: Failed to restore item", map[string]interface{}{
+			"ticket_id":  request.TicketID,
+			"user_email": request.Email,
+			"error":      err.Error(),
+		})
+		sendTrashError(ctx, err, dictionaries.TrashRestoreFailed)
+		return
+	}
+
+	logger.Info("Trash Controller: Item restored successfully", map[string]interface{}{
+		"ticket_id":  request.TicketID,
+		"user_email": request.Email,
+	})
+
+	utils.SendSuccess(ctx, dictionaries.TrashRestoredSuccess, nil)
+}
+
+// Purge permanently deletes a trashed ticket or user right away. Without a body it purges
+// everything that stayed in the trash past the retention.
+func (c *TrashController) Purge(ctx *gin.Context) {
+	var request utils.TrashItemRequest
+
+	// The body is optional
+	if err := ctx.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
+	if request.TicketID != "" && request.Email != "" {
+		utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.TrashItemRequired, nil)
+		return
+	}
+
+	var err error
+	var purgedTickets, purgedUsers int64
+	switch {
+	case request.TicketID != "":
+		err = c.trashService.PurgeTicket(request.TicketID)
+		purgedTickets = 1
+	case request.Email != "":
+		err = c.trashService.PurgeUser(request.Email)
+		purgedUsers = 1
+	default:
+		purgedTickets, purgedUsers, err = c.trashService.PurgeExpired()
+	}
+
+	if err != nil {
+		logger.Error("Trash Controller: Failed to purge trash", map[string]interface{}{
+			"ticket_id":  request.TicketID,
+			"user_email": request.Email,
+			"error":      err.Error(),
+		})
+		sendTrashError(ctx, err, dictionaries.TrashPurgeFailed)
+		return
+	}
+
+	logger.Info("Trash Controller: Trash purged successfully", map[string]interface{}{
+		"tickets": purgedTickets,
+		"users":   purgedUsers,
+	})
+
+	utils.SendSuccess(ctx, dictionaries.TrashPurgedSuccess, gin.H{
+		"purged_tickets": purgedTickets,
+		"purged_users":   purgedUsers,
+	})
+}
+
+// sendTrashError answers a failed trash operation
+func sendTrashError(ctx *gin.Context, err error, fallback string) {
+	switch err.Error() {
+	case "ticket not found in trash", "user not found in trash":
+		utils.SendError(ctx, utils.CodeNotFound, dictionaries.TrashItemNotFound, err)
+	case "cannot purge user with existing tickets":
+		utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.UserHasTickets, err)
+	default:
+		utils.SendError(ctx, utils.CodeInternalError, fallback, err)
+	}
+}
@@ -26,6 +26,11 @@ func NewUserController() *UserController {
 // @Produce json
 // @Param email query string false "User's email"
 // @Param role query string false "User's role"
+// @Param limit query int false "Page size"
+// @Param offset query int false "Rows to skip"
+// @Param cursor query string false "Next cursor of the previous page"
+// @Param sort query string false "created or updated"
+// @Param order query string false "asc or desc"
 // @Success 200 {object} utils.UserResponse
 // @Success 200 {object} utils.UsersListResponse
 // @Failure 404 {object} utils.MessageResponse
@@ -35,6 +40,12 @@ func (c *UserController) GetUsers(ctx *gin.Context) {
 	email := ctx.Query("email")
 	role := ctx.Query("role")
 
+	var pageQuery utils.PageQuery
+	if err := ctx.ShouldBindQuery(&pageQuery); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
+
 	// If email is provided, get specific user
 	if email != "" {
 		// If role is also provided, get user with specific role
@@ -80,8 +91,12 @@ func (c *UserController) GetUsers(ctx *gin.Context) {
 	// If role is provided, get users with specific role
 	if role != "" {
 		userRole := models.Role(role)
-		users, err := c.userService.GetUsersByRole(userRole)
+		users, page, err := c.userService.GetUsersByRole(userRole, pageQuery)
 		if err != nil {
+			if services.IsPaginationError(err) {
+				utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.InvalidPagination, err)
+				return
+			}
 			utils.SendError(ctx, utils.CodeNotFound, "No users found with specified role", err)
 			return
 		}
@@ -93,14 +108,19 @@ func (c *UserController) GetUsers(ctx *gin.Context) {
 		}
 
 		utils.SendSuccess(ctx, "Users found", gin.H{
-			"users": responseUsers,
+			"users":      responseUsers,
+			"pagination": page,
 		})
 		return
 	}
 
 	// Get all users
-	users, err := c.userService.GetUsers()
+	users, page, err := c.userService.GetUsers(pageQuery)
 	if err != nil {
+		if services.IsPaginationError(err) {
+			utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.InvalidPagination, err)
+			return
+		}
 		utils.SendError(ctx, utils.CodeInternalError, utils.MsgInternalError, err)
 		return
 	}
@@ -112,7 +132,8 @@ func (c *UserController) GetUsers(ctx *gin.Context) {
 	}
 
 	utils.SendSuccess(ctx, "Users found", gin.H{
-		"users": responseUsers,
+		"users":      responseUsers,
+		"pagination": page,
 	})
 }
 
@@ -144,23 +165,32 @@ func (c *UserController) CreateUser(ctx *gin.Context) {
 
 // DeleteUser handles user deletion
 // @Summary Delete a user
-// @Description Deletes a user from the system
+// @Description Moves a user to the trash, it can be restored until it is purged
+// @Accept json
 // @Produce json
-// @Param id path int true "User ID"
+// @Param body body utils.DeleteUserRequest true "User email"
 // @Success 200 {object} utils.MessageResponse
 // @Failure 404 {object} utils.MessageResponse
 // @Failure 500 {object} utils.MessageResponse
-// @Router /users/{id} [delete]
+// @Router /user/delete [post]
 func (c *UserController) DeleteUser(ctx *gin.Context) {
-	id := ctx.Param("id")
+	var request utils.DeleteUserRequest
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		utils.SendError(ctx, utils.CodeInvalidInput, utils.MsgInvalidInput, err)
+		return
+	}
 
-	err := c.userService.DeleteUser(id)
+	err := c.userService.DeleteUser(request.Email)
 	if err != nil {
-		if err.Error() == "user not found" {
+		switch err.Error() {
+		case "user not found":
 			utils.SendError(ctx, utils.CodeNotFound, dictionaries.UserNotFound, err)
-			return
+		case "cannot delete user with existing tickets":
+			utils.SendError(ctx, utils.CodeInvalidInput, dictionaries.UserHasTickets, err)
+		default:
+			utils.SendError(ctx, utils.CodeInternalError, utils.MsgInternalError, err)
 		}
-		utils.SendError(ctx, utils.CodeInternalError, utils.MsgInternalError, err)
 		return
 	}
 
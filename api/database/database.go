@@ -64,17 +64,47 @@ func runMigrations(db *gorm.DB) error {
 	// Drop the base64 column if it exists (this is safe because we're starting fresh)
 	// _ = db.Exec(`ALTER TABLE "images" DROP COLUMN IF EXISTS "base64"`).Error
 
+	// Users registered before emails were verified keep their access, only the first time the column is added
+	verifyExisting := db.Migrator().HasTable(&models.User{}) && !db.Migrator().HasColumn(&models.User{}, "EmailVerifiedAt")
+
 	// Run GORM migrations to create tables and add the base64 column properly
 	err := db.AutoMigrate(
 		&models.User{},
+		&models.Queue{},
+		&models.QueueMember{},
+		&models.Tag{},
 		&models.Ticket{},
 		&models.Counters{},
 		&models.Image{},
 		&models.TicketHistory{},
+		&models.TicketStatusChange{},
+		&models.TicketHistoryRevision{},
+		&models.TicketRevision{},
+		&models.ArchivedTicket{},
+		&models.Session{},
+		&models.RevokedToken{},
+		&models.EmailToken{},
+		&models.EmailRequest{},
+		&models.UserMFA{},
+		&models.RecoveryCode{},
+		&models.MFAChallenge{},
+		&models.APIKey{},
+		&models.OIDCState{},
+		&models.UserIdentity{},
 	)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	if verifyExisting {
+		if err := db.Exec("UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL").Error; err != nil {
+			return err
+		}
+	}
+
+	if err := migrateTicketSearch(db); err != nil {
+		return err
+	}
+
+	return migrateTicketMetrics(db)
 }
@@ -0,0 +1,19 @@
+package database
+
+import (
+	"hcall/api/models"
+
+	"gorm.io/gorm"
+)
+
+// migrateTicketMetrics fills the resolution time of the tickets closed before it was recorded, from
+// the last time their status log closed them or from their last update when there is no log
+func migrateTicketMetrics(db *gorm.DB) error {
+	return db.Exec(`UPDATE tickets SET resolved_at = COALESCE(
+	(SELECT MAX(changes.created_at) FROM ticket_status_changes changes
+		WHERE changes.ticket_id = tickets.id AND changes.to_status IN @closed),
+	tickets.updated_at)
+WHERE resolved_at IS NULL AND status IN @closed`, map[string]interface{}{
+		"closed": models.ClosedStatuses,
+	}).Error
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"fmt"
+
+	"hcall/api/config"
+
+	"gorm.io/gorm"
+)
+
+// migrateTicketSearch creates the full-text search column of the tickets and the triggers keeping it
+// up to date. The document of a ticket is made of its name, its explanation and its conversation,
+// weighted in this order.
+func migrateTicketSearch(db *gorm.DB) error {
+	language := config.AppConfig.SearchLanguage
+
+	statements := []string{
+		`ALTER TABLE tickets ADD COLUMN IF NOT EXISTS search_vector tsvector`,
+		`CREATE INDEX IF NOT EXISTS idx_tickets_search_vector ON tickets USING GIN (search_vector)`,
+
+		fmt.Sprintf(`CREATE OR REPLACE FUNCTION ticket_search_document(ticket_id varchar, name text, explanation text)
+RETURNS tsvector AS $$
+	SELECT setweight(to_tsvector('%[1]s', coalesce(name, '')), 'A') ||
+		setweight(to_tsvector('%[1]s', coalesce(explanation, '')), 'B') ||
+		setweight(to_tsvector('%[1]s', coalesce((
+			SELECT string_agg(h.message, ' ')
+			FROM ticket_histories h
+			WHERE h.ticket_id = ticket_search_document.ticket_id AND h.deleted_at IS NULL
+		), '')), 'C')
+$$ LANGUAGE sql STABLE`, language),
+
+		`CREATE OR REPLACE FUNCTION tickets_search_vector_update() RETURNS trigger AS $$
+BEGIN
+	NEW.search_vector := ticket_search_document(NEW.id, NEW.name, NEW.explanation);
+	RETURN NEW;
+END
+$$ LANGUAGE plpgsql`,
+
+		`CREATE OR REPLACE FUNCTION ticket_histories_search_vector_update() RETURNS trigger AS $$
+BEGIN
+	UPDATE tickets SET search_vector = ticket_search_document(id, name, explanation)
+	WHERE id = CASE WHEN TG_OP = 'DELETE' THEN OLD.ticket_id ELSE NEW.ticket_id END;
+	RETURN NULL;
+END
+$$ LANGUAGE plpgsql`,
+
+		`DROP TRIGGER IF EXISTS tickets_search_vector ON tickets`,
+		`CREATE TRIGGER tickets_search_vector BEFORE INSERT OR UPDATE OF name, explanation ON tickets
+FOR EACH ROW EXECUTE FUNCTION tickets_search_vector_update()`,
+
+		`DROP TRIGGER IF EXISTS ticket_histories_search_vector ON ticket_histories`,
+		`CREATE TRIGGER ticket_histories_search_vector AFTER INSERT OR UPDATE OF message, deleted_at OR DELETE ON ticket_histories
+FOR EACH ROW EXECUTE FUNCTION ticket_histories_search_vector_update()`,
+
+		// Fill the tickets created before the column existed
+		`UPDATE tickets SET search_vector = ticket_search_document(id, name, explanation) WHERE search_vector IS NULL`,
+	}
+
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -19,6 +19,96 @@ const (
 	InvalidMasterPassword = "Invalid master password"
 )
 
+// Session messages
+const (
+	// Success
+	TokenRefreshedSuccess  = "Session refreshed successfully"
+	LoggedOutSuccess       = "Logged out successfully"
+	SessionsRetrieved      = "Sessions retrieved successfully"
+	SessionsRevokedSuccess = "Sessions revoked successfully"
+
+	// Error
+	InvalidRefreshToken        = "Invalid or expired refresh token"
+	RefreshTokenReused         = "Refresh token was already used, the session has been revoked"
+	SessionNotFound            = "Session not found"
+	CannotRevokeMasterSessions = "Only a master can revoke the sessions of a master"
+)
+
+// Email verification and password reset messages
+const (
+	// Success
+	VerificationEmailRequired = "Registration successful, check your email to verify it before logging in"
+	VerificationEmailSent     = "If the email is registered and not verified yet, a verification email was sent"
+	EmailVerifiedSuccess      = "Email verified successfully"
+	PasswordResetEmailSent    = "If the email is registered, a password reset email was sent"
+	PasswordResetSuccess      = "Password reset successfully, log in with the new password"
+
+	// Error
+	EmailNotVerified     = "Email not verified, check your email or ask for a new verification email"
+	InvalidEmailToken    = "Invalid or expired link"
+	TooManyEmailRequests = "Too many emails asked for this address, try again later"
+	PasswordResetFailed  = "Password reset failed"
+)
+
+// Two-factor authentication messages
+const (
+	// Success
+	MFACodeRequired          = "Enter the code of your authenticator app to log in"
+	MFAEnrollmentRequired    = "Your role requires two-factor authentication, set it up to log in"
+	MFASetupCreated          = "Add the secret to your authenticator app, then confirm with a code"
+	MFAEnabledSuccess        = "Two-factor authentication enabled, keep the recovery codes somewhere safe"
+	MFAStatusRetrieved       = "Two-factor authentication status retrieved successfully"
+	RecoveryCodesRegenerated = "Recovery codes regenerated, the previous ones can't be used anymore"
+	MFADisabledSuccess       = "Two-factor authentication disabled successfully"
+	MFAResetSuccess          = "Two-factor authentication reset successfully"
+
+	// Error
+	InvalidMFAToken      = "Invalid or expired login, log in again"
+	InvalidMFACode       = "Invalid code"
+	TooManyMFAAttempts   = "Too many wrong codes, try again later"
+	MFAAlreadyEnabled    = "Two-factor authentication is already enabled"
+	MFANotSetUp          = "Set up two-factor authentication first"
+	MFANotEnabled        = "Two-factor authentication is not enabled"
+	MFARequiredForRole   = "Your role requires two-factor authentication"
+	CannotResetMasterMFA = "Only a master can reset the two-factor authentication of a master"
+)
+
+// Single sign-on messages
+const (
+	// Success
+	OIDCLoginStarted = "Send the user to the authorization URL of the identity provider"
+
+	// Error
+	OIDCNotEnabled          = "Single sign-on is not enabled"
+	OIDCProviderUnavailable = "The identity provider can't be reached, try again later"
+	InvalidOIDCState        = "Invalid or expired single sign-on login, start again"
+	OIDCLoginFailed         = "The identity provider refused the login"
+	OIDCEmailNotVerified    = "Your email isn't verified by the identity provider"
+	OIDCEmailMissing        = "The identity provider didn't give your email"
+	OIDCNoRole              = "Your groups at the identity provider don't give access to HCall"
+	OIDCProvisioningOff     = "Your account doesn't exist yet, ask an administrator to create it"
+	OIDCAccountDisabled     = "Your account is disabled"
+	OIDCLoginNotAllowed     = "This account can't log in with single sign-on"
+	PasswordLoginDisabled   = "Password login is disabled for your domain, log in with single sign-on"
+)
+
+// Service account messages
+const (
+	// Success
+	ServiceAccountCreated = "Service account created successfully"
+	ServiceAccountsListed = "Service accounts listed successfully"
+	APIKeyCreated         = "API key created, store it now as it won't be shown again"
+	APIKeysListed         = "API keys listed successfully"
+	APIKeyRevoked         = "API key revoked successfully"
+
+	// Error
+	ServiceAccountNotFound = "Service account not found"
+	InvalidServiceAccount  = "Invalid service account name or role"
+	InvalidAPIKeyScope     = "Invalid API key scope, use <area>:read or <area>:write"
+	InvalidAPIKeyName      = "API key name is required"
+	APIKeyNotFound         = "API key not found or already revoked"
+)
+
 // User messages
 const (
 	// Success
@@ -35,26 +125,53 @@ const (
 // Ticket messages
 const (
 	// Success
-	TicketCreatedSuccess = "Ticket created successfully"
-	TicketDeletedSuccess = "Ticket deleted successfully"
-	TicketStatusUpdated  = "Ticket status updated successfully"
-	TicketHistoryAdded   = "Ticket history updated successfully"
-	TicketFoundSuccess   = "Ticket found successfully"
-	TicketsListedSuccess = "Tickets listed successfully"
+	TicketCreatedSuccess    = "Ticket created successfully"
+	TicketDeletedSuccess    = "Ticket deleted successfully"
+	TicketStatusUpdated     = "Ticket status updated successfully"
+	TicketHistoryAdded      = "Ticket history updated successfully"
+	TicketFoundSuccess      = "Ticket found successfully"
+	TicketsListedSuccess    = "Tickets listed successfully"
+	TicketSearchSuccess     = "Ticket search completed"
+	TicketAssigned          = "Ticket assigned successfully"
+	TicketPriorityUpdated   = "Ticket priority updated successfully"
+	TicketTimelineFound     = "Ticket status timeline found"
+	TicketMessageEdited     = "Ticket message edited successfully"
+	TicketMessageDeleted    = "Ticket message deleted successfully"
+	TicketMessageAuditFound = "Ticket message audit found"
+	TicketUnassigned        = "Ticket unassigned successfully"
+	WorkloadsListed         = "Agent workloads listed successfully"
+	TicketContentEdited     = "Ticket content edited successfully"
+	CountersListed          = "Ticket counters listed successfully"
+	CountersRecomputed      = "Ticket counters recomputed successfully"
+	MetricsComputed         = "Helpdesk metrics computed successfully"
 
 	// Error
-	TicketCreationFailed     = "Failed to create ticket"
-	TicketDeletionFailed     = "Failed to delete ticket"
-	TicketNotFound           = "Ticket not found"
-	TicketStatusUpdateFailed = "Failed to update ticket status"
-	TicketHistoryAddFailed   = "Failed to add ticket history"
-	NoTicketsFound           = "No tickets found"
-	NoTicketsForAuthor       = "Author has no tickets"
-	NoTicketsForStatus       = "No tickets found with specified status"
-	InvalidTicketStatus      = "Invalid ticket status"
-	InvalidTicketData        = "Invalid ticket data"
-	NoPermissionToDelete     = "You don't have permission to delete this ticket"
-	InvalidDateFormat        = "Invalid date format"
+	TicketCreationFailed       = "Failed to create ticket"
+	TicketDeletionFailed       = "Failed to delete ticket"
+	TicketNotFound             = "Ticket not found"
+	TicketStatusUpdateFailed   = "Failed to update ticket status"
+	TicketHistoryAddFailed     = "Failed to add ticket history"
+	NoTicketsFound             = "No tickets found"
+	NoTicketsForAuthor         = "Author has no tickets"
+	NoTicketsForStatus         = "No tickets found with specified status"
+	InvalidTicketStatus        = "Invalid ticket status"
+	InvalidTicketData          = "Invalid ticket data"
+	NoPermissionToDelete       = "You don't have permission to delete this ticket"
+	InvalidDateFormat          = "Invalid date format"
+	TicketAssignFailed         = "Failed to assign ticket"
+	TicketPriorityUpdateFailed = "Failed to update ticket priority"
+	TicketMessageNotFound      = "Ticket message not found"
+	TicketMessageEditFailed    = "Failed to edit ticket message"
+	TicketMessageDeleteFailed  = "Failed to delete ticket message"
+	TicketUnassignFailed       = "Failed to unassign ticket"
+	InvalidAssignedFilter      = "Invalid assigned filter"
+	InvalidTicketFilter        = "Invalid ticket filter"
+	SearchTextRequired         = "Search text is required"
+	TicketContentEditFailed    = "Failed to edit ticket content"
+	TicketNotEditable          = "Ticket can only be edited while pending"
+	CountersRecomputeFailed    = "Failed to recompute ticket counters"
+	InvalidDateRange           = "Invalid date range"
+	InvalidExportColumns       = "Invalid export columns"
 )
 
 // Image messages
@@ -63,10 +180,96 @@ const (
 	ImageUploadedSuccess = "Image uploaded successfully"
 
 	// Error
+	ImageNotFound       = "Image not found"
 	ImageUploadFailed   = "Failed to upload image"
 	InvalidImageFormat  = "Invalid image format"
 	InvalidImageContent = "Invalid image content"
 	ImageTooLarge       = "Image too large"
+	TooManyImages       = "Too many images"
+)
+
+// Tag messages
+const (
+	// Success
+	TagsListedSuccess = "Tags listed successfully"
+	TicketTagsAdded   = "Ticket tags added successfully"
+	TicketTagsRemoved = "Ticket tags removed successfully"
+	TagRenamed        = "Tag renamed successfully"
+	TagsMerged        = "Tags merged successfully"
+
+	// Error
+	TagNotFound      = "Tag not found"
+	TagAlreadyExists = "Tag already exists"
+	InvalidTagName   = "Invalid tag name"
+	TicketTagsFailed = "Failed to update ticket tags"
+	TagRenameFailed  = "Failed to rename tag"
+	TagMergeFailed   = "Failed to merge tags"
+)
+
+// Queue messages
+const (
+	// Success
+	QueueCreatedSuccess = "Queue created successfully"
+	QueueDeletedSuccess = "Queue deleted successfully"
+	QueuesListedSuccess = "Queues listed successfully"
+	QueueMemberAdded    = "Queue member added successfully"
+	QueueMemberRemoved  = "Queue member removed successfully"
+	QueueMembersListed  = "Queue members listed successfully"
+	QueueFieldsUpdated  = "Queue custom fields updated successfully"
+
+	// Error
+	QueueNotFound       = "Queue not found"
+	QueueAlreadyExists  = "Queue already exists"
+	QueueCreationFailed = "Failed to create queue"
+	QueueDeletionFailed = "Failed to delete queue"
+	QueueNotEmpty       = "Queue still has tickets"
+	QueueMemberFailed   = "Failed to update queue members"
+	QueueAccessDenied   = "You don't have access to this queue"
+	InvalidFieldSchema  = "Invalid custom field schema"
+	InvalidCustomFields = "Invalid custom fields"
+)
+
+// Archive messages
+const (
+	// Success
+	ArchiveSearchSuccess   = "Archive search completed"
+	ArchivedTicketFound    = "Archived ticket found"
+	ArchivedTicketImported = "Archived ticket imported successfully"
+
+	// Error
+	ArchivedTicketNotFound = "Ticket not found in archive"
+	ArchiveFileUnavailable = "Archive file unavailable"
+	ArchiveAuthorNotFound  = "Author of the archived ticket is not registered"
+	TicketAlreadyExists    = "Ticket already exists"
+	ArchiveImportFailed    = "Failed to import archived ticket"
+)
+
+// Import messages
+const (
+	// Success
+	TicketsImported    = "Tickets imported"
+	ImportDryRunPassed = "Import file checked, nothing was imported"
+
+	// Error
+	ImportFileRequired = "Import file is required"
+	ImportFileTooLarge = "Import file is too large"
+	InvalidImportFile  = "Invalid import file"
+	TicketImportFailed = "Failed to import tickets"
+)
+
+// Trash messages
+const (
+	// Success
+	TrashListedSuccess   = "Trash listed successfully"
+	TrashRestoredSuccess = "Item restored successfully"
+	TrashPurgedSuccess   = "Trash purged successfully"
+
+	// Error
+	TrashItemNotFound  = "Item not found in trash"
+	TrashItemRequired  = "Either a ticket or a user must be given"
+	TrashRestoreFailed = "Failed to restore item"
+	TrashPurgeFailed   = "Failed to purge trash"
+	UserHasTickets     = "User still has tickets"
 )
 
 // General messages
@@ -82,6 +285,7 @@ const (
 	MissingRequiredField = "Required field not provided"
 	InvalidFieldValue    = "Invalid field value"
 	RouteNotFound        = "Route not found"
+	InvalidPagination    = "Invalid pagination parameters"
 )
 
 // Validation messages
@@ -0,0 +1,25 @@
+package mailer
+
+import (
+	"context"
+
+	"hcall/api/logger"
+)
+
+// LogMailer writes the emails to the logs instead of sending them, for development only as the
+// links of the emails end up in the logs
+type LogMailer struct{}
+
+// Send logs the message
+func (m *LogMailer) Send(ctx context.Context, message Message) error {
+	if err := checkHeader(message.To); err != nil {
+		return err
+	}
+
+	logger.Info("Mailer: Email not sent, log driver", map[string]interface{}{
+		"to":      message.To,
+		"subject": message.Subject,
+		"body":    message.Body,
+	})
+	return nil
+}
@@ -0,0 +1,68 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"hcall/api/config"
+	"hcall/api/logger"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers emails
+type Mailer interface {
+	// Send delivers the message, giving up when the context is done
+	Send(ctx context.Context, message Message) error
+}
+
+var Default Mailer
+
+// InitMailer initializes the mailer selected by MAIL_DRIVER
+func InitMailer() {
+	mailer, err := NewMailer(config.AppConfig)
+	if err != nil {
+		logger.Fatal("Mailer: Failed to initialize mailer", map[string]interface{}{
+			"driver": config.AppConfig.MailDriver,
+			"error":  err.Error(),
+		})
+	}
+
+	Default = mailer
+	logger.Info("Mailer: Mailer initialized successfully", map[string]interface{}{
+		"driver": config.AppConfig.MailDriver,
+	})
+}
+
+// NewMailer creates the mailer described by the configuration
+func NewMailer(cfg config.Config) (Mailer, error) {
+	switch cfg.MailDriver {
+	case "smtp":
+		return NewSMTPMailer(SMTPOptions{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.MailFrom,
+		})
+	case "log":
+		return &LogMailer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.MailDriver)
+	}
+}
+
+// checkHeader refuses the header values that would add lines to the headers of a message
+func checkHeader(value string) error {
+	if strings.ContainsAny(value, "\r\n") {
+		return errors.New("invalid mail header")
+	}
+	return nil
+}
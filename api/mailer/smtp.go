@@ -0,0 +1,154 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SMTPOptions struct {
+	Host     string
+	Port     int
+	Username string // no authentication when empty, e.g. for a local mail catcher like MailHog
+	Password string
+	From     string // e.g. HCall <no-reply@example.com>
+}
+
+// SMTPMailer sends emails through an SMTP server, upgrading the connection with STARTTLS when the
+// server offers it
+type SMTPMailer struct {
+	options SMTPOptions
+	from    *mail.Address
+}
+
+func NewSMTPMailer(options SMTPOptions) (*SMTPMailer, error) {
+	if options.Host == "" || options.Port <= 0 {
+		return nil, errors.New("SMTP_HOST and SMTP_PORT are required")
+	}
+
+	from, err := mail.ParseAddress(options.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAIL_FROM %q", options.From)
+	}
+
+	return &SMTPMailer{
+		options: options,
+		from:    from,
+	}, nil
+}
+
+// Send delivers the message to the SMTP server
+func (m *SMTPMailer) Send(ctx context.Context, message Message) error {
+	if err := checkHeader(message.To); err != nil {
+		return err
+	}
+
+	to, err := mail.ParseAddress(message.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient %q", message.To)
+	}
+
+	data, err := m.build(to, message)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(m.options.Host, strconv.Itoa(m.options.Port))
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(time.Minute)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, m.options.Host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.options.Host}); err != nil {
+			return err
+		}
+	}
+
+	if m.options.Username != "" {
+		auth := smtp.PlainAuth("", m.options.Username, m.options.Password, m.options.Host)
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(m.from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return err
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(data); err != nil {
+		writer.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// build writes the headers and the quoted-printable body of the message
+func (m *SMTPMailer) build(to *mail.Address, message Message) ([]byte, error) {
+	domain := m.from.Address[strings.LastIndex(m.from.Address, "@")+1:]
+
+	var data bytes.Buffer
+	headers := [][2]string{
+		{"From", m.from.String()},
+		{"To", to.String()},
+		{"Subject", mime.QEncoding.Encode("utf-8", message.Subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", fmt.Sprintf("<%s@%s>", uuid.NewString(), domain)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "text/plain; charset=UTF-8"},
+		{"Content-Transfer-Encoding", "quoted-printable"},
+	}
+	for _, header := range headers {
+		fmt.Fprintf(&data, "%s: %s\r\n", header[0], header[1])
+	}
+	data.WriteString("\r\n")
+
+	// The writer ends the lines of the body with CRLF
+	body := quotedprintable.NewWriter(&data)
+	if _, err := body.Write([]byte(message.Body)); err != nil {
+		return nil, err
+	}
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+
+	return data.Bytes(), nil
+}
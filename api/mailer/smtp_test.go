@@ -0,0 +1,287 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// receivedMail is what the fake SMTP server got for a message
+type receivedMail struct {
+	from string
+	to   []string
+	data string
+}
+
+// fakeSMTP is an SMTP server accepting every message, without STARTTLS nor authentication
+type fakeSMTP struct {
+	listener net.Listener
+	mails    chan receivedMail
+	conns    chan struct{}
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	server := &fakeSMTP{
+		listener: listener,
+		mails:    make(chan receivedMail, 10),
+		conns:    make(chan struct{}, 10),
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server.conns <- struct{}{}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 fake.example.com ESMTP")
+
+	var mail receivedMail
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			text.PrintfLine("250 fake.example.com")
+		case "MAIL":
+			mail = receivedMail{from: strings.TrimPrefix(arg, "FROM:")}
+			text.PrintfLine("250 OK")
+		case "RCPT":
+			mail.to = append(mail.to, strings.TrimPrefix(arg, "TO:"))
+			text.PrintfLine("250 OK")
+		case "DATA":
+			text.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := readData(text.R)
+			if err != nil {
+				text.PrintfLine("500 %v", err)
+				return
+			}
+			mail.data = data
+			s.mails <- mail
+			text.PrintfLine("250 OK")
+		case "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+// readData reads the content of a message as sent, refusing the lines that don't end with CRLF
+func readData(reader *bufio.Reader) (string, error) {
+	var data strings.Builder
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		if !strings.HasSuffix(line, "\r\n") {
+			return "", errors.New("bare LF in message")
+		}
+		if line == ".\r\n" {
+			return data.String(), nil
+		}
+		data.WriteString(strings.TrimPrefix(line, "."))
+	}
+}
+
+func (s *fakeSMTP) mailer(t *testing.T) *SMTPMailer {
+	t.Helper()
+
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	m, err := NewSMTPMailer(SMTPOptions{
+		Host: host,
+		Port: portNumber,
+		From: "HCall <no-reply@example.com>",
+	})
+	if err != nil {
+		t.Fatalf("NewSMTPMailer: %v", err)
+	}
+	return m
+}
+
+func (s *fakeSMTP) received(t *testing.T) receivedMail {
+	t.Helper()
+
+	select {
+	case mail := <-s.mails:
+		return mail
+	case <-time.After(5 * time.Second):
+		t.Fatal("no mail received")
+		return receivedMail{}
+	}
+}
+
+func TestSMTPMailerSend(t *testing.T) {
+	server := newFakeSMTP(t)
+	m := server.mailer(t)
+
+	link := "https://helpdesk.example.com/verify-email?token=" + strings.Repeat("Ab3-_x", 12) + "=="
+	body := "Hello Jöhn,\n\nPlease verify your email by opening the link below:\n" + link + "\n\nThe link expires in 24 hours.\n"
+	err := m.Send(context.Background(), Message{
+		To:      "John Doe <johndoe@example.com>",
+		Subject: "Vérifiez votre email",
+		Body:    body,
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	received := server.received(t)
+	if received.from != "<no-reply@example.com>" {
+		t.Errorf("MAIL FROM %q, want <no-reply@example.com>", received.from)
+	}
+	if len(received.to) != 1 || received.to[0] != "<johndoe@example.com>" {
+		t.Errorf("RCPT TO %q, want only <johndoe@example.com>", received.to)
+	}
+
+	message, err := mail.ReadMessage(strings.NewReader(received.data))
+	if err != nil {
+		t.Fatalf("reading the message: %v", err)
+	}
+
+	headers := map[string]string{
+		"From":                      `"HCall" <no-reply@example.com>`,
+		"To":                        `"John Doe" <johndoe@example.com>`,
+		"Mime-Version":              "1.0",
+		"Content-Type":              "text/plain; charset=UTF-8",
+		"Content-Transfer-Encoding": "quoted-printable",
+	}
+	for name, want := range headers {
+		if got := message.Header.Get(name); got != want {
+			t.Errorf("header %s is %q, want %q", name, got, want)
+		}
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+	if err != nil || subject != "Vérifiez votre email" {
+		t.Errorf("subject decodes to %q (%v), want %q", subject, err, "Vérifiez votre email")
+	}
+
+	if _, err := message.Header.Date(); err != nil {
+		t.Errorf("invalid Date header: %v", err)
+	}
+	if id := message.Header.Get("Message-Id"); !strings.HasPrefix(id, "<") || !strings.HasSuffix(id, "@example.com>") {
+		t.Errorf("Message-ID %q isn't on the domain of the sender", id)
+	}
+
+	raw, err := io.ReadAll(message.Body)
+	if err != nil {
+		t.Fatalf("reading the body: %v", err)
+	}
+
+	// The body is encoded: the equal signs of the link are escaped and its line is folded
+	if strings.Contains(string(raw), link) {
+		t.Error("the link is not quoted-printable encoded")
+	}
+	if !strings.Contains(string(raw), "=3D") {
+		t.Error("the equal signs of the link are not escaped")
+	}
+	if strings.Contains(strings.ReplaceAll(string(raw), "\r\n", ""), "\n") {
+		t.Error("body lines don't end with CRLF")
+	}
+	for _, line := range strings.Split(string(raw), "\r\n") {
+		if len(line) > 76 {
+			t.Errorf("encoded line longer than 76 characters: %q", line)
+		}
+	}
+
+	decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(string(raw))))
+	if err != nil {
+		t.Fatalf("decoding the body: %v", err)
+	}
+	if want := strings.ReplaceAll(body, "\n", "\r\n"); string(decoded) != want {
+		t.Errorf("body decodes to %q, want %q", decoded, want)
+	}
+
+	if lines := strings.Split(string(decoded), "\r\n"); len(lines) < 4 || lines[3] != link {
+		t.Errorf("link line decodes to %q, want %q", lines, link)
+	}
+}
+
+func TestSMTPMailerRefusesHeaderInjection(t *testing.T) {
+	server := newFakeSMTP(t)
+	m := server.mailer(t)
+
+	for _, to := range []string{
+		"johndoe@example.com\r\nBcc: attacker@example.com",
+		"johndoe@example.com\nBcc: attacker@example.com",
+		"John\r\n <johndoe@example.com>",
+	} {
+		err := m.Send(context.Background(), Message{To: to, Subject: "Reset your password", Body: "token"})
+		if err == nil || err.Error() != "invalid mail header" {
+			t.Errorf("Send to %q returned %v, want invalid mail header", to, err)
+		}
+	}
+
+	select {
+	case <-server.conns:
+		t.Error("the mailer connected to the server for a refused recipient")
+	default:
+	}
+}
+
+func TestSMTPMailerEncodesSubjectLines(t *testing.T) {
+	server := newFakeSMTP(t)
+	m := server.mailer(t)
+
+	err := m.Send(context.Background(), Message{
+		To:      "johndoe@example.com",
+		Subject: "Hello\r\nBcc: attacker@example.com",
+		Body:    "token",
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	received := server.received(t)
+	if len(received.to) != 1 {
+		t.Errorf("RCPT TO %q, want only the recipient", received.to)
+	}
+
+	message, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(received.data)))
+	if err != nil {
+		t.Fatalf("reading the message: %v", err)
+	}
+	if bcc := message.Header.Get("Bcc"); bcc != "" {
+		t.Errorf("the subject added a Bcc header %q", bcc)
+	}
+	if subject := message.Header.Get("Subject"); !strings.HasPrefix(subject, "=?utf-8?q?") {
+		t.Errorf("subject %q is not encoded", subject)
+	}
+}
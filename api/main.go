@@ -8,11 +8,15 @@ import (
 	"syscall"
 	"time"
 
+	"hcall/api/commands"
 	"hcall/api/config"
 	"hcall/api/database"
 	"hcall/api/logger"
+	"hcall/api/mailer"
 	"hcall/api/middlewares"
+	"hcall/api/oidc"
 	"hcall/api/routes"
+	"hcall/api/storage"
 	"hcall/api/utils"
 
 	"github.com/gin-gonic/gin"
@@ -38,6 +42,26 @@ func main() {
 	// Initialize database
 	database.InitDB()
 
+	// Initialize blob store
+	storage.InitStore()
+
+	// Initialize mailer
+	mailer.InitMailer()
+
+	// Initialize single sign-on
+	oidc.InitOIDC()
+
+	// Run a maintenance command instead of the server when one is given
+	if len(os.Args) > 1 {
+		if err := commands.Run(os.Args[1:]); err != nil {
+			logger.Fatal("Main: Command failed", map[string]interface{}{
+				"command": os.Args[1],
+				"error":   err.Error(),
+			})
+		}
+		return
+	}
+
 	// Create Gin router
 	router := gin.Default()
 
@@ -2,18 +2,30 @@ package middlewares
 
 import (
 	"fmt"
+	"net/http"
 	"strings"
 
 	"hcall/api/logger"
 	"hcall/api/models"
+	"hcall/api/repository"
+	"hcall/api/services"
 	"hcall/api/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware verifies the JWT token in the Authorization header
+// AuthMiddleware verifies the JWT token in the Authorization header and that it wasn't revoked.
+// Service accounts give an API key instead, in the X-API-Key header or as the Bearer token.
 func AuthMiddleware() gin.HandlerFunc {
+	sessionRepo := repository.NewSessionRepository()
+	serviceAccountService := services.NewServiceAccountService()
+
 	return func(c *gin.Context) {
+		if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+			authenticateAPIKey(c, serviceAccountService, apiKey)
+			return
+		}
+
 		// Get the Authorization header
 		authHeader := c.GetHeader("Authorization")
 
@@ -41,6 +53,11 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		tokenString := parts[1]
 
+		if services.IsAPIKey(tokenString) {
+			authenticateAPIKey(c, serviceAccountService, tokenString)
+			return
+		}
+
 		// Validate the token
 		claims, err := utils.ValidateToken(tokenString)
 		if err != nil {
@@ -53,6 +70,29 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Tokens of sessions logged out or revoked are refused until they expire
+		revoked, err := sessionRepo.IsTokenRevoked(claims.RegisteredClaims.ID)
+		if err != nil {
+			logger.Error("Auth Middleware: Failed to check token revocation", map[string]interface{}{
+				"ip":    c.ClientIP(),
+				"error": err.Error(),
+			})
+			utils.SendError(c, utils.CodeInternalError, utils.MsgInternalError, nil)
+			c.Abort()
+			return
+		}
+
+		if revoked {
+			logger.Warning("Auth Middleware: Revoked token used", map[string]interface{}{
+				"ip":         c.ClientIP(),
+				"user_id":    claims.ID,
+				"session_id": claims.SessionID,
+			})
+			utils.SendError(c, utils.CodeUnauthorized, utils.MsgUnauthorized, nil)
+			c.Abort()
+			return
+		}
+
 		logger.Info("Auth Middleware: Token validated successfully", map[string]interface{}{
 			"user_id": claims.ID,
 			"email":   claims.Email,
@@ -63,6 +103,90 @@ func AuthMiddleware() gin.HandlerFunc {
 		c.Set("userId", claims.ID)
 		c.Set("userEmail", claims.Email)
 		c.Set("userRole", claims.Role)
+		c.Set("sessionId", claims.SessionID)
+
+		c.Next()
+	}
+}
+
+// authenticateAPIKey verifies the API key of a service account. The request acts with the role of
+// the service account, the scopes of the key are checked by RequireScope.
+func authenticateAPIKey(c *gin.Context, serviceAccountService *services.ServiceAccountService, key string) {
+	apiKey, user, err := serviceAccountService.Authenticate(key)
+	if err != nil {
+		if err.Error() != "invalid api key" {
+			logger.Error("Auth Middleware: Failed to check API key", map[string]interface{}{
+				"ip":    c.ClientIP(),
+				"error": err.Error(),
+			})
+			utils.SendError(c, utils.CodeInternalError, utils.MsgInternalError, nil)
+			c.Abort()
+			return
+		}
+
+		logger.Warning("Auth Middleware: Invalid API key", map[string]interface{}{
+			"ip": c.ClientIP(),
+		})
+		utils.SendError(c, utils.CodeUnauthorized, utils.MsgUnauthorized, nil)
+		c.Abort()
+		return
+	}
+
+	logger.Info("Auth Middleware: API key validated successfully", map[string]interface{}{
+		"user_id":    user.ID,
+		"api_key_id": apiKey.ID,
+		"role":       user.Role,
+	})
+
+	c.Set("userId", user.ID)
+	c.Set("userEmail", user.Email)
+	c.Set("userRole", user.Role)
+	c.Set("apiKeyId", apiKey.ID)
+	c.Set("apiKeyScopes", apiKey.Scopes)
+
+	c.Next()
+}
+
+// RequireScope checks that the API key of a request may access the area, reading for GET and
+// HEAD requests and writing for the others. Requests authenticated with a JWT aren't limited.
+func RequireScope(area string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get("apiKeyScopes")
+		if !exists {
+			c.Next()
+			return
+		}
+
+		scopes, _ := value.(models.APIKeyScopes)
+		write := c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead
+		if !scopes.Allows(area, write) {
+			logger.Warning("Auth Middleware: API key scope not allowed", map[string]interface{}{
+				"api_key_id": c.GetUint("apiKeyId"),
+				"area":       area,
+				"write":      write,
+			})
+			utils.SendError(c, utils.CodeForbidden, utils.MsgForbidden, nil)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// JWTOnly refuses the requests authenticated with an API key, for the routes acting on a login
+// session or managing credentials
+func JWTOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, exists := c.Get("apiKeyId"); exists {
+			logger.Warning("Auth Middleware: API key used on a JWT only route", map[string]interface{}{
+				"api_key_id": c.GetUint("apiKeyId"),
+				"path":       c.FullPath(),
+			})
+			utils.SendError(c, utils.CodeForbidden, utils.MsgForbidden, nil)
+			c.Abort()
+			return
+		}
 
 		c.Next()
 	}
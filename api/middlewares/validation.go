@@ -1,6 +1,7 @@
 package middlewares
 
 import (
+	"mime"
 	"regexp"
 
 	"hcall/api/config"
@@ -20,11 +21,11 @@ func ValidateRequest() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var errors []ValidationError
 
-		// Validate Content-Type
-		if c.GetHeader("Content-Type") != "application/json" {
+		// Validate Content-Type, only requests carrying a body have one
+		if c.Request.ContentLength != 0 && !hasSupportedBody(c) {
 			errors = append(errors, ValidationError{
 				Field:   "Content-Type",
-				Message: "Content-Type must be application/json",
+				Message: "Content-Type must be application/json or multipart/form-data",
 			})
 		}
 
@@ -51,6 +52,7 @@ func ValidateRequest() gin.HandlerFunc {
 		// If there are validation errors, return them
 		if len(errors) > 0 {
 			utils.SendError(c, utils.CodeValidationError, utils.MsgValidationError, nil)
+			c.Abort()
 			return
 		}
 
@@ -58,6 +60,13 @@ func ValidateRequest() gin.HandlerFunc {
 	}
 }
 
+// hasSupportedBody tells whether the request body is declared as JSON or as a multipart form (file uploads),
+// parameters like charset are allowed
+func hasSupportedBody(c *gin.Context) bool {
+	mediaType, _, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	return err == nil && (mediaType == "application/json" || mediaType == "multipart/form-data")
+}
+
 // ValidatePassword checks if the password meets the security requirements
 func ValidatePassword(password string) []string {
 	var errors []string
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+)
+
+// Scope areas of the API keys, each is given as "<area>:read" or "<area>:write"
+const (
+	TicketsScope = "tickets"
+	QueuesScope  = "queues"
+	TagsScope    = "tags"
+	UsersScope   = "users"
+	TrashScope   = "trash"
+
+	ReadAccess  = "read"
+	WriteAccess = "write"
+)
+
+// ScopeAreas are the areas an API key can be given access to
+var ScopeAreas = []string{TicketsScope, QueuesScope, TagsScope, UsersScope, TrashScope}
+
+// APIKeyScopes are the scopes of an API key, e.g. ["tickets:read", "tags:write"]
+type APIKeyScopes []string
+
+// Value stores the scopes as JSON
+func (s APIKeyScopes) Value() (driver.Value, error) {
+	data, err := json.Marshal([]string(s))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads the scopes from their JSON column
+func (s *APIKeyScopes) Scan(value interface{}) error {
+	return scanJSON(value, s)
+}
+
+// Allows tells whether the scopes give access to the area, a write scope also allows reading
+func (s APIKeyScopes) Allows(area string, write bool) bool {
+	for _, scope := range s {
+		if scope == area+":"+WriteAccess || (!write && scope == area+":"+ReadAccess) {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKey is a key a service account authenticates with instead of a JWT, only its hash is kept.
+// The key acts with the role of its service account, limited to its scopes.
+type APIKey struct {
+	ID     uint   `json:"api_key_id" gorm:"primaryKey"`
+	UserID uint   `json:"-" gorm:"index;not null"`
+	Name   string `json:"api_key_name" gorm:"size:255;not null"`
+	// Prefix is the start of the key, to recognize it once only its hash is kept
+	Prefix     string       `json:"api_key_prefix" gorm:"size:16;not null"`
+	KeyHash    string       `json:"-" gorm:"size:64;uniqueIndex;not null"`
+	Scopes     APIKeyScopes `json:"api_key_scopes" gorm:"type:jsonb;not null"`
+	ExpiresAt  *time.Time   `json:"api_key_expires_at,omitempty"`
+	LastUsedAt *time.Time   `json:"api_key_last_used_at,omitempty"`
+	RevokedAt  *time.Time   `json:"api_key_revoked_at,omitempty"`
+	CreatedBy  uint         `json:"api_key_created_by"`
+	CreatedAt  time.Time    `json:"api_key_created_at"`
+}
+
+// CreatedAPIKey is what the creator of an API key gets, the key itself isn't shown again
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"api_key"`
+}
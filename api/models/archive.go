@@ -0,0 +1,89 @@
+package models
+
+import "time"
+
+// ArchivedTicket is the index entry of a ticket exported to an archive file by the retention worker.
+// Line is the position of the ticket in the file, starting at 1.
+type ArchivedTicket struct {
+	TicketID        string       `json:"ticket_id" gorm:"primaryKey;type:varchar(100)"`
+	Name            string       `json:"ticket_name" gorm:"size:255;not null"`
+	AuthorEmail     string       `json:"ticket_author" gorm:"size:255;not null;index"`
+	Status          TicketStatus `json:"ticket_status" gorm:"type:varchar(20);not null"`
+	Queue           string       `json:"ticket_queue,omitempty" gorm:"size:100"`
+	Tags            string       `json:"ticket_tags,omitempty" gorm:"type:text"` // Comma separated tag names
+	TicketCreatedAt time.Time    `json:"ticket_date"`
+	File            string       `json:"archive_file" gorm:"size:255;not null"`
+	Line            int          `json:"archive_line" gorm:"not null"`
+	ArchivedAt      time.Time    `json:"archived_at" gorm:"index"`
+	RestoredAt      *time.Time   `json:"restored_at,omitempty"`
+}
+
+// ArchiveRecord is a ticket as written in an archive file, one JSON object per line.
+// It holds everything needed to bring the ticket back, the images content included.
+type ArchiveRecord struct {
+	ID                 string                `json:"ticket_id"`
+	Name               string                `json:"ticket_name"`
+	Explanation        string                `json:"ticket_explain"`
+	Status             TicketStatus          `json:"ticket_status"`
+	Priority           TicketPriority        `json:"ticket_priority"`
+	SLAState           SLAState              `json:"ticket_sla_state"`
+	AuthorEmail        string                `json:"ticket_author"`
+	AssigneeEmail      string                `json:"ticket_assignee,omitempty"`
+	Queue              string                `json:"ticket_queue,omitempty"`
+	Tags               []string              `json:"ticket_tags,omitempty"`
+	CustomFields       CustomFields          `json:"ticket_custom_fields,omitempty"`
+	FirstResponseDueAt *time.Time            `json:"ticket_first_response_due,omitempty"`
+	ResolutionDueAt    *time.Time            `json:"ticket_resolution_due,omitempty"`
+	FirstResponseAt    *time.Time            `json:"ticket_first_response_at,omitempty"`
+	ResolvedAt         *time.Time            `json:"ticket_resolved_at,omitempty"`
+	CreatedAt          time.Time             `json:"ticket_date"`
+	UpdatedAt          time.Time             `json:"ticket_updated_at"`
+	Messages           []ArchiveMessage      `json:"ticket_history"`
+	Images             []ArchiveImage        `json:"ticket_images"`
+	StatusChanges      []ArchiveStatusChange `json:"ticket_status_changes"`
+	Revisions          []ArchiveRevision     `json:"ticket_revisions"`
+}
+
+// ArchiveMessage is a message of an archived ticket conversation
+type ArchiveMessage struct {
+	AuthorID   *uint             `json:"author_id,omitempty"`
+	AuthorName string            `json:"author_name,omitempty"`
+	AuthorRole Role              `json:"author_role,omitempty"`
+	Visibility MessageVisibility `json:"visibility"`
+	Message    string            `json:"message"`
+	EditedAt   *time.Time        `json:"edited_at,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
+
+// ArchiveImage is an image of an archived ticket, Content is encoded as base64 in the file
+type ArchiveImage struct {
+	// ID is missing from the files written before image IDs were archived
+	ID          string    `json:"id,omitempty"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Content     []byte    `json:"content"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+}
+
+// ArchiveStatusChange is a workflow transition of an archived ticket
+type ArchiveStatusChange struct {
+	FromStatus TicketStatus `json:"from_status"`
+	ToStatus   TicketStatus `json:"to_status"`
+	Reason     string       `json:"reason,omitempty"`
+	ActorID    uint         `json:"actor_id"`
+	ActorEmail string       `json:"actor_email"`
+	CreatedAt  time.Time    `json:"changed_at"`
+}
+
+// ArchiveRevision is an edit of the name or explanation of an archived ticket
+type ArchiveRevision struct {
+	PreviousName        string    `json:"previous_name,omitempty"`
+	Name                string    `json:"ticket_name,omitempty"`
+	PreviousExplanation string    `json:"previous_explain,omitempty"`
+	Explanation         string    `json:"ticket_explain,omitempty"`
+	Diff                string    `json:"diff"`
+	ActorID             uint      `json:"actor_id"`
+	ActorName           string    `json:"actor_name"`
+	ActorRole           Role      `json:"actor_role"`
+	CreatedAt           time.Time `json:"changed_at"`
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// Custom field types and formats of a FieldSchema
+const (
+	FieldTypeString  = "string"
+	FieldTypeNumber  = "number"
+	FieldTypeInteger = "integer"
+
+	FieldFormatDate = "date" // YYYY-MM-DD
+	FieldFormatUser = "user" // Email of a registered user
+)
+
+// FieldSchema is the JSON Schema of the custom fields of the tickets of a queue. Only the subset
+// describing flat objects is supported: text, number, enum, date and user reference properties.
+type FieldSchema struct {
+	Type       string                     `json:"type"`
+	Properties map[string]FieldDefinition `json:"properties"`
+	Required   []string                   `json:"required,omitempty"`
+}
+
+// FieldDefinition is a property of a FieldSchema
+type FieldDefinition struct {
+	Type        string   `json:"type"`
+	Title       string   `json:"title,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Format      string   `json:"format,omitempty"`
+	Enum        []string `json:"enum,omitempty"`
+	MinLength   *int     `json:"minLength,omitempty"`
+	MaxLength   *int     `json:"maxLength,omitempty"`
+	Pattern     string   `json:"pattern,omitempty"`
+	Minimum     *float64 `json:"minimum,omitempty"`
+	Maximum     *float64 `json:"maximum,omitempty"`
+}
+
+// Value stores the schema as JSON
+func (s FieldSchema) Value() (driver.Value, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads the schema from its JSON column
+func (s *FieldSchema) Scan(value interface{}) error {
+	return scanJSON(value, s)
+}
+
+// CustomFields holds the values of the custom fields of a ticket
+type CustomFields map[string]interface{}
+
+// Value stores the values as JSON, tickets without custom fields keep a NULL column
+func (f CustomFields) Value() (driver.Value, error) {
+	if len(f) == 0 {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads the values from their JSON column
+func (f *CustomFields) Scan(value interface{}) error {
+	return scanJSON(value, f)
+}
+
+// scanJSON decodes a JSON column into dest, NULL leaves it empty
+func scanJSON(value interface{}, dest interface{}) error {
+	switch data := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(data, dest)
+	case string:
+		return json.Unmarshal([]byte(data), dest)
+	default:
+		return errors.New("unsupported JSON column value")
+	}
+}
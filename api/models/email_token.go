@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// EmailTokenPurpose is what a token sent by email allows
+type EmailTokenPurpose string
+
+const (
+	VerifyEmailPurpose   EmailTokenPurpose = "verify_email"
+	ResetPasswordPurpose EmailTokenPurpose = "reset_password"
+)
+
+// EmailToken is a one-time token sent to the email of a user, only its hash is kept
+type EmailToken struct {
+	ID        uint              `gorm:"primaryKey"`
+	UserID    uint              `gorm:"index;not null"`
+	Purpose   EmailTokenPurpose `gorm:"type:varchar(20);not null"`
+	TokenHash string            `gorm:"size:64;uniqueIndex;not null"`
+	ExpiresAt time.Time         `gorm:"index;not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// EmailRequest records a request to send a token to an email, known or not, for the rate limits
+type EmailRequest struct {
+	ID        uint              `gorm:"primaryKey"`
+	Email     string            `gorm:"size:255;index:idx_email_requests_email_purpose;not null"`
+	Purpose   EmailTokenPurpose `gorm:"type:varchar(20);index:idx_email_requests_email_purpose;not null"`
+	CreatedAt time.Time         `gorm:"index"`
+}
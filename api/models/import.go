@@ -0,0 +1,18 @@
+package models
+
+// ImportReport is the outcome of a ticket import, with the error of each row that wasn't imported
+type ImportReport struct {
+	DryRun   bool `json:"dry_run"`
+	Rows     int  `json:"rows"`
+	Imported int  `json:"imported"`
+	Failed   int  `json:"failed"`
+	// Placeholders are the emails of the users created for the unknown authors, or to be created on a dry run
+	Placeholders []string      `json:"placeholders"`
+	Errors       []ImportError `json:"errors"`
+}
+
+// ImportError is the reason a row of an import file wasn't imported
+type ImportError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
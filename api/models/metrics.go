@@ -0,0 +1,50 @@
+package models
+
+// MetricsGroupBy is the ticket field the helpdesk metrics are broken down by
+type MetricsGroupBy string
+
+const (
+	MetricsByStatus   MetricsGroupBy = "status"
+	MetricsByAgent    MetricsGroupBy = "agent"
+	MetricsByCategory MetricsGroupBy = "category" // the queue of the ticket
+)
+
+// TimeStats summarizes durations in seconds, the figures are null when there is nothing to measure
+type TimeStats struct {
+	Count   int64    `json:"count"`
+	Average *float64 `json:"average_seconds"`
+	Median  *float64 `json:"median_seconds"`
+	P90     *float64 `json:"p90_seconds"`
+}
+
+// AgeBucket is the number of open tickets whose age falls in a range
+type AgeBucket struct {
+	Bucket string `json:"bucket"`
+	Count  int64  `json:"count"`
+}
+
+// DailyVolume is the number of tickets created and closed during a day
+type DailyVolume struct {
+	Day     string `json:"day"`
+	Created int64  `json:"created"`
+	Closed  int64  `json:"closed"`
+}
+
+// MetricsGroup holds the metrics of the tickets sharing the grouped field
+type MetricsGroup struct {
+	Key           string        `json:"key"`
+	Label         string        `json:"label"`
+	FirstResponse TimeStats     `json:"first_response"`
+	Resolution    TimeStats     `json:"resolution"`
+	BacklogAge    []AgeBucket   `json:"backlog_age"`
+	Daily         []DailyVolume `json:"daily"`
+}
+
+// HelpdeskMetrics are the helpdesk metrics over a date range. Response and resolution times are the
+// ones of the tickets answered or resolved during the range, the backlog age is the current one.
+type HelpdeskMetrics struct {
+	DateFrom string         `json:"date_from"`
+	DateTo   string         `json:"date_to"`
+	GroupBy  MetricsGroupBy `json:"group_by,omitempty"`
+	Groups   []MetricsGroup `json:"groups"`
+}
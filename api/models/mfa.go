@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// UserMFA is the TOTP second factor of a user. It is pending until the user proves the
+// authenticator app was set up by giving a first code.
+type UserMFA struct {
+	UserID    uint       `gorm:"primaryKey"`
+	Secret    string     `gorm:"size:64;not null"`
+	EnabledAt *time.Time `gorm:"index"`
+	// LastUsedStep is the TOTP period of the last code accepted, a code can't be used twice
+	LastUsedStep int64 `gorm:"not null;default:0"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// RecoveryCode is a one-time code replacing a TOTP code when the authenticator app is lost,
+// only its hash is kept
+type RecoveryCode struct {
+	ID       uint   `gorm:"primaryKey"`
+	UserID   uint   `gorm:"index;not null"`
+	CodeHash string `gorm:"size:64;not null"`
+	UsedAt   *time.Time
+}
+
+// MFAChallenge is the second step of a login, it holds the password check until a TOTP or
+// recovery code is given
+type MFAChallenge struct {
+	ID        string `gorm:"primaryKey;size:64"`
+	UserID    uint   `gorm:"index;not null"`
+	TokenHash string `gorm:"size:64;uniqueIndex;not null"`
+	// Attempts counts the wrong codes given, the challenge can't be used past the limit
+	Attempts  int       `gorm:"not null;default:0"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// MFAChallengeResponse is what a user with a second factor gets from the login instead of tokens
+type MFAChallengeResponse struct {
+	Token     string    `json:"mfa_token"`
+	ExpiresAt time.Time `json:"mfa_expires_at"`
+	// EnrollmentRequired tells the user must set up a second factor before logging in, as the role requires one
+	EnrollmentRequired bool `json:"mfa_enrollment_required"`
+}
+
+// MFASetup is what an authenticator app is set up from
+type MFASetup struct {
+	Secret          string `json:"mfa_secret"`
+	ProvisioningURI string `json:"mfa_provisioning_uri"`
+}
+
+// MFAStatus tells whether a user has a second factor
+type MFAStatus struct {
+	Enabled       bool       `json:"mfa_enabled"`
+	Required      bool       `json:"mfa_required"`
+	EnabledAt     *time.Time `json:"mfa_enabled_at,omitempty"`
+	RecoveryCodes int64      `json:"mfa_recovery_codes_left"`
+}
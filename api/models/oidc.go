@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// OIDCState is a single sign-on login waiting for the user to come back from the identity
+// provider, only the hashes of its state and of its token are kept
+type OIDCState struct {
+	ID        uint   `gorm:"primaryKey"`
+	StateHash string `gorm:"size:64;uniqueIndex;not null"`
+	// TokenHash is the hash of the token given to the client that started the login, only that
+	// client can finish it, so a user can't be logged in with the code of someone else
+	TokenHash string `gorm:"size:64;not null"`
+	Nonce     string `gorm:"size:64;not null"`
+	// CodeVerifier is the PKCE secret the code is exchanged with, it never leaves the server
+	CodeVerifier string    `gorm:"size:128;not null"`
+	ExpiresAt    time.Time `gorm:"index;not null"`
+	CreatedAt    time.Time
+}
+
+// UserIdentity links a user to its account at the identity provider, the subject of a provider
+// never changes while the email may
+type UserIdentity struct {
+	ID          uint   `gorm:"primaryKey"`
+	UserID      uint   `gorm:"index;not null"`
+	Issuer      string `gorm:"size:255;uniqueIndex:idx_user_identities_issuer_subject;not null"`
+	Subject     string `gorm:"size:255;uniqueIndex:idx_user_identities_issuer_subject;not null"`
+	CreatedAt   time.Time
+	LastLoginAt time.Time
+}
+
+// OIDCAuthorization is where the user is sent to log in with single sign-on
+type OIDCAuthorization struct {
+	URL string `json:"authorization_url"`
+	// Token is kept by the client and sent back with the code
+	Token     string    `json:"oidc_token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -0,0 +1,18 @@
+package models
+
+// Page describes the slice of a listing to return. Once the listing is done it also
+// tells how many rows match and where the next page starts.
+type Page struct {
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+	Cursor     string `json:"-"`
+	Sort       string `json:"sort"`
+	Order      string `json:"order"`
+	Total      int64  `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+const (
+	SortAscending  = "asc"
+	SortDescending = "desc"
+)
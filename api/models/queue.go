@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Queue groups the tickets of a same area (IT, facilities, HR...). Requesters pick the queue of their
+// tickets and admins only see and work the tickets of the queues they are members of.
+type Queue struct {
+	ID          uint   `json:"queue_id" gorm:"primaryKey"`
+	Name        string `json:"queue_name" gorm:"size:100;unique;not null"`
+	Description string `json:"queue_description" gorm:"type:text"`
+	IsDefault   bool   `json:"queue_default" gorm:"default:false;not null"` // Receives the tickets created without a queue
+	// FieldSchema describes the custom fields filled in when creating a ticket in the queue
+	FieldSchema *FieldSchema `json:"queue_field_schema,omitempty" gorm:"type:jsonb"`
+	CreatedAt   time.Time    `json:"queue_created_at"`
+	UpdatedAt   time.Time    `json:"-"`
+}
+
+// QueueMember gives an admin access to the tickets of a queue
+type QueueMember struct {
+	QueueID   uint      `json:"queue_id" gorm:"primaryKey"`
+	UserID    uint      `json:"-" gorm:"primaryKey;index"`
+	User      User      `json:"-" gorm:"foreignKey:UserID"`
+	CreatedAt time.Time `json:"member_since"`
+}
+
+// QueueMemberResponse is a member of a queue as returned by the API
+type QueueMemberResponse struct {
+	Username    string    `json:"user_name"`
+	Email       string    `json:"user_email"`
+	Role        Role      `json:"user_role"`
+	MemberSince time.Time `json:"member_since"`
+}
+
+// ToResponse converts a QueueMember with its user loaded to a QueueMemberResponse
+func (m *QueueMember) ToResponse() QueueMemberResponse {
+	return QueueMemberResponse{
+		Username:    m.User.Username,
+		Email:       m.User.Email,
+		Role:        m.User.Role,
+		MemberSince: m.CreatedAt,
+	}
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Session is a login of a user. The refresh token of the session gets new access tokens and is
+// replaced each time it is used, only its hash is kept.
+type Session struct {
+	ID     string `json:"session_id" gorm:"primaryKey;size:64"`
+	UserID uint   `json:"-" gorm:"index;not null"`
+	// RefreshHash is the hash of the current refresh token of the session
+	RefreshHash string `json:"-" gorm:"size:64;uniqueIndex;not null"`
+	// PreviousHash is the hash of the refresh token replaced last, using it again means it was stolen
+	PreviousHash string `json:"-" gorm:"size:64;index"`
+	// AccessJTI is the id of the last access token given to the session, revoked with it
+	AccessJTI       string     `json:"-" gorm:"size:64"`
+	AccessExpiresAt time.Time  `json:"-"`
+	UserAgent       string     `json:"session_user_agent" gorm:"size:255"`
+	IP              string     `json:"session_ip" gorm:"size:64"`
+	ExpiresAt       time.Time  `json:"session_expires_at" gorm:"index;not null"`
+	RevokedAt       *time.Time `json:"-"`
+	CreatedAt       time.Time  `json:"session_created_at"`
+	LastUsedAt      time.Time  `json:"session_last_used_at"`
+	// Current tells whether the session is the one of the request listing the sessions
+	Current bool `json:"session_current" gorm:"-"`
+}
+
+// RevokedToken is an access token that can't be used anymore though it didn't expire yet
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey;size:64"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+}
+
+// TokenPair is what a user gets when logging in or refreshing its session
+type TokenPair struct {
+	AccessToken  string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Tag is a free-form label of tickets ("vpn", "printer", "outage-2026-10"), names are kept in lower case
+type Tag struct {
+	ID        uint      `json:"tag_id" gorm:"primaryKey"`
+	Name      string    `json:"tag_name" gorm:"size:50;unique;not null"`
+	CreatedAt time.Time `json:"tag_created_at"`
+}
+
+// TagUsage is a tag with the number of tickets labeled with it
+type TagUsage struct {
+	ID      uint   `json:"tag_id"`
+	Name    string `json:"tag_name"`
+	Tickets int64  `json:"tag_tickets"`
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,62 +11,193 @@ import (
 type TicketStatus string
 
 const (
-	PendingStatus  TicketStatus = "pending"
-	DoingStatus    TicketStatus = "doing"
-	ConcluedStatus TicketStatus = "conclued"
+	PendingStatus   TicketStatus = "pending"
+	DoingStatus     TicketStatus = "doing"
+	OnHoldStatus    TicketStatus = "on_hold"
+	ConcluedStatus  TicketStatus = "conclued"
+	CancelledStatus TicketStatus = "cancelled"
+	ReopenedStatus  TicketStatus = "reopened"
+)
+
+// ClosedStatuses are the statuses in which nobody has to work on the ticket anymore
+var ClosedStatuses = []TicketStatus{ConcluedStatus, CancelledStatus}
+
+// IsClosed tells whether a ticket status is a final one
+func (s TicketStatus) IsClosed() bool {
+	for _, closed := range ClosedStatuses {
+		if s == closed {
+			return true
+		}
+	}
+	return false
+}
+
+type TicketPriority string
+
+const (
+	LowPriority    TicketPriority = "low"
+	NormalPriority TicketPriority = "normal"
+	HighPriority   TicketPriority = "high"
+	UrgentPriority TicketPriority = "urgent"
+)
+
+// SLAState tells whether a ticket is within its SLA targets
+type SLAState string
+
+const (
+	SLAOk       SLAState = "ok"
+	SLAAtRisk   SLAState = "at_risk"
+	SLABreached SLAState = "breached"
 )
 
 type Ticket struct {
-	ID          string          `json:"ticket_id" gorm:"primaryKey;type:varchar(100)"`
-	Name        string          `json:"ticket_name" gorm:"size:255;not null"`
-	Explanation string          `json:"ticket_description" gorm:"type:text;not null"`
-	Status      TicketStatus    `json:"ticket_status" gorm:"type:varchar(20);default:pending;not null"`
-	AuthorID    uint            `json:"-" gorm:"not null"`
-	AuthorEmail string          `json:"ticket_author" gorm:"size:255;not null"`
-	Images      []Image         `json:"ticket_email,omitempty" gorm:"foreignKey:TicketID"`
-	History     []TicketHistory `json:"ticket_history,omitempty" gorm:"foreignKey:TicketID"`
-	CreatedAt   time.Time       `json:"ticket_date"`
-	UpdatedAt   time.Time       `json:"ticket_updated_at"`
-	DeletedAt   *time.Time      `json:"-" gorm:"index"`
+	ID                 string           `json:"ticket_id" gorm:"primaryKey;type:varchar(100)"`
+	Name               string           `json:"ticket_name" gorm:"size:255;not null"`
+	Explanation        string           `json:"ticket_description" gorm:"type:text;not null"`
+	Status             TicketStatus     `json:"ticket_status" gorm:"type:varchar(20);default:pending;not null"`
+	AuthorID           uint             `json:"-" gorm:"not null"`
+	AuthorEmail        string           `json:"ticket_author" gorm:"size:255;not null"`
+	AssigneeID         *uint            `json:"-" gorm:"index"`
+	Assignee           *User            `json:"-" gorm:"foreignKey:AssigneeID"`
+	QueueID            *uint            `json:"-" gorm:"index"`
+	Queue              *Queue           `json:"-" gorm:"foreignKey:QueueID"`
+	Tags               []Tag            `json:"-" gorm:"many2many:ticket_tags"`
+	CustomFields       CustomFields     `json:"ticket_custom_fields,omitempty" gorm:"type:jsonb;index:idx_tickets_custom_fields,type:gin"`
+	Priority           TicketPriority   `json:"ticket_priority" gorm:"type:varchar(10);default:normal;not null"`
+	SLAState           SLAState         `json:"ticket_sla_state" gorm:"type:varchar(10);default:ok;not null;index"`
+	FirstResponseDueAt *time.Time       `json:"ticket_first_response_due,omitempty"`
+	ResolutionDueAt    *time.Time       `json:"ticket_resolution_due,omitempty" gorm:"index"`
+	FirstResponseAt    *time.Time       `json:"ticket_first_response_at,omitempty" gorm:"index"`
+	ResolvedAt         *time.Time       `json:"ticket_resolved_at,omitempty" gorm:"index"` // Last time the ticket was closed, cleared when it is reopened
+	Images             []Image          `json:"ticket_email,omitempty" gorm:"foreignKey:TicketID"`
+	History            []TicketHistory  `json:"ticket_history,omitempty" gorm:"foreignKey:TicketID"`
+	Revisions          []TicketRevision `json:"-" gorm:"foreignKey:TicketID"`
+	CreatedAt          time.Time        `json:"ticket_date"`
+	UpdatedAt          time.Time        `json:"ticket_updated_at"`
+	DeletedAt          gorm.DeletedAt   `json:"-" gorm:"index"`
+	SearchRank         float64          `json:"-" gorm:"->;-:migration"` // Only loaded by the full-text search
 }
 
 func (t *Ticket) BeforeCreate(tx *gorm.DB) error {
-	t.ID = "ticket_" + uuid.New().String()
+	// Tickets brought back from the archive keep their ID
+	if t.ID == "" {
+		t.ID = "ticket_" + uuid.New().String()
+	}
 	return nil
 }
 
 type Image struct {
-	ID          string     `json:"image_id" gorm:"primaryKey;type:varchar(100)"`
-	TicketID    string     `json:"-" gorm:"type:varchar(100);not null"`
-	Name        string     `json:"image_name" gorm:"size:255;not null"`
-	ContentType string     `json:"image_type" gorm:"size:100;not null"`
-	Base64      string     `json:"image_base64" gorm:"type:text;not null"` // Base64 encoded image content stored in DB
-	UploadedAt  time.Time  `json:"image_uploaded_at"`
-	DeletedAt   *time.Time `json:"-" gorm:"index"`
+	ID          string         `json:"image_id" gorm:"primaryKey;type:varchar(100)"`
+	TicketID    string         `json:"-" gorm:"type:varchar(100);not null"`
+	Name        string         `json:"image_name" gorm:"size:255;not null"`
+	ContentType string         `json:"image_type" gorm:"size:100;not null"`
+	Size        int64          `json:"image_size"`
+	StorageKey  string         `json:"-" gorm:"size:255"`  // Key of the image content in the blob store
+	Base64      string         `json:"-" gorm:"type:text"` // Legacy content stored in DB, moved to the blob store by the migrate-images command
+	UploadedAt  time.Time      `json:"image_uploaded_at"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// IsStored tells whether the image content lives in the blob store
+func (i *Image) IsStored() bool {
+	return i.StorageKey != ""
 }
 
 func (i *Image) BeforeCreate(tx *gorm.DB) error {
-	i.ID = "img_" + uuid.New().String()[:8]
+	// Images brought back from the archive keep their ID
+	if i.ID == "" {
+		i.ID = "img_" + uuid.New().String()[:8]
+	}
 	return nil
 }
 
+// MessageVisibility tells who can read a message of the ticket conversation
+type MessageVisibility string
+
+const (
+	PublicMessage   MessageVisibility = "public"   // visible to the requester and the agents
+	InternalMessage MessageVisibility = "internal" // agent-only note
+)
+
+// TicketHistory is a message of the ticket conversation. Messages without an author
+// are entries written by the system, like assignment or priority changes.
 type TicketHistory struct {
-	ID        uint       `json:"-" gorm:"primaryKey"`
-	TicketID  string     `json:"-" gorm:"type:varchar(100);not null"`
-	Message   string     `json:"ticket_return" gorm:"type:text;not null"`
-	CreatedAt time.Time  `json:"ticket_date"`
-	UpdatedAt time.Time  `json:"-"`
-	DeletedAt *time.Time `json:"-" gorm:"index"`
+	ID         uint              `json:"message_id" gorm:"primaryKey"`
+	TicketID   string            `json:"-" gorm:"type:varchar(100);not null;index"`
+	AuthorID   *uint             `json:"-" gorm:"index"`
+	AuthorName string            `json:"message_author,omitempty" gorm:"size:255"`
+	AuthorRole Role              `json:"message_author_role,omitempty" gorm:"type:varchar(10)"`
+	Visibility MessageVisibility `json:"message_visibility" gorm:"type:varchar(10);default:public;not null"`
+	Message    string            `json:"ticket_return" gorm:"type:text;not null"`
+	EditedAt   *time.Time        `json:"message_edited_at,omitempty"`
+	CreatedAt  time.Time         `json:"ticket_date"`
+	UpdatedAt  time.Time         `json:"-"`
+	DeletedAt  gorm.DeletedAt    `json:"-" gorm:"index"`
+}
+
+// IsInternal tells whether the message is an agent-only note
+func (h *TicketHistory) IsInternal() bool {
+	return h.Visibility == InternalMessage
+}
+
+// TicketHistoryRevision keeps the previous content of a message every time it is edited or deleted
+type TicketHistoryRevision struct {
+	ID              uint      `json:"-" gorm:"primaryKey"`
+	HistoryID       uint      `json:"message_id" gorm:"not null;index"`
+	Action          string    `json:"action" gorm:"type:varchar(10);not null"`
+	PreviousMessage string    `json:"previous_message" gorm:"type:text;not null"`
+	ActorID         uint      `json:"-" gorm:"not null"`
+	ActorEmail      string    `json:"actor" gorm:"size:255;not null"`
+	CreatedAt       time.Time `json:"changed_at"`
+}
+
+// TicketRevision keeps the name and explanation of a ticket before and after each edit of its content.
+// Only the changed fields are filled, Diff compares them line by line.
+type TicketRevision struct {
+	ID                  uint      `json:"revision_id" gorm:"primaryKey"`
+	TicketID            string    `json:"-" gorm:"type:varchar(100);not null;index"`
+	PreviousName        string    `json:"previous_name,omitempty" gorm:"size:255"`
+	Name                string    `json:"ticket_name,omitempty" gorm:"size:255"`
+	PreviousExplanation string    `json:"previous_explain,omitempty" gorm:"type:text"`
+	Explanation         string    `json:"ticket_explain,omitempty" gorm:"type:text"`
+	Diff                string    `json:"diff" gorm:"type:text;not null"`
+	ActorID             uint      `json:"-" gorm:"not null"`
+	ActorName           string    `json:"actor_name" gorm:"size:255"`
+	ActorRole           Role      `json:"actor_role" gorm:"type:varchar(10)"`
+	CreatedAt           time.Time `json:"changed_at"`
+}
+
+const (
+	MessageEdited  = "edit"
+	MessageDeleted = "delete"
+)
+
+// TicketStatusChange records a transition in the ticket workflow
+type TicketStatusChange struct {
+	ID         uint         `json:"-" gorm:"primaryKey"`
+	TicketID   string       `json:"-" gorm:"type:varchar(100);not null;index"`
+	FromStatus TicketStatus `json:"from_status" gorm:"type:varchar(20);not null"`
+	ToStatus   TicketStatus `json:"to_status" gorm:"type:varchar(20);not null"`
+	Reason     string       `json:"reason,omitempty" gorm:"type:text"`
+	ActorID    uint         `json:"-" gorm:"not null"`
+	ActorEmail string       `json:"actor" gorm:"size:255;not null"`
+	CreatedAt  time.Time    `json:"changed_at"`
 }
 
 // Define response structures for tickets
 
 type BasicTicketResponse struct {
-	ID         string       `json:"ticket_id"`
-	Name       string       `json:"ticket_name"`
-	Status     TicketStatus `json:"ticket_status"`
-	AuthorName string       `json:"ticket_author"`
-	CreatedAt  time.Time    `json:"ticket_date"`
+	ID         string         `json:"ticket_id"`
+	Name       string         `json:"ticket_name"`
+	Status     TicketStatus   `json:"ticket_status"`
+	AuthorName string         `json:"ticket_author"`
+	Assignee   string         `json:"ticket_assignee,omitempty"`
+	Queue      string         `json:"ticket_queue,omitempty"`
+	Tags       []string       `json:"ticket_tags,omitempty"`
+	Priority   TicketPriority `json:"ticket_priority"`
+	SLAState   SLAState       `json:"ticket_sla_state"`
+	DueAt      *time.Time     `json:"ticket_due,omitempty"`
+	CreatedAt  time.Time      `json:"ticket_date"`
 }
 
 // Update the ToBasicResponse method
@@ -75,19 +207,84 @@ func (t *Ticket) ToBasicResponse(username string) BasicTicketResponse {
 		Name:       t.Name,
 		Status:     t.Status,
 		AuthorName: username,
+		Assignee:   t.AssigneeName(),
+		Queue:      t.QueueName(),
+		Tags:       t.TagNames(),
+		Priority:   t.Priority,
+		SLAState:   t.SLAState,
+		DueAt:      t.ResolutionDueAt,
 		CreatedAt:  t.CreatedAt,
 	}
 }
 
+// AssigneeName returns the username of the agent working on the ticket, if it was loaded
+func (t *Ticket) AssigneeName() string {
+	if t.Assignee == nil {
+		return ""
+	}
+	return t.Assignee.Username
+}
+
+// QueueName returns the name of the queue of the ticket, if it was loaded
+func (t *Ticket) QueueName() string {
+	if t.Queue == nil {
+		return ""
+	}
+	return t.Queue.Name
+}
+
+// TagNames returns the names of the tags of the ticket, if they were loaded
+func (t *Ticket) TagNames() []string {
+	names := make([]string, len(t.Tags))
+	for i, tag := range t.Tags {
+		names[i] = tag.Name
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OwnerTicketResponse is the listing entry shown to the author of a ticket
+type OwnerTicketResponse struct {
+	ID        string         `json:"ticket_id"`
+	Name      string         `json:"ticket_name"`
+	Status    TicketStatus   `json:"ticket_status"`
+	Priority  TicketPriority `json:"ticket_priority"`
+	Queue     string         `json:"ticket_queue,omitempty"`
+	CreatedAt time.Time      `json:"ticket_date"`
+	UpdatedAt time.Time      `json:"ticket_updated_at"`
+}
+
+// ToOwnerResponse converts a Ticket to an OwnerTicketResponse
+func (t *Ticket) ToOwnerResponse() OwnerTicketResponse {
+	return OwnerTicketResponse{
+		ID:        t.ID,
+		Name:      t.Name,
+		Status:    t.Status,
+		Priority:  t.Priority,
+		Queue:     t.QueueName(),
+		CreatedAt: t.CreatedAt,
+		UpdatedAt: t.UpdatedAt,
+	}
+}
+
 type DetailedTicketResponse struct {
-	ID          string          `json:"ticket_id"`
-	Name        string          `json:"ticket_name"`
-	Status      TicketStatus    `json:"tickt_status"`
-	Explanation string          `json:"ticket_explain"`
-	AuthorEmail string          `json:"ticket_email,omitempty"`
-	Images      []Image         `json:"ticket_images,omitempty"`
-	History     []TicketHistory `json:"ticket_history,omitempty"`
-	CreatedAt   time.Time       `json:"ticket_date,omitempty"`
+	ID                 string           `json:"ticket_id"`
+	Name               string           `json:"ticket_name"`
+	Status             TicketStatus     `json:"tickt_status"`
+	Explanation        string           `json:"ticket_explain"`
+	Priority           TicketPriority   `json:"ticket_priority"`
+	Queue              string           `json:"ticket_queue,omitempty"`
+	AuthorEmail        string           `json:"ticket_email,omitempty"`
+	Assignee           string           `json:"ticket_assignee,omitempty"`
+	Tags               []string         `json:"ticket_tags,omitempty"`
+	CustomFields       CustomFields     `json:"ticket_custom_fields,omitempty"`
+	SLAState           SLAState         `json:"ticket_sla_state,omitempty"`
+	FirstResponseDueAt *time.Time       `json:"ticket_first_response_due,omitempty"`
+	ResolutionDueAt    *time.Time       `json:"ticket_resolution_due,omitempty"`
+	Images             []Image          `json:"ticket_images,omitempty"`
+	History            []TicketHistory  `json:"ticket_history,omitempty"`
+	Revisions          []TicketRevision `json:"ticket_revisions,omitempty"`
+	CreatedAt          time.Time        `json:"ticket_date,omitempty"`
 }
 
 // ToDetailedResponse converts a Ticket to a DetailedTicketResponse
@@ -105,26 +302,90 @@ func (t *Ticket) ToDetailedResponse(includeAuthor bool) DetailedTicketResponse {
 	}
 
 	response := DetailedTicketResponse{
-		ID:          t.ID,
-		Name:        t.Name,
-		Status:      t.Status,
-		Explanation: t.Explanation,
-		Images:      images,
-		History:     history,
-		CreatedAt:   t.CreatedAt,
+		ID:           t.ID,
+		Name:         t.Name,
+		Status:       t.Status,
+		Priority:     t.Priority,
+		Queue:        t.QueueName(),
+		Explanation:  t.Explanation,
+		CustomFields: t.CustomFields,
+		Images:       images,
+		History:      history,
+		Revisions:    t.Revisions,
+		CreatedAt:    t.CreatedAt,
 	}
 
 	if includeAuthor {
 		response.AuthorEmail = t.AuthorEmail
+		if t.Assignee != nil {
+			response.Assignee = t.Assignee.Email
+		}
+		response.Tags = t.TagNames()
+		response.SLAState = t.SLAState
+		response.FirstResponseDueAt = t.FirstResponseDueAt
+		response.ResolutionDueAt = t.ResolutionDueAt
 	}
 
 	return response
 }
 
+// TicketHighlight holds the parts of a ticket matching a search, with the matched words marked
+type TicketHighlight struct {
+	TicketID    string `json:"-" gorm:"column:id"`
+	Name        string `json:"name,omitempty"`
+	Explanation string `json:"explanation,omitempty"`
+	Messages    string `json:"messages,omitempty"`
+}
+
+// TicketSearchResponse is a ticket found by the full-text search
+type TicketSearchResponse struct {
+	BasicTicketResponse
+	Rank      float64         `json:"search_rank"`
+	Highlight TicketHighlight `json:"search_highlight"`
+}
+
+// CounterScope tells what a row of the counters table counts
+type CounterScope string
+
+const (
+	GlobalCounters   CounterScope = "global"   // every ticket
+	AuthorCounters   CounterScope = "author"   // the tickets created by the owner
+	AssigneeCounters CounterScope = "assignee" // the tickets assigned to the owner
+)
+
+// Counters holds the number of live tickets per status, trashed tickets aren't counted.
+// OwnerID is the user the row counts the tickets of, 0 for the global row.
 type Counters struct {
-	ID       uint `json:"user_id" gorm:"primaryKey"`
-	Total    int  `json:"total" gorm:"default:0"`
-	Pending  int  `json:"pending" gorm:"default:0"`
-	Doing    int  `json:"doing" gorm:"default:0"`
-	Conclued int  `json:"conclued" gorm:"default:0"`
+	ID        uint         `json:"user_id" gorm:"primaryKey"`
+	Scope     CounterScope `json:"-" gorm:"type:varchar(10);not null;default:global;uniqueIndex:idx_counters_owner"`
+	OwnerID   uint         `json:"-" gorm:"not null;default:0;uniqueIndex:idx_counters_owner"`
+	Total     int          `json:"total" gorm:"default:0"`
+	Pending   int          `json:"pending" gorm:"default:0"`
+	Doing     int          `json:"doing" gorm:"default:0"`
+	OnHold    int          `json:"on_hold" gorm:"default:0"`
+	Conclued  int          `json:"conclued" gorm:"default:0"`
+	Cancelled int          `json:"cancelled" gorm:"default:0"`
+	Reopened  int          `json:"reopened" gorm:"default:0"`
+}
+
+// UserCounters are the counters of the tickets created by or assigned to a user
+type UserCounters struct {
+	UserID    uint   `json:"user_id"`
+	UserName  string `json:"user_name"`
+	UserEmail string `json:"user_email"`
+	Total     int    `json:"total"`
+	Pending   int    `json:"pending"`
+	Doing     int    `json:"doing"`
+	OnHold    int    `json:"on_hold"`
+	Conclued  int    `json:"conclued"`
+	Cancelled int    `json:"cancelled"`
+	Reopened  int    `json:"reopened"`
+}
+
+// AgentWorkload is the number of open tickets assigned to an agent
+type AgentWorkload struct {
+	AgentID     uint   `json:"agent_id"`
+	AgentName   string `json:"agent_name"`
+	AgentEmail  string `json:"agent_email"`
+	OpenTickets int64  `json:"open_tickets"`
 }
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// TrashedTicketResponse is a ticket of the trash listing
+type TrashedTicketResponse struct {
+	BasicTicketResponse
+	DeletedAt time.Time `json:"ticket_deleted_at"`
+}
+
+// ToTrashedResponse converts a trashed Ticket to a TrashedTicketResponse
+func (t *Ticket) ToTrashedResponse(username string) TrashedTicketResponse {
+	return TrashedTicketResponse{
+		BasicTicketResponse: t.ToBasicResponse(username),
+		DeletedAt:           t.DeletedAt.Time,
+	}
+}
+
+// TrashedUserResponse is a user of the trash listing
+type TrashedUserResponse struct {
+	ResponseUser
+	DeletedAt time.Time `json:"user_deleted_at"`
+}
+
+// ToTrashedResponse converts a trashed User to a TrashedUserResponse
+func (u *User) ToTrashedResponse() TrashedUserResponse {
+	return TrashedUserResponse{
+		ResponseUser: u.ToResponse(true),
+		DeletedAt:    u.DeletedAt.Time,
+	}
+}
@@ -16,15 +16,19 @@ const (
 )
 
 type User struct {
-	ID        uint       `json:"user_id" gorm:"primaryKey"`
-	Username  string     `json:"user_name" gorm:"size:255;not null"`
-	Email     string     `json:"user_email" gorm:"size:255;unique;not null"`
-	Password  string     `json:"user_password,omitempty" gorm:"size:255;not null"`
-	Role      Role       `json:"user_role" gorm:"type:varchar(10);default:user;not null"`
-	CreatedAt time.Time  `json:"user_created_at"`
-	UpdatedAt time.Time  `json:"user_updated_at"`
-	DeletedAt *time.Time `json:"-" gorm:"index"`
-	Tickets   []Ticket   `json:"-" gorm:"foreignKey:AuthorID"`
+	ID       uint   `json:"user_id" gorm:"primaryKey"`
+	Username string `json:"user_name" gorm:"size:255;not null"`
+	Email    string `json:"user_email" gorm:"size:255;unique;not null"`
+	Password string `json:"user_password,omitempty" gorm:"size:255;not null"`
+	Role     Role   `json:"user_role" gorm:"type:varchar(10);default:user;not null"`
+	// EmailVerifiedAt is when the user proved the email is theirs, nil until then
+	EmailVerifiedAt *time.Time `json:"user_email_verified_at,omitempty"`
+	// ServiceAccount users are machines, they use API keys and can't log in
+	ServiceAccount bool           `json:"user_service_account" gorm:"not null;default:false"`
+	CreatedAt      time.Time      `json:"user_created_at"`
+	UpdatedAt      time.Time      `json:"user_updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+	Tickets        []Ticket       `json:"-" gorm:"foreignKey:AuthorID"`
 }
 
 // BeforeSave hashs the password before saving
@@ -47,18 +51,22 @@ func (u *User) ComparePassword(password string) error {
 
 // ResponseUser is the data structure for user responses to avoid returning sensitive data
 type ResponseUser struct {
-	Username  string    `json:"user_name"`
-	Email     string    `json:"user_email"`
-	Role      Role      `json:"user_role"`
-	CreatedAt time.Time `json:"user_created_at,omitempty"`
+	Username       string    `json:"user_name"`
+	Email          string    `json:"user_email"`
+	EmailVerified  bool      `json:"user_email_verified"`
+	ServiceAccount bool      `json:"user_service_account"`
+	Role           Role      `json:"user_role"`
+	CreatedAt      time.Time `json:"user_created_at,omitempty"`
 }
 
 // ToResponse converts a User to a ResponseUser
 func (u *User) ToResponse(includeCreatedAt bool) ResponseUser {
 	response := ResponseUser{
-		Username: u.Username,
-		Email:    u.Email,
-		Role:     u.Role,
+		Username:       u.Username,
+		Email:          u.Email,
+		EmailVerified:  u.EmailVerifiedAt != nil,
+		ServiceAccount: u.ServiceAccount,
+		Role:           u.Role,
 	}
 
 	if includeCreatedAt {
@@ -0,0 +1,355 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"hcall/api/config"
+	"hcall/api/logger"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// discoveryTTL is how long the metadata and the keys of the provider are kept before being fetched again
+	discoveryTTL = time.Hour
+	// keysRefreshInterval limits how often the keys are fetched again for an unknown key id
+	keysRefreshInterval = time.Minute
+	// maxResponseSize limits the responses read from the provider
+	maxResponseSize = 1 << 20
+)
+
+type Options struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string // empty for a public client
+	RedirectURL  string
+	Scopes       []string
+	GroupsClaim  string
+}
+
+// Claims are what the hcall login uses from a verified ID token
+type Claims struct {
+	Issuer            string
+	Subject           string
+	Email             string
+	EmailVerified     *bool // nil when the provider doesn't tell
+	Name              string
+	PreferredUsername string
+	Groups            []string
+}
+
+// metadata is the part of the discovery document of the provider hcall uses
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider logs users in with an OpenID Connect provider, using the authorization code flow with
+// PKCE. Its metadata and keys are discovered on first use and kept for an hour.
+type Provider struct {
+	options Options
+	client  *http.Client
+
+	mu            sync.Mutex
+	metadata      *metadata
+	discoveredAt  time.Time
+	keys          map[string]interface{}
+	keysFetchedAt time.Time
+}
+
+var Default *Provider
+
+// InitOIDC initializes the provider of OIDC_ISSUER when single sign-on is enabled. Nothing is
+// fetched until the first login, so the server starts while the provider is down.
+func InitOIDC() {
+	if !config.AppConfig.OIDCEnabled {
+		return
+	}
+
+	Default = NewProvider(Options{
+		Issuer:       config.AppConfig.OIDCIssuer,
+		ClientID:     config.AppConfig.OIDCClientID,
+		ClientSecret: config.AppConfig.OIDCClientSecret,
+		RedirectURL:  config.AppConfig.OIDCRedirectURL,
+		Scopes:       strings.Fields(config.AppConfig.OIDCScopes),
+		GroupsClaim:  config.AppConfig.OIDCGroupsClaim,
+	})
+	logger.Info("OIDC: Single sign-on enabled", map[string]interface{}{
+		"issuer": config.AppConfig.OIDCIssuer,
+	})
+}
+
+func NewProvider(options Options) *Provider {
+	return &Provider{
+		options: options,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// NewCodeVerifier generates a random PKCE code verifier
+func NewCodeVerifier() (string, error) {
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// CodeChallenge is the S256 PKCE challenge of a code verifier
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// getJSON fetches a JSON document of the provider
+func (p *Provider) getJSON(ctx context.Context, endpoint string, dest interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+
+	response, err := p.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered %d", endpoint, response.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(response.Body, maxResponseSize)).Decode(dest)
+}
+
+// discover gets the metadata of the provider, fetching it again once it is too old
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil && time.Since(p.discoveredAt) < discoveryTTL {
+		return p.metadata, nil
+	}
+
+	var discovered metadata
+	if err := p.getJSON(ctx, p.options.Issuer+"/.well-known/openid-configuration", &discovered); err != nil {
+		return nil, err
+	}
+
+	// The issuer of the document must be the configured one (OpenID Connect Discovery 4.3)
+	if strings.TrimSuffix(discovered.Issuer, "/") != p.options.Issuer {
+		return nil, fmt.Errorf("discovery issuer %q doesn't match %q", discovered.Issuer, p.options.Issuer)
+	}
+	if discovered.AuthorizationEndpoint == "" || discovered.TokenEndpoint == "" || discovered.JWKSURI == "" {
+		return nil, errors.New("discovery document is missing endpoints")
+	}
+
+	p.metadata = &discovered
+	p.discoveredAt = time.Now()
+	p.keys = nil
+	return p.metadata, nil
+}
+
+// key gets the public key with the id, fetching the keys again when it is unknown since the
+// provider may have rotated them
+func (p *Provider) key(ctx context.Context, kid string) (interface{}, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok && time.Since(p.keysFetchedAt) < discoveryTTL {
+		return key, nil
+	}
+
+	if p.keys != nil && time.Since(p.keysFetchedAt) < keysRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	var set jwkSet
+	if err := p.getJSON(ctx, meta.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+
+	p.keys = set.publicKeys()
+	p.keysFetchedAt = time.Now()
+
+	// Providers with a single key may leave out its id
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, nil
+		}
+	}
+
+	key, ok := p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// AuthCodeURL is the URL of the provider the user is sent to, to log in and come back to the
+// redirect URL with a code
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	endpoint, err := url.Parse(meta.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+
+	query := endpoint.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.options.ClientID)
+	query.Set("redirect_uri", p.options.RedirectURL)
+	query.Set("scope", strings.Join(p.options.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+	endpoint.RawQuery = query.Encode()
+
+	return endpoint.String(), nil
+}
+
+// Exchange trades the code the user came back with for the tokens of the provider, and returns
+// the claims of the verified ID token
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Claims, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.options.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+	if p.options.ClientSecret == "" {
+		form.Set("client_id", p.options.ClientID)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	if p.options.ClientSecret != "" {
+		request.SetBasicAuth(url.QueryEscape(p.options.ClientID), url.QueryEscape(p.options.ClientSecret))
+	}
+
+	response, err := p.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	var tokens struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(response.Body, maxResponseSize)).Decode(&tokens); err != nil {
+		return nil, fmt.Errorf("token endpoint answered %d", response.StatusCode)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint refused the code: %s %s", tokens.Error, tokens.ErrorDescription)
+	}
+
+	if tokens.IDToken == "" {
+		return nil, errors.New("token endpoint gave no id token")
+	}
+
+	return p.verify(ctx, tokens.IDToken, nonce)
+}
+
+// verify checks the signature, issuer, audience, expiry and nonce of an ID token
+func (p *Provider) verify(ctx context.Context, idToken, nonce string) (*Claims, error) {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(p.options.Issuer),
+		jwt.WithAudience(p.options.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+
+	mapClaims := jwt.MapClaims{}
+	_, err := parser.ParseWithClaims(idToken, mapClaims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if tokenNonce, _ := mapClaims["nonce"].(string); tokenNonce == "" || tokenNonce != nonce {
+		return nil, errors.New("id token nonce doesn't match")
+	}
+
+	// A token for several clients must name this one as its authorized party (OpenID Connect Core 3.1.3.7)
+	audience, _ := mapClaims.GetAudience()
+	if azp, ok := mapClaims["azp"].(string); (ok || len(audience) > 1) && azp != p.options.ClientID {
+		return nil, errors.New("id token authorized party doesn't match")
+	}
+
+	claims := &Claims{Issuer: p.options.Issuer}
+	claims.Subject, _ = mapClaims["sub"].(string)
+	claims.Email, _ = mapClaims["email"].(string)
+	claims.Name, _ = mapClaims["name"].(string)
+	claims.PreferredUsername, _ = mapClaims["preferred_username"].(string)
+	if claims.Subject == "" {
+		return nil, errors.New("id token has no subject")
+	}
+
+	// Some providers give email_verified as a string
+	switch verified := mapClaims["email_verified"].(type) {
+	case bool:
+		claims.EmailVerified = &verified
+	case string:
+		value := verified == "true"
+		claims.EmailVerified = &value
+	}
+
+	claims.Groups = stringList(mapClaims[p.options.GroupsClaim])
+	return claims, nil
+}
+
+// stringList reads a claim holding a list of strings, or a single string
+func stringList(value interface{}) []string {
+	switch list := value.(type) {
+	case string:
+		return []string{list}
+	case []interface{}:
+		values := make([]string, 0, len(list))
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,583 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID    = "hcall"
+	testRedirectURL = "https://hcall.example.com/sso/callback"
+)
+
+// testKey is a signing key of the test identity provider
+type testKey struct {
+	kid    string
+	method jwt.SigningMethod
+	key    interface{}
+}
+
+func (k testKey) jwk() map[string]string {
+	switch key := k.key.(type) {
+	case *rsa.PrivateKey:
+		return map[string]string{
+			"kid": k.kid, "kty": "RSA", "use": "sig", "alg": k.method.Alg(),
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}
+	case *ecdsa.PrivateKey:
+		return map[string]string{
+			"kid": k.kid, "kty": "EC", "use": "sig", "alg": k.method.Alg(), "crv": key.Curve.Params().Name,
+			"x": base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+			"y": base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+		}
+	}
+	return nil
+}
+
+// pendingLogin is a code the test identity provider gave, waiting to be exchanged
+type pendingLogin struct {
+	challenge string
+	idToken   string
+}
+
+// testProvider is an OpenID Connect identity provider signing its ID tokens with its own keys
+type testProvider struct {
+	t      *testing.T
+	server *httptest.Server
+	secret string // the client authenticates with it when set
+
+	mu            sync.Mutex
+	keys          []testKey
+	issuer        string // issuer of the discovery document, the server URL when empty
+	discoveries   int
+	keysFetches   int
+	logins        map[string]pendingLogin
+	lastTokenForm url.Values
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating the RSA key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating the EC key: %v", err)
+	}
+
+	p := &testProvider{
+		t: t,
+		keys: []testKey{
+			{kid: "rsa-1", method: jwt.SigningMethodRS256, key: rsaKey},
+			{kid: "ec-1", method: jwt.SigningMethodES256, key: ecKey},
+		},
+		logins: map[string]pendingLogin{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/jwks", p.jwks)
+	mux.HandleFunc("/token", p.token)
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *testProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.discoveries++
+	issuer := p.issuer
+	if issuer == "" {
+		issuer = p.server.URL
+	}
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 issuer,
+		"authorization_endpoint": p.server.URL + "/authorize",
+		"token_endpoint":         p.server.URL + "/token",
+		"jwks_uri":               p.server.URL + "/jwks",
+	})
+}
+
+func (p *testProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.keysFetches++
+	keys := make([]map[string]string, 0, len(p.keys))
+	for _, key := range p.keys {
+		keys = append(keys, key.jwk())
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+}
+
+// token exchanges a code for its ID token once the PKCE verifier and the client are checked
+func (p *testProvider) token(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	refuse := func(code string) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": code})
+	}
+
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		refuse("invalid_request")
+		return
+	}
+	p.lastTokenForm = r.PostForm
+
+	if p.secret != "" {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != testClientID || secret != url.QueryEscape(p.secret) {
+			refuse("invalid_client")
+			return
+		}
+	} else if r.PostForm.Get("client_id") != testClientID {
+		refuse("invalid_client")
+		return
+	}
+
+	login, ok := p.logins[r.PostForm.Get("code")]
+	delete(p.logins, r.PostForm.Get("code"))
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("redirect_uri") != testRedirectURL {
+		refuse("invalid_grant")
+		return
+	}
+
+	if CodeChallenge(r.PostForm.Get("code_verifier")) != login.challenge {
+		refuse("invalid_grant")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"id_token":     login.idToken,
+	})
+}
+
+// client is a provider of hcall configured for the test identity provider
+func (p *testProvider) client() *Provider {
+	return NewProvider(Options{
+		Issuer:       p.server.URL,
+		ClientID:     testClientID,
+		ClientSecret: p.secret,
+		RedirectURL:  testRedirectURL,
+		Scopes:       []string{"openid", "email", "profile"},
+		GroupsClaim:  "groups",
+	})
+}
+
+// sign makes an ID token with the key
+func (p *testProvider) sign(key testKey, claims jwt.MapClaims) string {
+	p.t.Helper()
+
+	token := jwt.NewWithClaims(key.method, claims)
+	if key.kid != "" {
+		token.Header["kid"] = key.kid
+	}
+	signed, err := token.SignedString(key.key)
+	if err != nil {
+		p.t.Fatalf("signing the ID token: %v", err)
+	}
+	return signed
+}
+
+// login runs a login of hcall up to the code the user comes back with. The ID token has valid
+// claims for the nonce, edit changes them and signs the token, nil signs them with the first key.
+// It returns the code, the PKCE verifier and the nonce.
+func (p *testProvider) login(provider *Provider, edit func(claims jwt.MapClaims) string) (string, string, string) {
+	p.t.Helper()
+
+	verifier, err := NewCodeVerifier()
+	if err != nil {
+		p.t.Fatalf("NewCodeVerifier: %v", err)
+	}
+
+	authURL, err := provider.AuthCodeURL(context.Background(), "the-state", "the-nonce", CodeChallenge(verifier))
+	if err != nil {
+		p.t.Fatalf("AuthCodeURL: %v", err)
+	}
+
+	parsed, err := url.Parse(authURL)
+	if err != nil || !strings.HasPrefix(authURL, p.server.URL+"/authorize?") {
+		p.t.Fatalf("authorization URL %q isn't the endpoint of the provider", authURL)
+	}
+	query := parsed.Query()
+	expected := map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          testRedirectURL,
+		"scope":                 "openid email profile",
+		"state":                 "the-state",
+		"nonce":                 "the-nonce",
+		"code_challenge":        CodeChallenge(verifier),
+		"code_challenge_method": "S256",
+	}
+	for name, value := range expected {
+		if got := query.Get(name); got != value {
+			p.t.Errorf("authorization URL has %s=%q, want %q", name, got, value)
+		}
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            p.server.URL,
+		"sub":            "user-42",
+		"aud":            testClientID,
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          query.Get("nonce"),
+		"email":          "janesmith@example.com",
+		"email_verified": true,
+		"name":           "Jane Smith",
+		"groups":         []string{"staff", "helpdesk-admins"},
+	}
+
+	var idToken string
+	if edit != nil {
+		idToken = edit(claims)
+	}
+	if idToken == "" {
+		idToken = p.sign(p.keys[0], claims)
+	}
+
+	code := "code-" + verifier[:8]
+	p.mu.Lock()
+	p.logins[code] = pendingLogin{challenge: query.Get("code_challenge"), idToken: idToken}
+	p.mu.Unlock()
+
+	return code, verifier, query.Get("nonce")
+}
+
+func TestExchangeRSA(t *testing.T) {
+	p := newTestProvider(t)
+	provider := p.client()
+
+	code, verifier, nonce := p.login(provider, nil)
+	claims, err := provider.Exchange(context.Background(), code, verifier, nonce)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	if claims.Issuer != p.server.URL || claims.Subject != "user-42" || claims.Email != "janesmith@example.com" || claims.Name != "Jane Smith" {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+	if claims.EmailVerified == nil || !*claims.EmailVerified {
+		t.Errorf("email_verified is %v, want true", claims.EmailVerified)
+	}
+	if strings.Join(claims.Groups, ",") != "staff,helpdesk-admins" {
+		t.Errorf("groups are %q", claims.Groups)
+	}
+
+	// A public client sends its id with the PKCE verifier, no secret
+	if p.lastTokenForm.Get("client_id") != testClientID || p.lastTokenForm.Get("code_verifier") != verifier {
+		t.Errorf("token request form %v", p.lastTokenForm)
+	}
+
+	// The code can only be used once
+	if _, err := provider.Exchange(context.Background(), code, verifier, nonce); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("second exchange of the code returned %v, want invalid_grant", err)
+	}
+}
+
+func TestExchangeEC(t *testing.T) {
+	p := newTestProvider(t)
+	provider := p.client()
+
+	code, verifier, nonce := p.login(provider, func(claims jwt.MapClaims) string {
+		// Some providers give email_verified as a string and a single group as a string
+		claims["email_verified"] = "false"
+		claims["groups"] = "staff"
+		return p.sign(p.keys[1], claims)
+	})
+	claims, err := provider.Exchange(context.Background(), code, verifier, nonce)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	if claims.EmailVerified == nil || *claims.EmailVerified {
+		t.Errorf("email_verified is %v, want false", claims.EmailVerified)
+	}
+	if len(claims.Groups) != 1 || claims.Groups[0] != "staff" {
+		t.Errorf("groups are %q, want [staff]", claims.Groups)
+	}
+}
+
+func TestExchangeConfidentialClient(t *testing.T) {
+	p := newTestProvider(t)
+	p.secret = "s3cret:with/specials"
+	provider := p.client()
+
+	code, verifier, nonce := p.login(provider, nil)
+	if _, err := provider.Exchange(context.Background(), code, verifier, nonce); err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if p.lastTokenForm.Has("client_id") {
+		t.Error("a confidential client sent its id in the form instead of the basic auth")
+	}
+
+	provider.options.ClientSecret = "wrong"
+	code, verifier, nonce = p.login(provider, nil)
+	if _, err := provider.Exchange(context.Background(), code, verifier, nonce); err == nil || !strings.Contains(err.Error(), "invalid_client") {
+		t.Errorf("Exchange with a wrong secret returned %v, want invalid_client", err)
+	}
+}
+
+func TestExchangeRejects(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating the RSA key: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		edit     func(p *testProvider, claims jwt.MapClaims) string
+		verifier string // replaces the PKCE verifier when set
+		nonce    string // replaces the nonce when set
+		err      string
+	}{
+		{
+			name:     "wrong PKCE verifier",
+			verifier: "not-the-verifier-of-the-challenge",
+			err:      "invalid_grant",
+		},
+		{
+			name:  "wrong nonce",
+			nonce: "another-nonce",
+			err:   "id token nonce doesn't match",
+		},
+		{
+			name: "missing nonce",
+			edit: func(p *testProvider, claims jwt.MapClaims) string {
+				delete(claims, "nonce")
+				return ""
+			},
+			err: "id token nonce doesn't match",
+		},
+		{
+			name: "wrong issuer",
+			edit: func(p *testProvider, claims jwt.MapClaims) string {
+				claims["iss"] = "https://evil.example.com"
+				return ""
+			},
+			err: "invalid issuer",
+		},
+		{
+			name: "wrong audience",
+			edit: func(p *testProvider, claims jwt.MapClaims) string {
+				claims["aud"] = "another-client"
+				return ""
+			},
+			err: "invalid audience",
+		},
+		{
+			name: "several audiences without authorized party",
+			edit: func(p *testProvider, claims jwt.MapClaims) string {
+				claims["aud"] = []string{testClientID, "another-client"}
+				return ""
+			},
+			err: "authorized party doesn't match",
+		},
+		{
+			name: "authorized party of another client",
+			edit: func(p *testProvider, claims jwt.MapClaims) string {
+				claims["azp"] = "another-client"
+				return ""
+			},
+			err: "authorized party doesn't match",
+		},
+		{
+			name: "expired",
+			edit: func(p *testProvider, claims jwt.MapClaims) string {
+				claims["exp"] = time.Now().Add(-10 * time.Minute).Unix()
+				return ""
+			},
+			err: "token is expired",
+		},
+		{
+			name: "no expiry",
+			edit: func(p *testProvider, claims jwt.MapClaims) string {
+				delete(claims, "exp")
+				return ""
+			},
+			err: "exp claim is required",
+		},
+		{
+			name: "no subject",
+			edit: func(p *testProvider, claims jwt.MapClaims) string {
+				delete(claims, "sub")
+				return ""
+			},
+			err: "id token has no subject",
+		},
+		{
+			name: "signed by another key",
+			edit: func(p *testProvider, claims jwt.MapClaims) string {
+				return p.sign(testKey{kid: "rsa-1", method: jwt.SigningMethodRS256, key: otherKey}, claims)
+			},
+			err: "verification error",
+		},
+		{
+			name: "unknown key id",
+			edit: func(p *testProvider, claims jwt.MapClaims) string {
+				return p.sign(testKey{kid: "rsa-2", method: jwt.SigningMethodRS256, key: otherKey}, claims)
+			},
+			err: `unknown signing key "rsa-2"`,
+		},
+		{
+			name: "symmetric signature with the client id",
+			edit: func(p *testProvider, claims jwt.MapClaims) string {
+				return p.sign(testKey{kid: "rsa-1", method: jwt.SigningMethodHS256, key: []byte(testClientID)}, claims)
+			},
+			err: "signing method HS256 is invalid",
+		},
+		{
+			name: "unsigned",
+			edit: func(p *testProvider, claims jwt.MapClaims) string {
+				return p.sign(testKey{kid: "rsa-1", method: jwt.SigningMethodNone, key: jwt.UnsafeAllowNoneSignatureType}, claims)
+			},
+			err: "signing method none is invalid",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newTestProvider(t)
+			provider := p.client()
+
+			var edit func(claims jwt.MapClaims) string
+			if test.edit != nil {
+				edit = func(claims jwt.MapClaims) string { return test.edit(p, claims) }
+			}
+			code, verifier, nonce := p.login(provider, edit)
+			if test.verifier != "" {
+				verifier = test.verifier
+			}
+			if test.nonce != "" {
+				nonce = test.nonce
+			}
+
+			claims, err := provider.Exchange(context.Background(), code, verifier, nonce)
+			if err == nil {
+				t.Fatalf("Exchange accepted the login, claims %+v", claims)
+			}
+			if !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Exchange returned %q, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestDiscovery(t *testing.T) {
+	p := newTestProvider(t)
+	provider := p.client()
+
+	for i := 0; i < 2; i++ {
+		code, verifier, nonce := p.login(provider, nil)
+		if _, err := provider.Exchange(context.Background(), code, verifier, nonce); err != nil {
+			t.Fatalf("Exchange %d: %v", i+1, err)
+		}
+	}
+
+	// The document and the keys are kept between logins
+	if p.discoveries != 1 || p.keysFetches != 1 {
+		t.Errorf("discovery fetched %d times and keys %d times, want once each", p.discoveries, p.keysFetches)
+	}
+
+	// A trailing slash on the issuer of the document is accepted
+	p.issuer = p.server.URL + "/"
+	if _, err := p.client().AuthCodeURL(context.Background(), "state", "nonce", "challenge"); err != nil {
+		t.Errorf("AuthCodeURL with a trailing slash issuer: %v", err)
+	}
+
+	// The document of another issuer is refused
+	p.issuer = "https://evil.example.com"
+	_, err := p.client().AuthCodeURL(context.Background(), "state", "nonce", "challenge")
+	if err == nil || !strings.Contains(err.Error(), "discovery issuer") {
+		t.Errorf("AuthCodeURL with another issuer returned %v", err)
+	}
+
+	// A provider that isn't reachable fails the login
+	down := NewProvider(Options{Issuer: "http://127.0.0.1:1", ClientID: testClientID})
+	if _, err := down.AuthCodeURL(context.Background(), "state", "nonce", "challenge"); err == nil {
+		t.Error("AuthCodeURL succeeded with an unreachable provider")
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	p := newTestProvider(t)
+	provider := p.client()
+
+	code, verifier, nonce := p.login(provider, nil)
+	if _, err := provider.Exchange(context.Background(), code, verifier, nonce); err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating the RSA key: %v", err)
+	}
+	rotated := testKey{kid: "rsa-2", method: jwt.SigningMethodRS256, key: newKey}
+	p.mu.Lock()
+	p.keys = append(p.keys, rotated)
+	p.mu.Unlock()
+
+	signWithRotated := func(claims jwt.MapClaims) string { return p.sign(rotated, claims) }
+
+	// Unknown key ids don't make the keys be fetched again right away
+	code, verifier, nonce = p.login(provider, signWithRotated)
+	if _, err := provider.Exchange(context.Background(), code, verifier, nonce); err == nil || !strings.Contains(err.Error(), "unknown signing key") {
+		t.Fatalf("Exchange right after the rotation returned %v, want unknown signing key", err)
+	}
+
+	provider.mu.Lock()
+	provider.keysFetchedAt = time.Now().Add(-keysRefreshInterval - time.Second)
+	provider.mu.Unlock()
+
+	code, verifier, nonce = p.login(provider, signWithRotated)
+	if _, err := provider.Exchange(context.Background(), code, verifier, nonce); err != nil {
+		t.Fatalf("Exchange once the keys can be fetched again: %v", err)
+	}
+	if p.keysFetches != 2 {
+		t.Errorf("keys fetched %d times, want 2", p.keysFetches)
+	}
+}
+
+func TestCodeChallenge(t *testing.T) {
+	// RFC 7636 appendix B
+	if challenge := CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"); challenge != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Errorf("CodeChallenge is %q", challenge)
+	}
+
+	first, err := NewCodeVerifier()
+	if err != nil {
+		t.Fatalf("NewCodeVerifier: %v", err)
+	}
+	second, _ := NewCodeVerifier()
+	if len(first) < 43 || first == second {
+		t.Errorf("code verifiers %q and %q are too short or repeated", first, second)
+	}
+}
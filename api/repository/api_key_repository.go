@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"hcall/api/database"
+	"hcall/api/models"
+
+	"gorm.io/gorm"
+)
+
+// apiKeyTouchInterval is how often the last use of a key is recorded, so busy integrations don't
+// write on every request
+const apiKeyTouchInterval = time.Minute
+
+type APIKeyRepository struct {
+	DB *gorm.DB
+}
+
+func NewAPIKeyRepository() *APIKeyRepository {
+	return &APIKeyRepository{
+		DB: database.DB,
+	}
+}
+
+// CreateKey saves a new API key
+func (r *APIKeyRepository) CreateKey(key *models.APIKey) error {
+	return r.DB.Create(key).Error
+}
+
+// GetKeys gets the API keys of a service account, revoked and expired ones included
+func (r *APIKeyRepository) GetKeys(userID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := r.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+// revokeAPIKeys revokes the keys selected by the query that weren't revoked yet, returning how many were revoked
+func revokeAPIKeys(tx *gorm.DB, now time.Time, query interface{}, args ...interface{}) (int64, error) {
+	result := tx.Model(&models.APIKey{}).Where(query, args...).Where("revoked_at IS NULL").Update("revoked_at", now)
+	return result.RowsAffected, result.Error
+}
+
+// RevokeKey revokes an API key of a service account, it can't be used anymore
+func (r *APIKeyRepository) RevokeKey(id, userID uint) error {
+	revoked, err := revokeAPIKeys(r.DB, time.Now(), "id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+
+	if revoked == 0 {
+		return errors.New("api key not found")
+	}
+	return nil
+}
+
+// FindActiveKey finds the API key with the hash and its service account, when the key wasn't
+// revoked, didn't expire and its service account isn't trashed
+func (r *APIKeyRepository) FindActiveKey(hash string, now time.Time) (*models.APIKey, *models.User, error) {
+	var key models.APIKey
+	result := r.DB.Where("key_hash = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", hash, now).
+		First(&key)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil, errors.New("invalid api key")
+	}
+
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+
+	var user models.User
+	result = r.DB.Where("id = ? AND service_account = ?", key.UserID, true).First(&user)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil, errors.New("invalid api key")
+	}
+
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+
+	return &key, &user, nil
+}
+
+// TouchKey records the use of an API key, at most once per interval
+func (r *APIKeyRepository) TouchKey(id uint, now time.Time) error {
+	return r.DB.Model(&models.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, now.Add(-apiKeyTouchInterval)).
+		UpdateColumn("last_used_at", now).Error
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"hcall/api/database"
+	"hcall/api/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ArchiveRepository struct {
+	DB   *gorm.DB
+	page *models.Page
+}
+
+func NewArchiveRepository() *ArchiveRepository {
+	return &ArchiveRepository{
+		DB: database.DB,
+	}
+}
+
+// Paginate returns a copy of the repository whose archive searches only return the given page.
+// The page total and next cursor are filled by the search.
+func (r *ArchiveRepository) Paginate(page *models.Page) *ArchiveRepository {
+	return &ArchiveRepository{
+		DB:   r.DB,
+		page: page,
+	}
+}
+
+// ArchiveFilter holds the criteria of an archive search, the empty ones are ignored
+type ArchiveFilter struct {
+	Text         string // Part of the ticket name or ID
+	AuthorEmail  string
+	Statuses     []models.TicketStatus
+	Queue        string
+	Tag          string
+	ArchivedFrom *time.Time
+	ArchivedTo   *time.Time
+}
+
+// archiveSortColumns are the fields archive searches can be sorted by
+var archiveSortColumns = map[string]sortColumn[models.ArchivedTicket]{
+	"created":  timeColumn("archived_tickets.ticket_created_at", func(a *models.ArchivedTicket) time.Time { return a.TicketCreatedAt }),
+	"archived": timeColumn("archived_tickets.archived_at", func(a *models.ArchivedTicket) time.Time { return a.ArchivedAt }),
+}
+
+// SaveEntries indexes the tickets written to an archive file. A ticket archived again after
+// being restored points to its latest archive.
+func (r *ArchiveRepository) SaveEntries(entries []models.ArchivedTicket) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return r.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&entries).Error
+}
+
+// GetEntry gets the index entry of an archived ticket
+func (r *ArchiveRepository) GetEntry(ticketID string) (*models.ArchivedTicket, error) {
+	var entry models.ArchivedTicket
+	result := r.DB.Where("ticket_id = ?", ticketID).First(&entry)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, errors.New("ticket not found in archive")
+	}
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &entry, nil
+}
+
+// SearchEntries gets the archived tickets matching the filter, most recently archived first
+func (r *ArchiveRepository) SearchEntries(filter ArchiveFilter) ([]models.ArchivedTicket, error) {
+	query := r.DB.Model(&models.ArchivedTicket{})
+
+	if filter.Text != "" {
+		pattern := "%" + filter.Text + "%"
+		query = query.Where("archived_tickets.name ILIKE ? OR archived_tickets.ticket_id ILIKE ?", pattern, pattern)
+	}
+	if filter.AuthorEmail != "" {
+		query = query.Where("archived_tickets.author_email = ?", filter.AuthorEmail)
+	}
+	if len(filter.Statuses) > 0 {
+		query = query.Where("archived_tickets.status IN ?", filter.Statuses)
+	}
+	if filter.Queue != "" {
+		query = query.Where("archived_tickets.queue = ?", filter.Queue)
+	}
+	if filter.Tag != "" {
+		query = query.Where("',' || archived_tickets.tags || ',' LIKE ?", "%,"+filter.Tag+",%")
+	}
+	if filter.ArchivedFrom != nil {
+		query = query.Where("archived_tickets.archived_at >= ?", *filter.ArchivedFrom)
+	}
+	if filter.ArchivedTo != nil {
+		query = query.Where("archived_tickets.archived_at < ?", *filter.ArchivedTo)
+	}
+
+	if r.page != nil {
+		return findPage(query, r.page, archiveSortColumns, "archived_tickets.ticket_id", func(a *models.ArchivedTicket) string { return a.TicketID })
+	}
+
+	var entries []models.ArchivedTicket
+	if err := query.Order("archived_tickets.archived_at DESC, archived_tickets.ticket_id DESC").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// MarkRestored records that an archived ticket was brought back
+func (r *ArchiveRepository) MarkRestored(ticketID string, restoredAt time.Time) error {
+	return r.DB.Model(&models.ArchivedTicket{}).Where("ticket_id = ?", ticketID).Update("restored_at", restoredAt).Error
+}
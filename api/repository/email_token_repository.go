@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"hcall/api/database"
+	"hcall/api/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type EmailTokenRepository struct {
+	DB *gorm.DB
+}
+
+func NewEmailTokenRepository() *EmailTokenRepository {
+	return &EmailTokenRepository{
+		DB: database.DB,
+	}
+}
+
+// RecordRequest records a request to send a token to the email and counts the requests for it
+// during the window, this one included
+func (r *EmailTokenRepository) RecordRequest(email string, purpose models.EmailTokenPurpose, window time.Duration) (int64, error) {
+	var count int64
+	err := database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		request := &models.EmailRequest{Email: email, Purpose: purpose}
+		if err := tx.Create(request).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.EmailRequest{}).
+			Where("email = ? AND purpose = ? AND created_at > ?", email, purpose, request.CreatedAt.Add(-window)).
+			Count(&count).Error
+	})
+	return count, err
+}
+
+// CreateToken saves a new token, the tokens of the user for the same purpose that weren't used
+// can't be used anymore
+func (r *EmailTokenRepository) CreateToken(token *models.EmailToken) error {
+	return database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		err := tx.Model(&models.EmailToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", token.UserID, token.Purpose).
+			Update("used_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(token).Error
+	})
+}
+
+// consumeToken marks a token as used, failing when it doesn't exist, was already used or expired
+func consumeToken(tx *gorm.DB, hash string, purpose models.EmailTokenPurpose, now time.Time) (*models.EmailToken, error) {
+	var token models.EmailToken
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("token_hash = ? AND purpose = ?", hash, purpose).
+		First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("invalid email token")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if token.UsedAt != nil || !token.ExpiresAt.After(now) {
+		return nil, errors.New("invalid email token")
+	}
+
+	if err := tx.Model(&token).Update("used_at", now).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// VerifyEmail uses a verification token, marking the email of its user as verified
+func (r *EmailTokenRepository) VerifyEmail(hash string) error {
+	return database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		now := time.Now()
+		token, err := consumeToken(tx, hash, models.VerifyEmailPurpose, now)
+		if err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.User{}).Where("id = ?", token.UserID).
+			UpdateColumn("email_verified_at", gorm.Expr("COALESCE(email_verified_at, ?)", now))
+		if result.Error != nil {
+			return result.Error
+		}
+
+		// Trashed users can't verify their email
+		if result.RowsAffected == 0 {
+			return errors.New("invalid email token")
+		}
+		return nil
+	})
+}
+
+// ResetPassword uses a password reset token, replacing the password of its user with the hashed
+// one and revoking every session of the user. As the token was sent by email, it also verifies
+// the email.
+func (r *EmailTokenRepository) ResetPassword(hash, passwordHash string) error {
+	return database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		now := time.Now()
+		token, err := consumeToken(tx, hash, models.ResetPasswordPurpose, now)
+		if err != nil {
+			return err
+		}
+
+		// The password is already hashed, the hooks would hash it again
+		result := tx.Model(&models.User{}).Where("id = ?", token.UserID).UpdateColumns(map[string]interface{}{
+			"password":          passwordHash,
+			"email_verified_at": gorm.Expr("COALESCE(email_verified_at, ?)", now),
+			"updated_at":        now,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return errors.New("invalid email token")
+		}
+
+		_, err = revokeSessions(tx, now, "user_id = ?", token.UserID)
+		return err
+	})
+}
+
+// PurgeExpired deletes the tokens that expired and the requests older than the rate limit window,
+// returning how many of each were deleted
+func (r *EmailTokenRepository) PurgeExpired(now time.Time, window time.Duration) (int64, int64, error) {
+	tokens := r.DB.Where("expires_at < ?", now).Delete(&models.EmailToken{})
+	if tokens.Error != nil {
+		return 0, 0, tokens.Error
+	}
+
+	requests := r.DB.Where("created_at < ?", now.Add(-window)).Delete(&models.EmailRequest{})
+	if requests.Error != nil {
+		return tokens.RowsAffected, 0, requests.Error
+	}
+
+	return tokens.RowsAffected, requests.RowsAffected, nil
+}
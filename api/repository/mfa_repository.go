@@ -0,0 +1,233 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"hcall/api/database"
+	"hcall/api/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type MFARepository struct {
+	DB *gorm.DB
+}
+
+func NewMFARepository() *MFARepository {
+	return &MFARepository{
+		DB: database.DB,
+	}
+}
+
+// GetMFA gets the second factor of a user, enabled or pending
+func (r *MFARepository) GetMFA(userID uint) (*models.UserMFA, error) {
+	var mfa models.UserMFA
+	result := r.DB.Where("user_id = ?", userID).First(&mfa)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, errors.New("mfa not found")
+	}
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &mfa, nil
+}
+
+// SavePendingMFA replaces the pending second factor of a user with a new secret, an enabled one is kept
+func (r *MFARepository) SavePendingMFA(userID uint, secret string) error {
+	return database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		var mfa models.UserMFA
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).First(&mfa).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return tx.Create(&models.UserMFA{UserID: userID, Secret: secret}).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		if mfa.EnabledAt != nil {
+			return errors.New("mfa already enabled")
+		}
+
+		return tx.Model(&mfa).Updates(map[string]interface{}{
+			"secret":         secret,
+			"last_used_step": 0,
+		}).Error
+	})
+}
+
+// useStep records the TOTP period of an accepted code, failing when a code of that period or a
+// later one was already used
+func useStep(tx *gorm.DB, userID uint, step int64) error {
+	result := tx.Model(&models.UserMFA{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return errors.New("invalid mfa code")
+	}
+	return nil
+}
+
+// replaceRecoveryCodes replaces the recovery codes of a user with the hashed ones
+func replaceRecoveryCodes(tx *gorm.DB, userID uint, codeHashes []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+
+	codes := make([]models.RecoveryCode, len(codeHashes))
+	for i, hash := range codeHashes {
+		codes[i] = models.RecoveryCode{UserID: userID, CodeHash: hash}
+	}
+	return tx.Create(&codes).Error
+}
+
+// EnableMFA enables the pending second factor of a user, once its first code was accepted, and
+// gives it the hashed recovery codes
+func (r *MFARepository) EnableMFA(userID uint, step int64, codeHashes []string) error {
+	return database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		result := tx.Model(&models.UserMFA{}).
+			Where("user_id = ? AND enabled_at IS NULL", userID).
+			Update("enabled_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return errors.New("mfa already enabled")
+		}
+
+		if err := useStep(tx, userID, step); err != nil {
+			return err
+		}
+
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+// UseTOTPStep records the period of an accepted TOTP code, so the code can't be used again
+func (r *MFARepository) UseTOTPStep(userID uint, step int64) error {
+	return useStep(r.DB, userID, step)
+}
+
+// UseRecoveryCode uses a recovery code of a user, failing when it doesn't exist or was already used
+func (r *MFARepository) UseRecoveryCode(userID uint, codeHash string) error {
+	result := r.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return errors.New("invalid mfa code")
+	}
+	return nil
+}
+
+// ReplaceRecoveryCodes gives new hashed recovery codes to a user, the previous ones can't be used anymore
+func (r *MFARepository) ReplaceRecoveryCodes(userID uint, codeHashes []string) error {
+	return database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+// CountRecoveryCodes counts the recovery codes of a user that weren't used
+func (r *MFARepository) CountRecoveryCodes(userID uint) (int64, error) {
+	var count int64
+	err := r.DB.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
+
+// DeleteMFA removes the second factor of a user and its recovery codes. When revoke is set, every
+// session of the user is revoked too.
+func (r *MFARepository) DeleteMFA(userID uint, revoke bool) error {
+	return database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", userID).Delete(&models.MFAChallenge{}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Where("user_id = ?", userID).Delete(&models.UserMFA{})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return errors.New("mfa not found")
+		}
+
+		if revoke {
+			_, err := revokeSessions(tx, time.Now(), "user_id = ?", userID)
+			return err
+		}
+		return nil
+	})
+}
+
+// CreateChallenge saves a new login challenge
+func (r *MFARepository) CreateChallenge(challenge *models.MFAChallenge) error {
+	return r.DB.Create(challenge).Error
+}
+
+// FindChallenge finds a login challenge that can still be answered
+func (r *MFARepository) FindChallenge(tokenHash string, maxAttempts int) (*models.MFAChallenge, error) {
+	var challenge models.MFAChallenge
+	result := r.DB.Where("token_hash = ? AND used_at IS NULL AND expires_at > ? AND attempts < ?", tokenHash, time.Now(), maxAttempts).
+		First(&challenge)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, errors.New("invalid mfa token")
+	}
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &challenge, nil
+}
+
+// CountFailedAttempts counts the wrong codes given to the login challenges of a user created since the time
+func (r *MFARepository) CountFailedAttempts(userID uint, since time.Time) (int64, error) {
+	var attempts int64
+	err := r.DB.Model(&models.MFAChallenge{}).
+		Select("COALESCE(SUM(attempts), 0)").
+		Where("user_id = ? AND created_at > ?", userID, since).
+		Scan(&attempts).Error
+	return attempts, err
+}
+
+// FailChallenge counts a wrong code given to a login challenge
+func (r *MFARepository) FailChallenge(id string) error {
+	return r.DB.Model(&models.MFAChallenge{}).Where("id = ?", id).
+		UpdateColumn("attempts", gorm.Expr("attempts + 1")).Error
+}
+
+// UseChallenge marks a login challenge as answered, failing when it was answered meanwhile
+func (r *MFARepository) UseChallenge(id string) error {
+	result := r.DB.Model(&models.MFAChallenge{}).Where("id = ? AND used_at IS NULL", id).Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return errors.New("invalid mfa token")
+	}
+	return nil
+}
+
+// PurgeExpiredChallenges deletes the login challenges that expired before the time, returning how many were deleted
+func (r *MFARepository) PurgeExpiredChallenges(now time.Time) (int64, error) {
+	result := r.DB.Where("expires_at < ?", now).Delete(&models.MFAChallenge{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"hcall/api/database"
+	"hcall/api/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OIDCRepository struct {
+	DB *gorm.DB
+}
+
+func NewOIDCRepository() *OIDCRepository {
+	return &OIDCRepository{
+		DB: database.DB,
+	}
+}
+
+// CreateState saves a single sign-on login waiting for the user to come back
+func (r *OIDCRepository) CreateState(state *models.OIDCState) error {
+	return r.DB.Create(state).Error
+}
+
+// ConsumeState uses the login with the state, failing when it doesn't exist, expired or was started
+// by another client. A state can only be used once.
+func (r *OIDCRepository) ConsumeState(stateHash, tokenHash string, now time.Time) (*models.OIDCState, error) {
+	var state models.OIDCState
+	err := database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("state_hash = ?", stateHash).First(&state).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("invalid oidc state")
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Delete(&state).Error; err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if state.TokenHash != tokenHash || !state.ExpiresAt.After(now) {
+		return nil, errors.New("invalid oidc state")
+	}
+	return &state, nil
+}
+
+// FindIdentity finds the identity with the subject at the issuer
+func (r *OIDCRepository) FindIdentity(issuer, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	result := r.DB.Where("issuer = ? AND subject = ?", issuer, subject).First(&identity)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, errors.New("identity not found")
+	}
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &identity, nil
+}
+
+// LinkIdentity links an existing user to its account at the identity provider
+func (r *OIDCRepository) LinkIdentity(identity *models.UserIdentity) error {
+	return r.DB.Create(identity).Error
+}
+
+// ProvisionUser creates a user logging in with single sign-on for the first time, along with its identity
+func (r *OIDCRepository) ProvisionUser(user *models.User, identity *models.UserIdentity) error {
+	return database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+
+		identity.UserID = user.ID
+		return tx.Create(identity).Error
+	})
+}
+
+// RecordLogin records a single sign-on login of the identity, and gives its user the role mapped
+// from its groups when one is given
+func (r *OIDCRepository) RecordLogin(identity *models.UserIdentity, role models.Role, now time.Time) error {
+	return database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		if err := tx.Model(identity).UpdateColumn("last_login_at", now).Error; err != nil {
+			return err
+		}
+
+		if role == "" {
+			return nil
+		}
+
+		// The master is never changed by the identity provider
+		return tx.Model(&models.User{}).
+			Where("id = ? AND role <> ? AND role <> ?", identity.UserID, role, models.MasterRole).
+			UpdateColumns(map[string]interface{}{"role": role, "updated_at": now}).Error
+	})
+}
+
+// PurgeExpiredStates deletes the single sign-on logins that expired, returning how many were deleted
+func (r *OIDCRepository) PurgeExpiredStates(now time.Time) (int64, error) {
+	result := r.DB.Where("expires_at < ?", now).Delete(&models.OIDCState{})
+	return result.RowsAffected, result.Error
+}
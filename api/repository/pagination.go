@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"hcall/api/models"
+
+	"gorm.io/gorm"
+)
+
+// sortColumn is a field a listing of T can be sorted by
+type sortColumn[T any] struct {
+	expr  string                                // SQL expression the rows are ordered by
+	key   func(row *T) string                   // value of the expression for a row, saved in cursors
+	parse func(key string) (interface{}, error) // converts a cursor key back to a query value
+}
+
+// cursor points right after the last row of a page. It's sent to clients as an opaque string.
+type cursor struct {
+	Sort  string `json:"s"`
+	Order string `json:"o"`
+	Key   string `json:"k"`
+	ID    string `json:"i"`
+}
+
+func encodeCursor(c cursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(value string) (cursor, error) {
+	var c cursor
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return c, errors.New("invalid cursor")
+	}
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return c, errors.New("invalid cursor")
+	}
+	return c, nil
+}
+
+// timeColumn sorts by a timestamp column
+func timeColumn[T any](expr string, value func(row *T) time.Time) sortColumn[T] {
+	return sortColumn[T]{
+		expr: expr,
+		key: func(row *T) string {
+			return value(row).UTC().Format(time.RFC3339Nano)
+		},
+		parse: func(key string) (interface{}, error) {
+			return time.Parse(time.RFC3339Nano, key)
+		},
+	}
+}
+
+// rankColumn sorts by the position of a column value in the given list instead of alphabetically
+func rankColumn[T any](column string, values []string, value func(row *T) string) sortColumn[T] {
+	var expr strings.Builder
+	expr.WriteString("CASE " + column)
+	ranks := make(map[string]int, len(values))
+	for i, v := range values {
+		ranks[v] = i
+		fmt.Fprintf(&expr, " WHEN '%s' THEN %d", v, i)
+	}
+	fmt.Fprintf(&expr, " ELSE %d END", len(values))
+
+	return sortColumn[T]{
+		expr: expr.String(),
+		key: func(row *T) string {
+			rank, ok := ranks[value(row)]
+			if !ok {
+				rank = len(values)
+			}
+			return strconv.Itoa(rank)
+		},
+		parse: func(key string) (interface{}, error) {
+			return strconv.Atoi(key)
+		},
+	}
+}
+
+// findPage counts the rows matched by the query and returns the requested page of them, with the
+// given associations loaded. Pages are read from the offset, or right after the cursor when there is one.
+func findPage[T any](query *gorm.DB, page *models.Page, columns map[string]sortColumn[T], idColumn string, id func(row *T) string, preloads ...string) ([]T, error) {
+	column, ok := columns[page.Sort]
+	if !ok {
+		return nil, errors.New("invalid sort field")
+	}
+
+	if page.Order != models.SortAscending && page.Order != models.SortDescending {
+		return nil, errors.New("invalid sort order")
+	}
+
+	if err := query.Session(&gorm.Session{}).Count(&page.Total).Error; err != nil {
+		return nil, err
+	}
+
+	query = query.Session(&gorm.Session{})
+	if page.Cursor != "" {
+		after, err := decodeCursor(page.Cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		// A cursor only makes sense for the ordering it was created with
+		if after.Sort != page.Sort || after.Order != page.Order {
+			return nil, errors.New("invalid cursor")
+		}
+
+		key, err := column.parse(after.Key)
+		if err != nil {
+			return nil, errors.New("invalid cursor")
+		}
+
+		operator := ">"
+		if page.Order == models.SortDescending {
+			operator = "<"
+		}
+		query = query.Where(fmt.Sprintf("(%s, %s) %s (?, ?)", column.expr, idColumn, operator), key, after.ID)
+	} else if page.Offset > 0 {
+		query = query.Offset(page.Offset)
+	}
+
+	for _, preload := range preloads {
+		query = query.Preload(preload)
+	}
+
+	// Read one more row to know whether there is a next page
+	var rows []T
+	err := query.Order(fmt.Sprintf("%s %s, %s %s", column.expr, page.Order, idColumn, page.Order)).
+		Limit(page.Limit + 1).Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	page.NextCursor = ""
+	if len(rows) > page.Limit {
+		rows = rows[:page.Limit]
+		last := &rows[len(rows)-1]
+		page.NextCursor = encodeCursor(cursor{
+			Sort:  page.Sort,
+			Order: page.Order,
+			Key:   column.key(last),
+			ID:    id(last),
+		})
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"errors"
+
+	"hcall/api/database"
+	"hcall/api/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type QueueRepository struct {
+	DB *gorm.DB
+}
+
+func NewQueueRepository() *QueueRepository {
+	return &QueueRepository{
+		DB: database.DB,
+	}
+}
+
+// CreateQueue creates a new queue, it becomes the only default queue when flagged so
+func (r *QueueRepository) CreateQueue(queue *models.Queue) error {
+	return database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		if queue.IsDefault {
+			if err := tx.Model(&models.Queue{}).Where("is_default = ?", true).Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(queue).Error
+	})
+}
+
+// GetQueues gets all queues sorted by name
+func (r *QueueRepository) GetQueues() ([]models.Queue, error) {
+	var queues []models.Queue
+	if err := r.DB.Order("name ASC").Find(&queues).Error; err != nil {
+		return nil, err
+	}
+	return queues, nil
+}
+
+// GetQueue gets a queue by ID
+func (r *QueueRepository) GetQueue(id uint) (*models.Queue, error) {
+	var queue models.Queue
+	result := r.DB.First(&queue, id)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, errors.New("queue not found")
+	}
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &queue, nil
+}
+
+// GetQueueByName gets a queue by its name
+func (r *QueueRepository) GetQueueByName(name string) (*models.Queue, error) {
+	var queue models.Queue
+	result := r.DB.Where("name = ?", name).First(&queue)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, errors.New("queue not found")
+	}
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &queue, nil
+}
+
+// GetDefaultQueue gets the queue receiving the tickets created without one, if there is one
+func (r *QueueRepository) GetDefaultQueue() (*models.Queue, error) {
+	var queue models.Queue
+	result := r.DB.Where("is_default = ?", true).Limit(1).Find(&queue)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return &queue, nil
+}
+
+// UpdateFieldSchema replaces the custom field schema of a queue, a nil schema removes the custom fields
+func (r *QueueRepository) UpdateFieldSchema(id uint, schema *models.FieldSchema) error {
+	var value interface{}
+	if schema != nil {
+		value = *schema
+	}
+
+	result := r.DB.Model(&models.Queue{}).Where("id = ?", id).Update("field_schema", value)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return errors.New("queue not found")
+	}
+
+	return nil
+}
+
+// DeleteQueue deletes a queue and its memberships, queues with tickets can't be deleted
+func (r *QueueRepository) DeleteQueue(id uint) error {
+	return database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		// Trashed tickets still point to the queue until they are purged
+		var count int64
+		if err := tx.Unscoped().Model(&models.Ticket{}).Where("queue_id = ?", id).Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count > 0 {
+			return errors.New("cannot delete queue with existing tickets")
+		}
+
+		if err := tx.Where("queue_id = ?", id).Delete(&models.QueueMember{}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Delete(&models.Queue{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return errors.New("queue not found")
+		}
+
+		return nil
+	})
+}
+
+// AddMember gives a user access to a queue, adding an existing member does nothing
+func (r *QueueRepository) AddMember(queueID, userID uint) error {
+	member := models.QueueMember{QueueID: queueID, UserID: userID}
+	return r.DB.Omit("User").Clauses(clause.OnConflict{DoNothing: true}).Create(&member).Error
+}
+
+// RemoveMember removes the access of a user to a queue
+func (r *QueueRepository) RemoveMember(queueID, userID uint) error {
+	result := r.DB.Where("queue_id = ? AND user_id = ?", queueID, userID).Delete(&models.QueueMember{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return errors.New("user is not a member of the queue")
+	}
+
+	return nil
+}
+
+// GetMembers gets the members of a queue with their user
+func (r *QueueRepository) GetMembers(queueID uint) ([]models.QueueMember, error) {
+	var members []models.QueueMember
+	if err := r.DB.Preload("User").Where("queue_id = ?", queueID).Order("created_at ASC").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// GetMemberQueueIDs gets the IDs of the queues a user is a member of
+func (r *QueueRepository) GetMemberQueueIDs(userID uint) ([]uint, error) {
+	var queueIDs []uint
+	if err := r.DB.Model(&models.QueueMember{}).Where("user_id = ?", userID).Pluck("queue_id", &queueIDs).Error; err != nil {
+		return nil, err
+	}
+	return queueIDs, nil
+}
+
+// IsMember tells whether a user is a member of a queue
+func (r *QueueRepository) IsMember(queueID, userID uint) (bool, error) {
+	var count int64
+	err := r.DB.Model(&models.QueueMember{}).Where("queue_id = ? AND user_id = ?", queueID, userID).Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"hcall/api/database"
+	"hcall/api/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SessionRepository struct {
+	DB *gorm.DB
+}
+
+func NewSessionRepository() *SessionRepository {
+	return &SessionRepository{
+		DB: database.DB,
+	}
+}
+
+// CreateSession saves a new session
+func (r *SessionRepository) CreateSession(session *models.Session) error {
+	return r.DB.Create(session).Error
+}
+
+// FindByRefreshHash finds the session a refresh token belongs to, the tokens it replaced included
+func (r *SessionRepository) FindByRefreshHash(hash string) (*models.Session, error) {
+	var session models.Session
+	result := r.DB.Where("refresh_hash = ? OR previous_hash = ?", hash, hash).First(&session)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, errors.New("invalid refresh token")
+	}
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &session, nil
+}
+
+// RotateSession replaces the refresh token of a session and records its new access token, the
+// previous access token is revoked. Presenting a refresh token that was already replaced revokes
+// the whole session, as either the user or whoever stole the token is using an old copy.
+func (r *SessionRepository) RotateSession(id, hash, newHash, accessJTI string, accessExpiresAt time.Time) error {
+	reused := false
+	err := database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		var session models.Session
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&session).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("invalid refresh token")
+			}
+			return err
+		}
+
+		now := time.Now()
+		if session.RevokedAt != nil || !session.ExpiresAt.After(now) {
+			return errors.New("invalid refresh token")
+		}
+
+		if session.RefreshHash != hash {
+			if session.PreviousHash != hash {
+				return errors.New("invalid refresh token")
+			}
+			// The revocation is kept, the error is only returned once it is committed
+			reused = true
+			_, err := revokeSessions(tx, now, "id = ?", id)
+			return err
+		}
+
+		if err := revokeAccessToken(tx, &session, now); err != nil {
+			return err
+		}
+
+		return tx.Model(&session).Updates(map[string]interface{}{
+			"refresh_hash":      newHash,
+			"previous_hash":     hash,
+			"access_jti":        accessJTI,
+			"access_expires_at": accessExpiresAt,
+			"last_used_at":      now,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	if reused {
+		return errors.New("refresh token reused")
+	}
+	return nil
+}
+
+// revokeAccessToken adds the access token of a session to the revoked ones while it is still valid
+func revokeAccessToken(tx *gorm.DB, session *models.Session, now time.Time) error {
+	if session.AccessJTI == "" || !session.AccessExpiresAt.After(now) {
+		return nil
+	}
+
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RevokedToken{
+		JTI:       session.AccessJTI,
+		ExpiresAt: session.AccessExpiresAt,
+	}).Error
+}
+
+// revokeSessions revokes the active sessions matching the condition along with their access tokens,
+// returning how many were revoked
+func revokeSessions(tx *gorm.DB, now time.Time, query string, args ...interface{}) (int64, error) {
+	var sessions []models.Session
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where(query, args...).
+		Where("revoked_at IS NULL").
+		Find(&sessions).Error
+	if err != nil {
+		return 0, err
+	}
+
+	if len(sessions) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]string, len(sessions))
+	for i := range sessions {
+		ids[i] = sessions[i].ID
+		if err := revokeAccessToken(tx, &sessions[i], now); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Model(&models.Session{}).Where("id IN ?", ids).Update("revoked_at", now).Error; err != nil {
+		return 0, err
+	}
+	return int64(len(sessions)), nil
+}
+
+// RevokeSession revokes a session of a user
+func (r *SessionRepository) RevokeSession(id string, userID uint) error {
+	return database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		revoked, err := revokeSessions(tx, time.Now(), "id = ? AND user_id = ?", id, userID)
+		if err != nil {
+			return err
+		}
+
+		if revoked == 0 {
+			return errors.New("session not found")
+		}
+		return nil
+	})
+}
+
+// RevokeUserSessions revokes every session of a user, returning how many were active
+func (r *SessionRepository) RevokeUserSessions(userID uint) (int64, error) {
+	var revoked int64
+	err := database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		var err error
+		revoked, err = revokeSessions(tx, time.Now(), "user_id = ?", userID)
+		return err
+	})
+	return revoked, err
+}
+
+// IsTokenRevoked tells whether an access token was revoked
+func (r *SessionRepository) IsTokenRevoked(jti string) (bool, error) {
+	var count int64
+	if err := r.DB.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetActiveSessions lists the sessions of a user which weren't revoked nor expired, the latest used first
+func (r *SessionRepository) GetActiveSessions(userID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// PurgeExpired deletes the sessions that can't be refreshed anymore and the revoked tokens that
+// expired, returning how many of each were deleted
+func (r *SessionRepository) PurgeExpired(now time.Time) (int64, int64, error) {
+	// A revoked session is kept until its last access token expired, the revoked tokens cover it after that
+	sessions := r.DB.Where("expires_at < ? OR (revoked_at IS NOT NULL AND access_expires_at < ?)", now, now).
+		Delete(&models.Session{})
+	if sessions.Error != nil {
+		return 0, 0, sessions.Error
+	}
+
+	tokens := r.DB.Where("expires_at < ?", now).Delete(&models.RevokedToken{})
+	if tokens.Error != nil {
+		return sessions.RowsAffected, 0, tokens.Error
+	}
+
+	return sessions.RowsAffected, tokens.RowsAffected, nil
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"errors"
+
+	"hcall/api/database"
+	"hcall/api/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TagRepository struct {
+	DB *gorm.DB
+}
+
+func NewTagRepository() *TagRepository {
+	return &TagRepository{
+		DB: database.DB,
+	}
+}
+
+// GetOrCreateTags gets the tags with the given names, creating the missing ones
+func (r *TagRepository) GetOrCreateTags(names []string) ([]models.Tag, error) {
+	var tags []models.Tag
+	err := database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		missing := make([]models.Tag, len(names))
+		for i, name := range names {
+			missing[i] = models.Tag{Name: name}
+		}
+
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&missing).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("name IN ?", names).Find(&tags).Error
+	})
+	return tags, err
+}
+
+// GetTagByName gets a tag by its name
+func (r *TagRepository) GetTagByName(name string) (*models.Tag, error) {
+	var tag models.Tag
+	result := r.DB.Where("name = ?", name).First(&tag)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, errors.New("tag not found")
+	}
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &tag, nil
+}
+
+// AddTicketTags labels a ticket with the tags, the tags it already has are left as they are
+func (r *TagRepository) AddTicketTags(ticketID string, tags []models.Tag) error {
+	return database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		for _, tag := range tags {
+			if err := tx.Exec("INSERT INTO ticket_tags (ticket_id, tag_id) VALUES (?, ?) ON CONFLICT DO NOTHING", ticketID, tag.ID).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RemoveTicketTags removes the tags with the given names from a ticket
+func (r *TagRepository) RemoveTicketTags(ticketID string, names []string) error {
+	return r.DB.Exec("DELETE FROM ticket_tags WHERE ticket_id = ? AND tag_id IN (SELECT id FROM tags WHERE name IN ?)", ticketID, names).Error
+}
+
+// GetTagUsage gets every tag with the number of tickets labeled with it, most used first
+func (r *TagRepository) GetTagUsage() ([]models.TagUsage, error) {
+	var usage []models.TagUsage
+	err := r.DB.Table("tags").
+		Select("tags.id AS id, tags.name AS name, COUNT(tickets.id) AS tickets").
+		Joins("LEFT JOIN ticket_tags ON ticket_tags.tag_id = tags.id").
+		// Trashed tickets aren't counted
+		Joins("LEFT JOIN tickets ON tickets.id = ticket_tags.ticket_id AND tickets.deleted_at IS NULL").
+		Group("tags.id, tags.name").
+		Order("tickets DESC, tags.name ASC").
+		Scan(&usage).Error
+	if err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// RenameTag changes the name of a tag
+func (r *TagRepository) RenameTag(id uint, name string) error {
+	return r.DB.Model(&models.Tag{}).Where("id = ?", id).Update("name", name).Error
+}
+
+// MergeTags moves the tickets of the source tag to the target tag and deletes the source tag
+func (r *TagRepository) MergeTags(sourceID, targetID uint) error {
+	return database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		if err := tx.Exec(`INSERT INTO ticket_tags (ticket_id, tag_id)
+			SELECT ticket_id, ? FROM ticket_tags WHERE tag_id = ?
+			ON CONFLICT DO NOTHING`, targetID, sourceID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Exec("DELETE FROM ticket_tags WHERE tag_id = ?", sourceID).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&models.Tag{}, sourceID).Error
+	})
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"hcall/api/database"
+	"hcall/api/models"
+
+	"gorm.io/gorm"
+)
+
+// GetTicketsToArchive gets the oldest tickets with the status created before the given date, with
+// everything the archive keeps of them, leaving out the tickets of excludeIDs. At most limit tickets
+// are returned.
+func (r *TicketRepository) GetTicketsToArchive(status models.TicketStatus, before time.Time, limit int, excludeIDs []string) ([]models.Ticket, error) {
+	query := r.DB.Where("status = ? AND created_at < ?", status, before)
+	if len(excludeIDs) > 0 {
+		query = query.Where("id NOT IN ?", excludeIDs)
+	}
+
+	var tickets []models.Ticket
+	err := query.Preload("Assignee").Preload("Queue").Preload("Tags").
+		Preload("Images", func(db *gorm.DB) *gorm.DB {
+			return db.Order("uploaded_at ASC")
+		}).
+		Preload("History", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at ASC")
+		}).
+		Preload("Revisions", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at ASC, id ASC")
+		}).
+		Order("created_at ASC, id ASC").
+		Limit(limit).
+		Find(&tickets).Error
+	if err != nil {
+		return nil, err
+	}
+	return tickets, nil
+}
+
+// PurgeArchivedTickets permanently deletes tickets once they are written to an archive file.
+// It returns the blob store keys of their images so their content can be deleted too.
+func (r *TicketRepository) PurgeArchivedTickets(ids []string) ([]string, error) {
+	var storageKeys []string
+	err := database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		archived := tx.Unscoped().Model(&models.Ticket{}).Select("id").Where("id IN ?", ids)
+		if err := uncountTickets(tx, archived); err != nil {
+			return err
+		}
+
+		var err error
+		storageKeys, _, err = purgeTickets(tx, archived)
+		return err
+	})
+	return storageKeys, err
+}
+
+// ImportTicket creates a ticket brought back from the archive with its conversation, images,
+// revisions and status changes. The ticket keeps its ID, so it must not exist anymore.
+func (r *TicketRepository) ImportTicket(ticket *models.Ticket, changes []models.TicketStatusChange) error {
+	return database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Unscoped().Model(&models.Ticket{}).Where("id = ?", ticket.ID).Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count > 0 {
+			return errors.New("ticket already exists")
+		}
+
+		if err := tx.Create(ticket).Error; err != nil {
+			return err
+		}
+
+		deltas := counterDeltas{}
+		deltas.add(ticketCountOf(ticket), 1)
+		if err := deltas.apply(tx); err != nil {
+			return err
+		}
+
+		if len(changes) == 0 {
+			return nil
+		}
+
+		for i := range changes {
+			changes[i].TicketID = ticket.ID
+		}
+		return tx.Create(&changes).Error
+	})
+}
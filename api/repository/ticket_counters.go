@@ -0,0 +1,239 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+
+	"hcall/api/database"
+	"hcall/api/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// counterColumns maps each ticket status to its column in the counters table
+var counterColumns = map[models.TicketStatus]string{
+	models.PendingStatus:   "pending",
+	models.DoingStatus:     "doing",
+	models.OnHoldStatus:    "on_hold",
+	models.ConcluedStatus:  "conclued",
+	models.CancelledStatus: "cancelled",
+	models.ReopenedStatus:  "reopened",
+}
+
+// ticketCount is a number of tickets sharing the fields the counters are kept by
+type ticketCount struct {
+	Status     models.TicketStatus
+	AuthorID   uint
+	AssigneeID *uint
+	Count      int
+}
+
+// ticketCountOf is the count of a single ticket
+func ticketCountOf(ticket *models.Ticket) ticketCount {
+	return ticketCount{
+		Status:     ticket.Status,
+		AuthorID:   ticket.AuthorID,
+		AssigneeID: ticket.AssigneeID,
+		Count:      1,
+	}
+}
+
+// ticketCounts counts the live tickets selected by the ids subquery
+func ticketCounts(tx *gorm.DB, ids *gorm.DB) ([]ticketCount, error) {
+	var counts []ticketCount
+	err := tx.Model(&models.Ticket{}).
+		Select("status, author_id, assignee_id, COUNT(*) AS count").
+		Where("id IN (?)", ids).
+		Group("status, author_id, assignee_id").
+		Scan(&counts).Error
+	return counts, err
+}
+
+// counterOwner identifies a row of the counters table
+type counterOwner struct {
+	scope   models.CounterScope
+	ownerID uint
+}
+
+// counterDeltas accumulates the changes of the counters rows, by row and column, so a ticket change
+// updates the counters in the same transaction and each row only once
+type counterDeltas map[counterOwner]map[string]int
+
+// add counts the tickets in the global, author and assignee rows, a negative sign uncounts them
+func (d counterDeltas) add(count ticketCount, sign int) {
+	owners := []counterOwner{
+		{models.GlobalCounters, 0},
+		{models.AuthorCounters, count.AuthorID},
+	}
+	if count.AssigneeID != nil {
+		owners = append(owners, counterOwner{models.AssigneeCounters, *count.AssigneeID})
+	}
+
+	n := sign * count.Count
+	column, known := counterColumns[count.Status]
+	for _, owner := range owners {
+		columns, ok := d[owner]
+		if !ok {
+			columns = map[string]int{}
+			d[owner] = columns
+		}
+
+		columns["total"] += n
+		if known {
+			columns[column] += n
+		}
+	}
+}
+
+// apply writes the changes to the counters table, creating the missing rows
+func (d counterDeltas) apply(tx *gorm.DB) error {
+	// Rows are always locked in the same order so concurrent changes can't deadlock
+	owners := make([]counterOwner, 0, len(d))
+	for owner := range d {
+		owners = append(owners, owner)
+	}
+	sort.Slice(owners, func(i, j int) bool {
+		if owners[i].scope != owners[j].scope {
+			return owners[i].scope < owners[j].scope
+		}
+		return owners[i].ownerID < owners[j].ownerID
+	})
+
+	for _, owner := range owners {
+		values := map[string]interface{}{
+			"scope":    owner.scope,
+			"owner_id": owner.ownerID,
+		}
+		assignments := map[string]interface{}{}
+		for column, n := range d[owner] {
+			if n == 0 {
+				continue
+			}
+			values[column] = n
+			assignments[column] = gorm.Expr("counters."+column+" + ?", n)
+		}
+
+		if len(assignments) == 0 {
+			continue
+		}
+
+		err := tx.Model(&models.Counters{}).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "scope"}, {Name: "owner_id"}},
+			DoUpdates: clause.Assignments(assignments),
+		}).Create(values).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uncountTickets removes the live tickets selected by the ids subquery from the counters,
+// before they are trashed or deleted
+func uncountTickets(tx *gorm.DB, ids *gorm.DB) error {
+	counts, err := ticketCounts(tx, ids)
+	if err != nil {
+		return err
+	}
+
+	deltas := counterDeltas{}
+	for _, count := range counts {
+		deltas.add(count, -1)
+	}
+	return deltas.apply(tx)
+}
+
+// userCountersColumns are the columns of the user counters, read from a counters table joined to the users
+const userCountersColumns = `counters.owner_id AS user_id, users.username AS user_name, users.email AS user_email,
+	counters.total, counters.pending, counters.doing, counters.on_hold,
+	counters.conclued, counters.cancelled, counters.reopened`
+
+// GetUserCounters gets the counters of the tickets created by, or assigned to, each user, the
+// busiest users first
+func (r *TicketRepository) GetUserCounters(scope models.CounterScope) ([]models.UserCounters, error) {
+	var counters []models.UserCounters
+	err := r.DB.Table("counters").
+		Select(userCountersColumns).
+		Joins("JOIN users ON users.id = counters.owner_id").
+		Where("counters.scope = ? AND counters.total > 0", scope).
+		Order("counters.total DESC, users.email ASC").
+		Scan(&counters).Error
+	if err != nil {
+		return nil, err
+	}
+	return counters, nil
+}
+
+// CountUserTickets counts the tickets matching the filter created by, or assigned to, each user, the
+// busiest users first. Unlike GetUserCounters it counts the tickets themselves, so the filter applies.
+func (r *TicketRepository) CountUserTickets(filter TicketFilter, scope models.CounterScope) ([]models.UserCounters, error) {
+	owner := "tickets.author_id"
+	if scope == models.AssigneeCounters {
+		owner = "tickets.assignee_id"
+	}
+
+	counts := filter.apply(r.DB.Model(&models.Ticket{})).
+		Select(owner + ` AS owner_id, COUNT(*) AS total,
+			COUNT(*) FILTER (WHERE tickets.status = 'pending') AS pending,
+			COUNT(*) FILTER (WHERE tickets.status = 'doing') AS doing,
+			COUNT(*) FILTER (WHERE tickets.status = 'on_hold') AS on_hold,
+			COUNT(*) FILTER (WHERE tickets.status = 'conclued') AS conclued,
+			COUNT(*) FILTER (WHERE tickets.status = 'cancelled') AS cancelled,
+			COUNT(*) FILTER (WHERE tickets.status = 'reopened') AS reopened`).
+		Where(owner + " IS NOT NULL").
+		Group(owner)
+
+	var counters []models.UserCounters
+	err := r.DB.Table("(?) AS counters", counts).
+		Select(userCountersColumns).
+		Joins("JOIN users ON users.id = counters.owner_id").
+		Order("counters.total DESC, users.email ASC").
+		Scan(&counters).Error
+	if err != nil {
+		return nil, err
+	}
+	return counters, nil
+}
+
+// recomputeCounters is the query rebuilding the counters of a scope from the live tickets
+const recomputeCounters = `INSERT INTO counters (scope, owner_id, total, pending, doing, on_hold, conclued, cancelled, reopened)
+SELECT ?, %s, COUNT(*),
+	COUNT(*) FILTER (WHERE status = 'pending'),
+	COUNT(*) FILTER (WHERE status = 'doing'),
+	COUNT(*) FILTER (WHERE status = 'on_hold'),
+	COUNT(*) FILTER (WHERE status = 'conclued'),
+	COUNT(*) FILTER (WHERE status = 'cancelled'),
+	COUNT(*) FILTER (WHERE status = 'reopened')
+FROM tickets
+WHERE deleted_at IS NULL %s`
+
+// RecomputeCounters rebuilds every counter from the tickets, fixing any drift
+func (r *TicketRepository) RecomputeCounters() error {
+	return database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		// Ticket changes wait for the rebuild, the ones already done are part of it
+		if err := tx.Exec("LOCK TABLE counters IN SHARE ROW EXCLUSIVE MODE").Error; err != nil {
+			return err
+		}
+
+		if err := tx.Exec("DELETE FROM counters").Error; err != nil {
+			return err
+		}
+
+		queries := []struct {
+			scope models.CounterScope
+			owner string
+			group string
+		}{
+			{models.GlobalCounters, "0", ""},
+			{models.AuthorCounters, "author_id", "GROUP BY author_id"},
+			{models.AssigneeCounters, "assignee_id", "AND assignee_id IS NOT NULL GROUP BY assignee_id"},
+		}
+		for _, query := range queries {
+			if err := tx.Exec(fmt.Sprintf(recomputeCounters, query.owner, query.group), query.scope).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
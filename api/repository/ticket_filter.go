@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"encoding/json"
+	"time"
+
+	"hcall/api/models"
+
+	"gorm.io/gorm"
+)
+
+// TicketFilter describes a ticket listing. Every field left empty matches all tickets, the
+// fields that are set are combined with AND. The Exclude fields remove the matching tickets.
+type TicketFilter struct {
+	AuthorID      *uint
+	AuthorEmails  []string
+	ExcludeAuthor []string
+
+	Statuses        []models.TicketStatus
+	ExcludeStatuses []models.TicketStatus
+
+	Priorities        []models.TicketPriority
+	ExcludePriorities []models.TicketPriority
+
+	SLAStates []models.SLAState
+
+	AssigneeID *uint
+	Unassigned bool
+
+	QueueIDs        []uint
+	ExcludeQueueIDs []uint
+
+	// RestrictQueues limits the tickets to the VisibleQueueIDs and to the tickets without a queue
+	RestrictQueues  bool
+	VisibleQueueIDs []uint
+
+	// TagsAny matches the tickets with at least one of the tags, TagsAll the tickets with all of them
+	TagsAny []string
+	TagsAll []string
+
+	// CustomFields matches the tickets whose custom fields hold all the given values
+	CustomFields map[string]interface{}
+
+	// Name matches part of the ticket name, case insensitive
+	Name string
+
+	// Creation date range, CreatedFrom is inclusive and CreatedTo exclusive
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+
+	// DueBefore matches the tickets that must be resolved before the given time
+	DueBefore *time.Time
+}
+
+// apply compiles the filter into the conditions of a ticket query
+func (f TicketFilter) apply(db *gorm.DB) *gorm.DB {
+	if f.AuthorID != nil {
+		db = db.Where("tickets.author_id = ?", *f.AuthorID)
+	}
+	if len(f.AuthorEmails) > 0 {
+		db = db.Where("tickets.author_email IN ?", f.AuthorEmails)
+	}
+	if len(f.ExcludeAuthor) > 0 {
+		db = db.Where("tickets.author_email NOT IN ?", f.ExcludeAuthor)
+	}
+
+	if len(f.Statuses) > 0 {
+		db = db.Where("tickets.status IN ?", f.Statuses)
+	}
+	if len(f.ExcludeStatuses) > 0 {
+		db = db.Where("tickets.status NOT IN ?", f.ExcludeStatuses)
+	}
+
+	if len(f.Priorities) > 0 {
+		db = db.Where("tickets.priority IN ?", f.Priorities)
+	}
+	if len(f.ExcludePriorities) > 0 {
+		db = db.Where("tickets.priority NOT IN ?", f.ExcludePriorities)
+	}
+
+	if len(f.SLAStates) > 0 {
+		db = db.Where("tickets.sla_state IN ?", f.SLAStates)
+	}
+
+	if f.AssigneeID != nil {
+		db = db.Where("tickets.assignee_id = ?", *f.AssigneeID)
+	}
+	if f.Unassigned {
+		db = db.Where("tickets.assignee_id IS NULL")
+	}
+
+	if len(f.QueueIDs) > 0 {
+		db = db.Where("tickets.queue_id IN ?", f.QueueIDs)
+	}
+	if len(f.ExcludeQueueIDs) > 0 {
+		db = db.Where("(tickets.queue_id IS NULL OR tickets.queue_id NOT IN ?)", f.ExcludeQueueIDs)
+	}
+	if f.RestrictQueues {
+		if len(f.VisibleQueueIDs) > 0 {
+			db = db.Where("(tickets.queue_id IS NULL OR tickets.queue_id IN ?)", f.VisibleQueueIDs)
+		} else {
+			db = db.Where("tickets.queue_id IS NULL")
+		}
+	}
+
+	if len(f.TagsAny) > 0 {
+		db = db.Where("tickets.id IN (?)", taggedTickets(db, f.TagsAny))
+	}
+	if len(f.TagsAll) > 0 {
+		db = db.Where("tickets.id IN (?)", taggedTickets(db, f.TagsAll).
+			Group("ticket_tags.ticket_id").
+			Having("COUNT(DISTINCT ticket_tags.tag_id) = ?", len(f.TagsAll)))
+	}
+
+	if len(f.CustomFields) > 0 {
+		if fields, err := json.Marshal(f.CustomFields); err == nil {
+			db = db.Where("tickets.custom_fields @> ?::jsonb", string(fields))
+		}
+	}
+
+	if f.Name != "" {
+		db = db.Where("tickets.name ILIKE ?", "%"+f.Name+"%")
+	}
+
+	if f.CreatedFrom != nil {
+		db = db.Where("tickets.created_at >= ?", *f.CreatedFrom)
+	}
+	if f.CreatedTo != nil {
+		db = db.Where("tickets.created_at < ?", *f.CreatedTo)
+	}
+
+	if f.DueBefore != nil {
+		db = db.Where("tickets.resolution_due_at < ?", *f.DueBefore)
+	}
+
+	return db
+}
+
+// taggedTickets selects the IDs of the tickets labeled with any of the tags
+func taggedTickets(db *gorm.DB, tags []string) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true}).Table("ticket_tags").
+		Select("ticket_tags.ticket_id").
+		Joins("JOIN tags ON tags.id = ticket_tags.tag_id").
+		Where("tags.name IN ?", tags)
+}
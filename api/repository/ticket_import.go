@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"hcall/api/database"
+	"hcall/api/models"
+
+	"gorm.io/gorm"
+)
+
+// importSavepoint is the savepoint each imported ticket is created under
+const importSavepoint = "import_ticket"
+
+// ImportedTicket is a ticket brought from another helpdesk, with the status change that closed it
+type ImportedTicket struct {
+	Ticket  *models.Ticket
+	Closing *models.TicketStatusChange // nil for an open ticket
+}
+
+// ImportTickets creates tickets brought from another helpdesk, with their conversation and the
+// placeholder users of their unregistered authors, in one transaction. Each ticket is created under
+// a savepoint so a failing one doesn't undo the others, the error of each ticket is returned at its
+// index. The tickets and closings of a placeholder refer to it by email, they get its ID once it is
+// created; its messages point to its ID.
+func (r *TicketRepository) ImportTickets(placeholders []*models.User, tickets []ImportedTicket) ([]error, error) {
+	errs := make([]error, len(tickets))
+	err := database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		created := make(map[string]uint, len(placeholders))
+		for _, user := range placeholders {
+			if err := tx.Create(user).Error; err != nil {
+				return err
+			}
+			created[user.Email] = user.ID
+		}
+
+		deltas := counterDeltas{}
+		for i, imported := range tickets {
+			ticket, closing := imported.Ticket, imported.Closing
+			if ticket.AuthorID == 0 {
+				ticket.AuthorID = created[ticket.AuthorEmail]
+			}
+
+			if err := tx.SavePoint(importSavepoint).Error; err != nil {
+				return err
+			}
+
+			err := tx.Create(ticket).Error
+			if err == nil && closing != nil {
+				closing.TicketID = ticket.ID
+				if closing.ActorID == 0 {
+					closing.ActorID = created[closing.ActorEmail]
+				}
+				err = tx.Create(closing).Error
+			}
+
+			if err != nil {
+				if err := tx.RollbackTo(importSavepoint).Error; err != nil {
+					return err
+				}
+				errs[i] = err
+				continue
+			}
+
+			deltas.add(ticketCountOf(ticket), 1)
+		}
+
+		return deltas.apply(tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return errs, nil
+}
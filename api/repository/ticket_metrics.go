@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"hcall/api/models"
+)
+
+// metricsGroupKeys maps each metrics grouping to the SQL expression of the group of a ticket.
+// Tickets without an agent or a queue fall in the empty group.
+var metricsGroupKeys = map[models.MetricsGroupBy]string{
+	"":                       "''",
+	models.MetricsByStatus:   "tickets.status",
+	models.MetricsByAgent:    "COALESCE(tickets.assignee_id::text, '')",
+	models.MetricsByCategory: "COALESCE(tickets.queue_id::text, '')",
+}
+
+// BacklogBuckets are the age ranges of the open tickets, each one holds the tickets younger than
+// its limit and not in the previous one. The last one has no limit.
+var BacklogBuckets = []struct {
+	Name  string
+	Limit time.Duration
+}{
+	{"under_1d", 24 * time.Hour},
+	{"1d_to_3d", 3 * 24 * time.Hour},
+	{"3d_to_7d", 7 * 24 * time.Hour},
+	{"7d_to_30d", 30 * 24 * time.Hour},
+	{"over_30d", 0},
+}
+
+// durationStats is the query summarizing the seconds between the creation of the tickets and a column
+const durationStats = `%s AS group_key, COUNT(*) AS count,
+	AVG(%[2]s)::float8 AS average,
+	percentile_cont(0.5) WITHIN GROUP (ORDER BY %[2]s) AS median,
+	percentile_cont(0.9) WITHIN GROUP (ORDER BY %[2]s) AS p90`
+
+// getDurationStats summarizes, per group, the time the tickets took to reach the time column, for
+// the tickets which reached it during the range
+func (r *TicketRepository) getDurationStats(filter TicketFilter, groupBy models.MetricsGroupBy, column string, from, to time.Time) (map[string]models.TimeStats, error) {
+	var rows []struct {
+		GroupKey string
+		models.TimeStats
+	}
+
+	seconds := fmt.Sprintf("EXTRACT(EPOCH FROM tickets.%s - tickets.created_at)::float8", column)
+	err := filter.apply(r.DB.Model(&models.Ticket{})).
+		Select(fmt.Sprintf(durationStats, metricsGroupKeys[groupBy], seconds)).
+		Where(fmt.Sprintf("tickets.%[1]s >= ? AND tickets.%[1]s < ?", column), from, to).
+		Group("group_key").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[string]models.TimeStats, len(rows))
+	for _, row := range rows {
+		stats[row.GroupKey] = row.TimeStats
+	}
+	return stats, nil
+}
+
+// GetFirstResponseStats summarizes the time to the first agent answer of the tickets answered during the range
+func (r *TicketRepository) GetFirstResponseStats(filter TicketFilter, groupBy models.MetricsGroupBy, from, to time.Time) (map[string]models.TimeStats, error) {
+	return r.getDurationStats(filter, groupBy, "first_response_at", from, to)
+}
+
+// GetResolutionStats summarizes the time to the resolution of the tickets resolved during the range
+func (r *TicketRepository) GetResolutionStats(filter TicketFilter, groupBy models.MetricsGroupBy, from, to time.Time) (map[string]models.TimeStats, error) {
+	return r.getDurationStats(filter, groupBy, "resolved_at", from, to)
+}
+
+// GetBacklogAges counts the open tickets of each group in every BacklogBuckets range, by their age at the given time
+func (r *TicketRepository) GetBacklogAges(filter TicketFilter, groupBy models.MetricsGroupBy, now time.Time) (map[string][]int64, error) {
+	var bucket strings.Builder
+	var args []interface{}
+	bucket.WriteString("CASE")
+	for i, b := range BacklogBuckets {
+		if b.Limit == 0 {
+			fmt.Fprintf(&bucket, " ELSE %d", i)
+			continue
+		}
+		fmt.Fprintf(&bucket, " WHEN tickets.created_at > ? THEN %d", i)
+		args = append(args, now.Add(-b.Limit))
+	}
+	bucket.WriteString(" END")
+
+	var rows []struct {
+		GroupKey string
+		Bucket   int
+		Count    int64
+	}
+	err := filter.apply(r.DB.Model(&models.Ticket{})).
+		Select(fmt.Sprintf("%s AS group_key, %s AS bucket, COUNT(*) AS count", metricsGroupKeys[groupBy], bucket.String()), args...).
+		Where("tickets.status NOT IN ?", models.ClosedStatuses).
+		Group("group_key, bucket").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	ages := map[string][]int64{}
+	for _, row := range rows {
+		counts, ok := ages[row.GroupKey]
+		if !ok {
+			counts = make([]int64, len(BacklogBuckets))
+			ages[row.GroupKey] = counts
+		}
+		counts[row.Bucket] = row.Count
+	}
+	return ages, nil
+}
+
+// dayCount is a number of tickets of a group during a UTC day
+type dayCount struct {
+	GroupKey string
+	Day      string
+	Count    int64
+}
+
+// utcDay is the SQL expression of the UTC day of a timestamp column, as YYYY-MM-DD
+func utcDay(column string) string {
+	return fmt.Sprintf("to_char(%s AT TIME ZONE 'UTC', 'YYYY-MM-DD')", column)
+}
+
+// GetDailyVolumes counts, per group and day, the tickets created and the tickets closed during the range.
+// A ticket closed again after being reopened counts every time.
+func (r *TicketRepository) GetDailyVolumes(filter TicketFilter, groupBy models.MetricsGroupBy, from, to time.Time) (created, closed map[string]map[string]int64, err error) {
+	var createdRows []dayCount
+	err = filter.apply(r.DB.Model(&models.Ticket{})).
+		Select(fmt.Sprintf("%s AS group_key, %s AS day, COUNT(*) AS count", metricsGroupKeys[groupBy], utcDay("tickets.created_at"))).
+		Where("tickets.created_at >= ? AND tickets.created_at < ?", from, to).
+		Group("group_key, day").
+		Scan(&createdRows).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var closedRows []dayCount
+	err = filter.apply(r.DB.Table("ticket_status_changes")).
+		Select(fmt.Sprintf("%s AS group_key, %s AS day, COUNT(*) AS count", metricsGroupKeys[groupBy], utcDay("ticket_status_changes.created_at"))).
+		Joins("JOIN tickets ON tickets.id = ticket_status_changes.ticket_id AND tickets.deleted_at IS NULL").
+		Where("ticket_status_changes.to_status IN ? AND ticket_status_changes.from_status NOT IN ?", models.ClosedStatuses, models.ClosedStatuses).
+		Where("ticket_status_changes.created_at >= ? AND ticket_status_changes.created_at < ?", from, to).
+		Group("group_key, day").
+		Scan(&closedRows).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return groupDays(createdRows), groupDays(closedRows), nil
+}
+
+// groupDays indexes the day counts by group and day
+func groupDays(rows []dayCount) map[string]map[string]int64 {
+	days := map[string]map[string]int64{}
+	for _, row := range rows {
+		if days[row.GroupKey] == nil {
+			days[row.GroupKey] = map[string]int64{}
+		}
+		days[row.GroupKey][row.Day] = row.Count
+	}
+	return days
+}
@@ -2,17 +2,18 @@ package repository
 
 import (
 	"errors"
-	"log"
 	"time"
 
 	"hcall/api/database"
 	"hcall/api/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TicketRepository struct {
-	DB *gorm.DB
+	DB   *gorm.DB
+	page *models.Page
 }
 
 func NewTicketRepository() *TicketRepository {
@@ -21,17 +22,48 @@ func NewTicketRepository() *TicketRepository {
 	}
 }
 
-// create a function that remove tickets with specified status
-func (r *TicketRepository) RemoveTicketsWithStatus(status models.TicketStatus, remove_after int) error {
-	// Get atual date and refator now to YYYY/MM/DD format
+// Paginate returns a copy of the repository whose ticket listings only return the given page.
+// The page total and next cursor are filled by the listing.
+func (r *TicketRepository) Paginate(page *models.Page) *TicketRepository {
+	return &TicketRepository{
+		DB:   r.DB,
+		page: page,
+	}
+}
 
-	// subtract remove_after (days) from now
-	now := time.Now().AddDate(0, 0, -remove_after).Format("2006/01/02")
-	log.Println(now) // debugg
+// ticketSortColumns are the fields ticket listings can be sorted by
+var ticketSortColumns = map[string]sortColumn[models.Ticket]{
+	"created": timeColumn("tickets.created_at", func(t *models.Ticket) time.Time { return t.CreatedAt }),
+	"updated": timeColumn("tickets.updated_at", func(t *models.Ticket) time.Time { return t.UpdatedAt }),
+	"status": rankColumn("tickets.status", []string{
+		string(models.PendingStatus), string(models.DoingStatus), string(models.OnHoldStatus),
+		string(models.ReopenedStatus), string(models.ConcluedStatus), string(models.CancelledStatus),
+	}, func(t *models.Ticket) string { return string(t.Status) }),
+	"priority": rankColumn("tickets.priority", []string{
+		string(models.LowPriority), string(models.NormalPriority), string(models.HighPriority), string(models.UrgentPriority),
+	}, func(t *models.Ticket) string { return string(t.Priority) }),
+	// Tickets without a deadline come last
+	"due_date": timeColumn("COALESCE(tickets.resolution_due_at, '9999-12-31')", func(t *models.Ticket) time.Time {
+		if t.ResolutionDueAt == nil {
+			return noDueDate
+		}
+		return *t.ResolutionDueAt
+	}),
+}
+
+var noDueDate = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
 
-	// remove tickets with specified status and created_at before now
-	result := r.DB.Where("status =? AND created_at <?", status, now).Delete(&models.Ticket{})
-	return result.Error
+// find runs a ticket listing query, only returning the repository page when there is one
+func (r *TicketRepository) find(query *gorm.DB) ([]models.Ticket, error) {
+	if r.page != nil {
+		return findPage(query, r.page, ticketSortColumns, "tickets.id", func(t *models.Ticket) string { return t.ID }, "Assignee", "Queue", "Tags")
+	}
+
+	var tickets []models.Ticket
+	if err := query.Preload("Assignee").Preload("Queue").Preload("Tags").Find(&tickets).Error; err != nil {
+		return nil, err
+	}
+	return tickets, nil
 }
 
 // CreateTicket creates a new ticket
@@ -47,63 +79,20 @@ func (r *TicketRepository) CreateTicket(ticket *models.Ticket) error {
 		ticket.AuthorID = user.ID
 
 		// Now create the ticket
-		return tx.Create(ticket).Error
-	})
-}
-
-func (r *TicketRepository) CountTicket(status string) error {
-	return database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
-		var counters models.Counters
-		counters.ID = 1
-
-		// Get the current counter or create if not exists - within transaction
-		if err := tx.First(&counters).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				counters = models.Counters{Pending: 0, Doing: 0, Conclued: 0}
-				if err := tx.Create(&counters).Error; err != nil {
-					return err
-				}
-			} else {
-				return err
-			}
-		}
-
-		if status == "pending" {
-			log.Println("pending")
-			if err := tx.Model(&models.Counters{}).Where("id =?", counters.ID).
-				Update("pending", gorm.Expr("pending +?", 1)).Error; err != nil {
-				return err
-			}
-		}
-
-		if status == "doing" {
-			log.Println("doing")
-			if err := tx.Model(&models.Counters{}).Where("id =?", counters.ID).
-				Update("doing", gorm.Expr("doing +?", 1)).Error; err != nil {
-				return err
-			}
-		}
-
-		if status == "conclued" {
-			log.Println("conclued")
-			if err := tx.Model(&models.Counters{}).Where("id =?", counters.ID).
-				Update("conclued", gorm.Expr("conclued +?", 1)).Error; err != nil {
-				return err
-			}
-		}
-
-		if err := tx.Model(&models.Counters{}).Where("id =?", counters.ID).
-			Update("total", gorm.Expr("total +?", 1)).Error; err != nil {
+		if err := tx.Create(ticket).Error; err != nil {
 			return err
 		}
 
-		return nil
+		deltas := counterDeltas{}
+		deltas.add(ticketCountOf(ticket), 1)
+		return deltas.apply(tx)
 	})
 }
 
+// GetCounters gets the counters of every ticket
 func (r *TicketRepository) GetCounters() (*models.Counters, error) {
 	var counters models.Counters
-	result := r.DB.First(&counters)
+	result := r.DB.Where("scope = ?", models.GlobalCounters).First(&counters)
 
 	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 		return nil, errors.New("counters not found")
@@ -116,10 +105,46 @@ func (r *TicketRepository) GetCounters() (*models.Counters, error) {
 	return &counters, nil
 }
 
+// CountTicketsByStatus counts the tickets matching the filter per status, the counters are computed live
+func (r *TicketRepository) CountTicketsByStatus(filter TicketFilter) (*models.Counters, error) {
+	var rows []struct {
+		Status models.TicketStatus
+		Count  int
+	}
+	err := filter.apply(r.DB.Model(&models.Ticket{})).
+		Select("tickets.status AS status, COUNT(*) AS count").
+		Group("tickets.status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counters := &models.Counters{}
+	for _, row := range rows {
+		counters.Total += row.Count
+		switch row.Status {
+		case models.PendingStatus:
+			counters.Pending = row.Count
+		case models.DoingStatus:
+			counters.Doing = row.Count
+		case models.OnHoldStatus:
+			counters.OnHold = row.Count
+		case models.ConcluedStatus:
+			counters.Conclued = row.Count
+		case models.CancelledStatus:
+			counters.Cancelled = row.Count
+		case models.ReopenedStatus:
+			counters.Reopened = row.Count
+		}
+	}
+
+	return counters, nil
+}
+
 // GetTicket gets a ticket by ID
 func (r *TicketRepository) GetTicket(id string) (*models.Ticket, error) {
 	var ticket models.Ticket
-	result := r.DB.Where("id = ?", id).First(&ticket)
+	result := r.DB.Preload("Assignee").Preload("Queue").Where("id = ?", id).First(&ticket)
 
 	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 		return nil, errors.New("ticket not found")
@@ -151,7 +176,17 @@ func (r *TicketRepository) FindByID(id string) (*models.Ticket, error) {
 // GetTicketWithDetails gets a ticket with all its details (images and history)
 func (r *TicketRepository) GetTicketWithDetails(id string) (*models.Ticket, error) {
 	var ticket models.Ticket
-	result := r.DB.Preload("Images").Preload("History").Where("id = ?", id).First(&ticket)
+	result := r.DB.Preload("Assignee").Preload("Queue").Preload("Tags").
+		Preload("Images", func(db *gorm.DB) *gorm.DB {
+			return db.Omit("base64").Order("uploaded_at ASC")
+		}).
+		Preload("History", func(db *gorm.DB) *gorm.DB {
+			return db.Where("deleted_at IS NULL").Order("created_at ASC")
+		}).
+		Preload("Revisions", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at ASC, id ASC")
+		}).
+		Where("id = ?", id).First(&ticket)
 
 	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 		return nil, errors.New("ticket not found")
@@ -164,143 +199,341 @@ func (r *TicketRepository) GetTicketWithDetails(id string) (*models.Ticket, erro
 	return &ticket, nil
 }
 
-// GetTickets gets all tickets
-func (r *TicketRepository) GetTickets() ([]models.Ticket, error) {
-	var tickets []models.Ticket
-	if err := r.DB.Find(&tickets).Error; err != nil {
-		return nil, err
+// FindTickets gets the tickets matching the filter, no matching ticket is not an error
+func (r *TicketRepository) FindTickets(filter TicketFilter) ([]models.Ticket, error) {
+	query := filter.apply(r.DB.Model(&models.Ticket{}))
+	if r.page == nil {
+		query = query.Order("tickets.created_at DESC")
 	}
-	return tickets, nil
-}
 
-// GetTicketsByAuthor gets tickets by author
-func (r *TicketRepository) GetTicketsByAuthor(authorEmail string) ([]models.Ticket, error) {
-	var tickets []models.Ticket
-	if err := r.DB.Where("author_email = ?", authorEmail).Find(&tickets).Error; err != nil {
+	tickets, err := r.find(query)
+	if err != nil {
 		return nil, err
 	}
 
-	if len(tickets) == 0 {
-		return nil, errors.New("author doesn't have tickets")
+	if tickets == nil {
+		tickets = []models.Ticket{}
 	}
-
 	return tickets, nil
 }
 
-// GetTicketsByStatus gets tickets by status
-func (r *TicketRepository) GetTicketsByStatus(status models.TicketStatus) ([]models.Ticket, error) {
-	var tickets []models.Ticket
-	if err := r.DB.Where("status = ?", status).Find(&tickets).Error; err != nil {
+// EachTicket walks the tickets matching the filter by batches, oldest first, so the caller never
+// holds all of them at once. The conversation is only loaded when asked for.
+func (r *TicketRepository) EachTicket(filter TicketFilter, withHistory bool, batchSize int, fn func(tickets []models.Ticket) error) error {
+	var last *models.Ticket
+	for {
+		query := filter.apply(r.DB.Model(&models.Ticket{})).Preload("Assignee").Preload("Queue").Preload("Tags")
+		if withHistory {
+			query = query.Preload("History", func(db *gorm.DB) *gorm.DB {
+				return db.Order("created_at ASC, id ASC")
+			})
+		}
+		if last != nil {
+			query = query.Where("(tickets.created_at, tickets.id) > (?, ?)", last.CreatedAt, last.ID)
+		}
+
+		var tickets []models.Ticket
+		if err := query.Order("tickets.created_at ASC, tickets.id ASC").Limit(batchSize).Find(&tickets).Error; err != nil {
+			return err
+		}
+
+		if len(tickets) == 0 {
+			return nil
+		}
+
+		if err := fn(tickets); err != nil {
+			return err
+		}
+
+		if len(tickets) < batchSize {
+			return nil
+		}
+		last = &tickets[len(tickets)-1]
+	}
+}
+
+// GetTicketImages gets the images attached to a ticket
+func (r *TicketRepository) GetTicketImages(ticketID string) ([]models.Image, error) {
+	var images []models.Image
+	if err := r.DB.Omit("base64").Where("ticket_id = ?", ticketID).Order("uploaded_at ASC").Find(&images).Error; err != nil {
 		return nil, err
 	}
+	return images, nil
+}
 
-	if len(tickets) == 0 {
-		return nil, errors.New("no tickets found with specified status")
+// GetTicketHistory gets the conversation of a ticket in chronological order
+func (r *TicketRepository) GetTicketHistory(ticketID string, includeInternal bool) ([]models.TicketHistory, error) {
+	var history []models.TicketHistory
+	query := r.DB.Where("ticket_id = ? AND deleted_at IS NULL", ticketID)
+	if !includeInternal {
+		query = query.Where("visibility = ?", models.PublicMessage)
 	}
 
-	return tickets, nil
+	if err := query.Order("created_at ASC").Find(&history).Error; err != nil {
+		return nil, err
+	}
+	return history, nil
 }
 
-func (r *TicketRepository) GetTicketsByDate(date string) ([]models.Ticket, error) {
-	var tickets []models.Ticket
+// GetTicketMessage gets a message of a ticket conversation by ID
+func (r *TicketRepository) GetTicketMessage(id uint) (*models.TicketHistory, error) {
+	var message models.TicketHistory
+	result := r.DB.Where("id = ? AND deleted_at IS NULL", id).First(&message)
 
-	// Query tickets created on or after the specified date
-	if err := r.DB.Where("DATE(created_at) >= ?", date).Find(&tickets).Error; err != nil {
-		return nil, err
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, errors.New("message not found")
 	}
 
-	if len(tickets) == 0 {
-		return nil, errors.New("no tickets found after the specified date")
+	if result.Error != nil {
+		return nil, result.Error
 	}
 
-	return tickets, nil
+	return &message, nil
 }
 
-// GetTicketsByAuthorAndStatus gets tickets by author and status
-func (r *TicketRepository) GetTicketsByAuthorAndStatus(authorEmail string, status models.TicketStatus) ([]models.Ticket, error) {
-	var tickets []models.Ticket
-	if err := r.DB.Where("author_email = ? AND status = ?", authorEmail, status).Find(&tickets).Error; err != nil {
-		return nil, err
-	}
+// EditTicketMessage replaces the content of a message and keeps the previous one in its revisions
+func (r *TicketRepository) EditTicketMessage(id uint, message string, revision *models.TicketHistoryRevision) error {
+	return database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		result := tx.Model(&models.TicketHistory{}).Where("id = ? AND deleted_at IS NULL", id).Updates(map[string]interface{}{
+			"message":   message,
+			"edited_at": revision.CreatedAt,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
 
-	if len(tickets) == 0 {
-		return nil, errors.New("no tickets found with specified author and status")
-	}
+		if result.RowsAffected == 0 {
+			return errors.New("message not found")
+		}
 
-	return tickets, nil
+		return tx.Create(revision).Error
+	})
 }
 
-func (r *TicketRepository) GetTicketsByAuthorAndDate(author string, date string) ([]models.Ticket, error) {
-	var tickets []models.Ticket
+// DeleteTicketMessage hides a message from the conversation and keeps its content in its revisions
+func (r *TicketRepository) DeleteTicketMessage(id uint, revision *models.TicketHistoryRevision) error {
+	return database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		result := tx.Model(&models.TicketHistory{}).Where("id = ? AND deleted_at IS NULL", id).
+			Update("deleted_at", revision.CreatedAt)
+		if result.Error != nil {
+			return result.Error
+		}
 
-	// Query tickets created on or after the specified date and author
-	if err := r.DB.Where("author_email =? AND DATE(created_at) >=?", author, date).Find(&tickets).Error; err != nil {
-		return nil, err
-	}
+		if result.RowsAffected == 0 {
+			return errors.New("message not found")
+		}
+
+		return tx.Create(revision).Error
+	})
+}
 
-	if len(tickets) == 0 {
-		return nil, errors.New("no tickets found after the specified date and author")
+// UpdateTicketContent changes the name and explanation of a ticket and records the revision.
+// When onlyStatus is set the ticket is only changed if it is still in that status.
+func (r *TicketRepository) UpdateTicketContent(revision *models.TicketRevision, onlyStatus models.TicketStatus) error {
+	updates := map[string]interface{}{}
+	if revision.Name != "" {
+		updates["name"] = revision.Name
+	}
+	if revision.Explanation != "" {
+		updates["explanation"] = revision.Explanation
 	}
 
-	return tickets, nil
-}
+	return database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		query := tx.Model(&models.Ticket{}).Where("id = ?", revision.TicketID)
+		if onlyStatus != "" {
+			query = query.Where("status = ?", onlyStatus)
+		}
 
-// GetTicketsByAuthorAndStatusAndDate gets tickets by date and status
-func (r *TicketRepository) GetTicketsByStatusAndDate(status models.TicketStatus, date string) ([]models.Ticket, error) {
-	var tickets []models.Ticket
+		result := query.Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return errors.New("ticket changed while it was being edited")
+		}
 
-	// Query tickets created on or after the specified date and status
-	if err := r.DB.Where("status =? AND DATE(created_at) >=?", status, date).Find(&tickets).Error; err != nil {
+		return tx.Create(revision).Error
+	})
+}
+
+// GetMessageRevisions gets the edit and delete audit trail of a message
+func (r *TicketRepository) GetMessageRevisions(historyID uint) ([]models.TicketHistoryRevision, error) {
+	var revisions []models.TicketHistoryRevision
+	if err := r.DB.Where("history_id = ?", historyID).Order("created_at ASC, id ASC").Find(&revisions).Error; err != nil {
 		return nil, err
 	}
+	return revisions, nil
+}
 
-	if len(tickets) == 0 {
-		return nil, errors.New("no tickets found after the specified date and status")
-	}
+// TransitionTicketStatus moves a ticket to a new status and records the change in the status log.
+// The update only happens if the ticket is still in the status the transition started from.
+func (r *TicketRepository) TransitionTicketStatus(change *models.TicketStatusChange) error {
+	return database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		var ticket models.Ticket
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id, status, author_id, assignee_id").
+			Where("id = ?", change.TicketID).First(&ticket).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("ticket not found")
+			}
+			return err
+		}
 
-	return tickets, nil
-}
+		if ticket.Status != change.FromStatus {
+			return errors.New("ticket status was changed by someone else")
+		}
 
-// GetTicketsByAuthorAndStatusAndDate gets tickets by author, status and date
-func (r *TicketRepository) GetTicketsByAuthorAndStatusAndDate(authorEmail string, status models.TicketStatus, date string) ([]models.Ticket, error) {
-	var tickets []models.Ticket
+		deltas := counterDeltas{}
+		deltas.add(ticketCountOf(&ticket), -1)
+		moved := ticketCountOf(&ticket)
+		moved.Status = change.ToStatus
+		deltas.add(moved, 1)
+
+		// The resolution time is the one of the status log entry closing the ticket
+		updates := map[string]interface{}{"status": change.ToStatus}
+		switch {
+		case change.ToStatus.IsClosed() && !ticket.Status.IsClosed():
+			change.CreatedAt = time.Now()
+			updates["resolved_at"] = change.CreatedAt
+		case !change.ToStatus.IsClosed():
+			updates["resolved_at"] = nil
+		}
 
-	// Query tickets created on or after the specified date, author and status
-	if err := r.DB.Where("author_email =? AND status =? AND DATE(created_at) >=?", authorEmail, status, date).Find(&tickets).Error; err != nil {
-		return nil, err
-	}
+		if err := tx.Model(&models.Ticket{}).Where("id = ?", ticket.ID).Updates(updates).Error; err != nil {
+			return err
+		}
 
-	if len(tickets) == 0 {
-		return nil, errors.New("no tickets found after the specified date, author and status")
-	}
+		if err := deltas.apply(tx); err != nil {
+			return err
+		}
 
-	return tickets, nil
+		return tx.Create(change).Error
+	})
+}
+
+// GetStatusChanges gets the status log of a ticket in chronological order
+func (r *TicketRepository) GetStatusChanges(ticketID string) ([]models.TicketStatusChange, error) {
+	var changes []models.TicketStatusChange
+	if err := r.DB.Where("ticket_id = ?", ticketID).Order("created_at ASC, id ASC").Find(&changes).Error; err != nil {
+		return nil, err
+	}
+	return changes, nil
 }
 
-// UpdateTicketStatus updates a ticket's status
-func (r *TicketRepository) UpdateTicketStatus(id string, status models.TicketStatus) error {
+// UpdateTicketAssignee sets the agent working on a ticket, nil removes the current one
+func (r *TicketRepository) UpdateTicketAssignee(id string, assigneeID *uint) error {
 	return database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
-		//get status of ticket id
 		var ticket models.Ticket
-		if err := tx.Where("id =?", id).First(&ticket).Error; err != nil {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id, status, author_id, assignee_id").
+			Where("id = ?", id).First(&ticket).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("ticket not found")
+			}
 			return err
 		}
 
-		// get current status of ticket
-		currentStatus := ticket.Status
+		// The ticket moves from the counters of its former agent to the ones of the new agent
+		deltas := counterDeltas{}
+		deltas.add(ticketCountOf(&ticket), -1)
+		moved := ticketCountOf(&ticket)
+		moved.AssigneeID = assigneeID
+		deltas.add(moved, 1)
 
-		if currentStatus == status {
-			return errors.New("New status is the same as the current status")
+		if err := tx.Model(&models.Ticket{}).Where("id = ?", ticket.ID).Update("assignee_id", assigneeID).Error; err != nil {
+			return err
 		}
 
-		result := tx.Model(&models.Ticket{}).Where("id = ?", id).Update("status", status)
+		return deltas.apply(tx)
+	})
+}
 
-		if result.RowsAffected == 0 {
-			return errors.New("ticket not found")
-		}
+// GetAgentWorkloads counts the open tickets matching the filter assigned to each admin and master user
+func (r *TicketRepository) GetAgentWorkloads(filter TicketFilter) ([]models.AgentWorkload, error) {
+	open := filter.apply(r.DB.Model(&models.Ticket{}).
+		Select("tickets.id, tickets.assignee_id").
+		Where("tickets.status NOT IN ?", models.ClosedStatuses))
+
+	var workloads []models.AgentWorkload
+	err := r.DB.Table("users").
+		Select("users.id AS agent_id, users.username AS agent_name, users.email AS agent_email, COUNT(assigned.id) AS open_tickets").
+		Joins("LEFT JOIN (?) AS assigned ON assigned.assignee_id = users.id", open).
+		Where("users.role IN ? AND users.deleted_at IS NULL", []models.Role{models.AdminRole, models.MasterRole}).
+		Group("users.id, users.username, users.email").
+		Order("open_tickets DESC, users.username ASC").
+		Scan(&workloads).Error
+	if err != nil {
+		return nil, err
+	}
+	return workloads, nil
+}
+
+// CountUnassignedOpenTickets counts the open tickets matching the filter nobody is working on
+func (r *TicketRepository) CountUnassignedOpenTickets(filter TicketFilter) (int64, error) {
+	var count int64
+	err := filter.apply(r.DB.Model(&models.Ticket{})).
+		Where("tickets.assignee_id IS NULL AND tickets.status NOT IN ?", models.ClosedStatuses).
+		Count(&count).Error
+	return count, err
+}
 
+// UpdateTicketPriority changes a ticket's priority along with its SLA deadlines
+func (r *TicketRepository) UpdateTicketPriority(id string, priority models.TicketPriority, firstResponseDue, resolutionDue *time.Time) error {
+	result := r.DB.Model(&models.Ticket{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"priority":              priority,
+		"first_response_due_at": firstResponseDue,
+		"resolution_due_at":     resolutionDue,
+		"sla_state":             models.SLAOk, // re-evaluated by the SLA worker on its next run
+	})
+	if result.Error != nil {
 		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return errors.New("ticket not found")
+	}
+
+	return nil
+}
+
+// MarkFirstResponse records when a ticket got its first answer, later answers are ignored
+func (r *TicketRepository) MarkFirstResponse(id string, respondedAt time.Time) error {
+	return r.DB.Model(&models.Ticket{}).
+		Where("id = ? AND first_response_at IS NULL", id).
+		Update("first_response_at", respondedAt).Error
+}
+
+// RefreshSLAStates flags the open tickets that breached or are about to breach their SLA deadlines
+func (r *TicketRepository) RefreshSLAStates(now time.Time, warning time.Duration) (breached int64, atRisk int64, err error) {
+	err = database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		overdue := func(limit time.Time) *gorm.DB {
+			return tx.Where("(first_response_at IS NULL AND first_response_due_at < ?) OR resolution_due_at < ?", limit, limit)
+		}
+
+		result := tx.Model(&models.Ticket{}).
+			Where("status NOT IN ? AND sla_state <> ?", models.ClosedStatuses, models.SLABreached).
+			Where(overdue(now)).
+			Update("sla_state", models.SLABreached)
+		if result.Error != nil {
+			return result.Error
+		}
+		breached = result.RowsAffected
+
+		result = tx.Model(&models.Ticket{}).
+			Where("status NOT IN ? AND sla_state = ?", models.ClosedStatuses, models.SLAOk).
+			Where(overdue(now.Add(warning))).
+			Update("sla_state", models.SLAAtRisk)
+		if result.Error != nil {
+			return result.Error
+		}
+		atRisk = result.RowsAffected
+
+		// Tickets answered in time are no longer at risk because of the first response deadline
+		return tx.Model(&models.Ticket{}).
+			Where("status NOT IN ? AND sla_state = ?", models.ClosedStatuses, models.SLAAtRisk).
+			Not(overdue(now.Add(warning))).
+			Update("sla_state", models.SLAOk).Error
 	})
+	return breached, atRisk, err
 }
 
 // AddTicketHistory adds a new entry to the ticket's history
@@ -308,29 +541,21 @@ func (r *TicketRepository) AddTicketHistory(history *models.TicketHistory) error
 	return r.DB.Create(history).Error
 }
 
-// DeleteTicket deletes a ticket
+// DeleteTicket moves a ticket to the trash
 func (r *TicketRepository) DeleteTicket(id string) error {
 	return database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
-		// Delete images associated with the ticket
-		if err := tx.Where("ticket_id = ?", id).Delete(&models.Image{}).Error; err != nil {
-			return err
-		}
+		// The ticket goes to the trash with its images and history, it can be restored until it is purged
+		ticket := tx.Model(&models.Ticket{}).Select("id").Where("id = ?", id)
 
-		// Delete history associated with the ticket
-		if err := tx.Where("ticket_id = ?", id).Delete(&models.TicketHistory{}).Error; err != nil {
+		trashed, err := trashTickets(tx, ticket, time.Now())
+		if err != nil {
 			return err
 		}
 
-		// Delete the ticket
-		result := tx.Where("id = ?", id).Delete(&models.Ticket{})
-		if result.RowsAffected == 0 {
+		if trashed == 0 {
 			return errors.New("ticket not found")
 		}
 
-		if result.Error != nil {
-			return result.Error
-		}
-
 		return nil
 	})
 }
@@ -340,27 +565,57 @@ func (r *TicketRepository) CreateImage(image *models.Image) error {
 	return r.DB.Create(image).Error
 }
 
-// Add these new methods
-func (r *TicketRepository) GetTicketsByName(name string) ([]models.Ticket, error) {
-	var tickets []models.Ticket
-	result := r.DB.Where("name ILIKE ?", "%"+name+"%").Find(&tickets)
-	return tickets, result.Error
+// GetImage gets an image by ID
+func (r *TicketRepository) GetImage(id string) (*models.Image, error) {
+	var image models.Image
+	result := r.DB.Where("id = ?", id).First(&image)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, errors.New("image not found")
+	}
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &image, nil
 }
 
-func (r *TicketRepository) GetTicketsByAuthorAndName(authorEmail, name string) ([]models.Ticket, error) {
-	var tickets []models.Ticket
-	result := r.DB.Where("author_email = ? AND name ILIKE ?", authorEmail, "%"+name+"%").Find(&tickets)
-	return tickets, result.Error
+// GetTicketImageUsage gets how many images a ticket has and their total size
+func (r *TicketRepository) GetTicketImageUsage(ticketID string) (int64, int64, error) {
+	var usage struct {
+		Count int64
+		Size  int64
+	}
+	err := r.DB.Model(&models.Image{}).Select("COUNT(*) AS count, COALESCE(SUM(size), 0) AS size").
+		Where("ticket_id = ? AND deleted_at IS NULL", ticketID).Scan(&usage).Error
+	if err != nil {
+		return 0, 0, err
+	}
+	return usage.Count, usage.Size, nil
 }
 
-func (r *TicketRepository) GetTicketsByStatusAndName(status models.TicketStatus, name string) ([]models.Ticket, error) {
-	var tickets []models.Ticket
-	result := r.DB.Where("status = ? AND name ILIKE ?", status, "%"+name+"%").Find(&tickets)
-	return tickets, result.Error
+// GetImagesWithoutStorage gets a batch of legacy images whose content is still kept as base64 in the database,
+// leaving out the images of excludeIDs
+func (r *TicketRepository) GetImagesWithoutStorage(limit int, excludeIDs []string) ([]models.Image, error) {
+	var images []models.Image
+	query := r.DB.Where("(storage_key IS NULL OR storage_key = '') AND base64 IS NOT NULL AND base64 <> ''")
+	if len(excludeIDs) > 0 {
+		query = query.Where("id NOT IN ?", excludeIDs)
+	}
+
+	err := query.Order("uploaded_at ASC").Limit(limit).Find(&images).Error
+	if err != nil {
+		return nil, err
+	}
+	return images, nil
 }
 
-func (r *TicketRepository) GetTicketsByDateAndName(date, name string) ([]models.Ticket, error) {
-	var tickets []models.Ticket
-	result := r.DB.Where("DATE(created_at) = ? AND name ILIKE ?", date, "%"+name+"%").Find(&tickets)
-	return tickets, result.Error
+// MoveImageToStorage points an image to its blob store content and drops the base64 copy
+func (r *TicketRepository) MoveImageToStorage(id, storageKey string, size int64) error {
+	return r.DB.Model(&models.Image{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"storage_key": storageKey,
+		"size":        size,
+		"base64":      gorm.Expr("NULL"),
+	}).Error
 }
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"strconv"
+
+	"hcall/api/config"
+	"hcall/api/models"
+
+	"gorm.io/gorm"
+)
+
+// headlineOptions configures the snippets returned by the search
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxWords=30, MinWords=10, MaxFragments=2, FragmentDelimiter=\" ... \""
+
+// searchSortColumns are the fields search results can be sorted by, the ticket fields plus relevance
+var searchSortColumns = func() map[string]sortColumn[models.Ticket] {
+	columns := map[string]sortColumn[models.Ticket]{
+		"relevance": {
+			expr: "tickets.search_rank",
+			key: func(t *models.Ticket) string {
+				return strconv.FormatFloat(t.SearchRank, 'g', -1, 64)
+			},
+			parse: func(key string) (interface{}, error) {
+				return strconv.ParseFloat(key, 64)
+			},
+		},
+	}
+	for name, column := range ticketSortColumns {
+		columns[name] = column
+	}
+	return columns
+}()
+
+// escapedHTML is the SQL expression of a text escaped for HTML. The snippets are built from the escaped
+// text so the <mark> tags are the only markup they hold, whatever the users wrote.
+func escapedHTML(text string) string {
+	return "replace(replace(replace(replace(replace(" + text +
+		", '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '\"', '&quot;'), '''', '&#39;')"
+}
+
+// tsQuery converts the text typed by the user into a full-text query, quotes and "or" are understood
+func tsQuery(text string) interface{} {
+	return gorm.Expr("websearch_to_tsquery(?::regconfig, ?)", config.AppConfig.SearchLanguage, text)
+}
+
+// SearchTickets gets the tickets whose name, explanation or conversation match the text and the filter.
+// Each ticket comes with its SearchRank, the results are sorted by it unless the page says otherwise.
+func (r *TicketRepository) SearchTickets(filter TicketFilter, text string) ([]models.Ticket, error) {
+	matches := filter.apply(r.DB.Model(&models.Ticket{})).
+		Select("tickets.*, ts_rank_cd(tickets.search_vector, ?) AS search_rank", tsQuery(text)).
+		Where("tickets.search_vector @@ ?", tsQuery(text))
+
+	// Keep the tickets alias so the sort columns apply to the results
+	query := r.DB.Table("(?) AS tickets", matches)
+
+	var tickets []models.Ticket
+	var err error
+	if r.page != nil {
+		tickets, err = findPage(query, r.page, searchSortColumns, "tickets.id", func(t *models.Ticket) string { return t.ID }, "Assignee", "Queue", "Tags")
+	} else {
+		err = query.Preload("Assignee").Preload("Queue").Preload("Tags").Order("tickets.search_rank DESC, tickets.id DESC").Find(&tickets).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if tickets == nil {
+		tickets = []models.Ticket{}
+	}
+	return tickets, nil
+}
+
+// GetSearchHighlights gets the snippets of the given tickets matching the text, by ticket ID.
+// The snippets are HTML escaped, only the matched words are wrapped in <mark> tags.
+func (r *TicketRepository) GetSearchHighlights(ticketIDs []string, text string) (map[string]models.TicketHighlight, error) {
+	highlights := make(map[string]models.TicketHighlight, len(ticketIDs))
+	if len(ticketIDs) == 0 {
+		return highlights, nil
+	}
+
+	language := config.AppConfig.SearchLanguage
+	var rows []models.TicketHighlight
+	err := r.DB.Model(&models.Ticket{}).
+		Select(`tickets.id,
+			ts_headline(?::regconfig, `+escapedHTML("tickets.name")+`, ?, ?) AS name,
+			ts_headline(?::regconfig, `+escapedHTML("tickets.explanation")+`, ?, ?) AS explanation,
+			ts_headline(?::regconfig, `+escapedHTML(`coalesce((
+				SELECT string_agg(h.message, ' ' ORDER BY h.created_at)
+				FROM ticket_histories h
+				WHERE h.ticket_id = tickets.id AND h.deleted_at IS NULL
+			), '')`)+`, ?, ?) AS messages`,
+			language, tsQuery(text), headlineOptions,
+			language, tsQuery(text), headlineOptions,
+			language, tsQuery(text), headlineOptions).
+		Where("tickets.id IN ?", ticketIDs).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		highlights[row.TicketID] = row
+	}
+	return highlights, nil
+}
@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"hcall/api/database"
+	"hcall/api/models"
+
+	"gorm.io/gorm"
+)
+
+// trashSortColumns are the fields trash listings can be sorted by, the ticket fields plus the deletion date
+var trashSortColumns = func() map[string]sortColumn[models.Ticket] {
+	columns := map[string]sortColumn[models.Ticket]{
+		"deleted": timeColumn("tickets.deleted_at", func(t *models.Ticket) time.Time { return t.DeletedAt.Time }),
+	}
+	for name, column := range ticketSortColumns {
+		columns[name] = column
+	}
+	return columns
+}()
+
+// trashTickets moves the tickets selected by the ids subquery to the trash, with their images and history.
+// Everything gets the same deletion date, so a restore brings back exactly what was trashed with the ticket.
+func trashTickets(tx *gorm.DB, ids *gorm.DB, now time.Time) (int64, error) {
+	// Trashed tickets aren't counted anymore
+	if err := uncountTickets(tx, ids); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Model(&models.Image{}).Where("ticket_id IN (?)", ids).Update("deleted_at", now).Error; err != nil {
+		return 0, err
+	}
+
+	if err := tx.Model(&models.TicketHistory{}).Where("ticket_id IN (?)", ids).Update("deleted_at", now).Error; err != nil {
+		return 0, err
+	}
+
+	// The tickets go last, the subquery doesn't select them anymore once they are trashed
+	result := tx.Model(&models.Ticket{}).Where("id IN (?)", ids).Update("deleted_at", now)
+	return result.RowsAffected, result.Error
+}
+
+// purgeTickets permanently deletes the tickets selected by the ids subquery and everything attached to them.
+// It returns the blob store keys of the deleted images so their content can be deleted too.
+func purgeTickets(tx *gorm.DB, ids *gorm.DB) ([]string, int64, error) {
+	var storageKeys []string
+	if err := tx.Unscoped().Model(&models.Image{}).Where("ticket_id IN (?) AND storage_key <> ''", ids).
+		Pluck("storage_key", &storageKeys).Error; err != nil {
+		return nil, 0, err
+	}
+
+	histories := tx.Unscoped().Model(&models.TicketHistory{}).Select("id").Where("ticket_id IN (?)", ids)
+	if err := tx.Where("history_id IN (?)", histories).Delete(&models.TicketHistoryRevision{}).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := tx.Unscoped().Where("ticket_id IN (?)", ids).Delete(&models.TicketHistory{}).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := tx.Unscoped().Where("ticket_id IN (?)", ids).Delete(&models.Image{}).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := tx.Exec("DELETE FROM ticket_tags WHERE ticket_id IN (?)", ids).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := tx.Where("ticket_id IN (?)", ids).Delete(&models.TicketRevision{}).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := tx.Where("ticket_id IN (?)", ids).Delete(&models.TicketStatusChange{}).Error; err != nil {
+		return nil, 0, err
+	}
+
+	result := tx.Unscoped().Where("id IN (?)", ids).Delete(&models.Ticket{})
+	return storageKeys, result.RowsAffected, result.Error
+}
+
+// FindTrashedTickets gets the trashed tickets matching the filter
+func (r *TicketRepository) FindTrashedTickets(filter TicketFilter) ([]models.Ticket, error) {
+	query := filter.apply(r.DB.Unscoped().Model(&models.Ticket{}).Where("tickets.deleted_at IS NOT NULL"))
+	if r.page != nil {
+		return findPage(query, r.page, trashSortColumns, "tickets.id", func(t *models.Ticket) string { return t.ID }, "Assignee", "Queue", "Tags")
+	}
+
+	var tickets []models.Ticket
+	if err := query.Preload("Assignee").Preload("Queue").Preload("Tags").Order("tickets.deleted_at DESC").Find(&tickets).Error; err != nil {
+		return nil, err
+	}
+	return tickets, nil
+}
+
+// GetTrashedTicket gets a ticket from the trash
+func (r *TicketRepository) GetTrashedTicket(id string) (*models.Ticket, error) {
+	var ticket models.Ticket
+	result := r.DB.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).First(&ticket)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, errors.New("ticket not found in trash")
+	}
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &ticket, nil
+}
+
+// RestoreTicket brings a ticket back from the trash, with the images and messages trashed along with it.
+// Messages deleted before the ticket was trashed stay deleted.
+func (r *TicketRepository) RestoreTicket(id string) error {
+	return database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		var ticket models.Ticket
+		if err := tx.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).First(&ticket).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("ticket not found in trash")
+			}
+			return err
+		}
+
+		deletedAt := ticket.DeletedAt.Time
+		if err := tx.Unscoped().Model(&models.Image{}).Where("ticket_id = ? AND deleted_at = ?", id, deletedAt).
+			Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Model(&models.TicketHistory{}).Where("ticket_id = ? AND deleted_at = ?", id, deletedAt).
+			Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Model(&models.Ticket{}).Where("id = ?", id).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+
+		deltas := counterDeltas{}
+		deltas.add(ticketCountOf(&ticket), 1)
+		return deltas.apply(tx)
+	})
+}
+
+// PurgeTicket permanently deletes a trashed ticket, returning the blob store keys of its images
+func (r *TicketRepository) PurgeTicket(id string) ([]string, error) {
+	var storageKeys []string
+	err := database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		ticket := tx.Unscoped().Model(&models.Ticket{}).Select("id").Where("id = ? AND deleted_at IS NOT NULL", id)
+
+		keys, purged, err := purgeTickets(tx, ticket)
+		if err != nil {
+			return err
+		}
+
+		if purged == 0 {
+			return errors.New("ticket not found in trash")
+		}
+
+		storageKeys = keys
+		return nil
+	})
+	return storageKeys, err
+}
+
+// PurgeTrashedTickets permanently deletes the tickets trashed before the given date.
+// It returns the blob store keys of their images and how many tickets were purged.
+func (r *TicketRepository) PurgeTrashedTickets(before time.Time) ([]string, int64, error) {
+	var storageKeys []string
+	var purged int64
+	err := database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		expired := tx.Unscoped().Model(&models.Ticket{}).Select("id").Where("deleted_at < ?", before)
+
+		var err error
+		storageKeys, purged, err = purgeTickets(tx, expired)
+		return err
+	})
+	return storageKeys, purged, err
+}
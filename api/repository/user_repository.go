@@ -2,15 +2,19 @@ package repository
 
 import (
 	"errors"
+	"strconv"
+	"time"
 
 	"hcall/api/database"
 	"hcall/api/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type UserRepository struct {
-	DB *gorm.DB
+	DB   *gorm.DB
+	page *models.Page
 }
 
 func NewUserRepository() *UserRepository {
@@ -19,11 +23,48 @@ func NewUserRepository() *UserRepository {
 	}
 }
 
+// Paginate returns a copy of the repository whose user listings only return the given page.
+// The page total and next cursor are filled by the listing.
+func (r *UserRepository) Paginate(page *models.Page) *UserRepository {
+	return &UserRepository{
+		DB:   r.DB,
+		page: page,
+	}
+}
+
+// userSortColumns are the fields user listings can be sorted by
+var userSortColumns = map[string]sortColumn[models.User]{
+	"created": timeColumn("users.created_at", func(u *models.User) time.Time { return u.CreatedAt }),
+	"updated": timeColumn("users.updated_at", func(u *models.User) time.Time { return u.UpdatedAt }),
+}
+
+// find runs a user listing query, only returning the repository page when there is one
+func (r *UserRepository) find(query *gorm.DB) ([]models.User, error) {
+	if r.page != nil {
+		return findPage(query, r.page, userSortColumns, "users.id", func(u *models.User) string { return strconv.FormatUint(uint64(u.ID), 10) })
+	}
+
+	var users []models.User
+	if err := query.Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
 // CreateUser creates a new user
 func (r *UserRepository) CreateUser(user *models.User) error {
 	return r.DB.Create(user).Error
 }
 
+// EmailInUse tells whether the email belongs to a user, trashed users included since they can be restored
+func (r *UserRepository) EmailInUse(email string) (bool, error) {
+	var count int64
+	if err := r.DB.Unscoped().Model(&models.User{}).Where("email = ?", email).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
 // FindByEmail finds a user by email
 func (r *UserRepository) FindByEmail(email string) (*models.User, error) {
 	var user models.User
@@ -56,6 +97,29 @@ func (r *UserRepository) FindByID(id uint) (*models.User, error) {
 	return &user, nil
 }
 
+// FindByEmails finds the users with the given emails, unknown emails are skipped
+func (r *UserRepository) FindByEmails(emails []string) ([]models.User, error) {
+	var users []models.User
+	if err := r.DB.Where("email IN ?", emails).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// GetUsernames gets the names of the given users, the trashed ones included
+func (r *UserRepository) GetUsernames(ids []uint) (map[uint]string, error) {
+	var users []models.User
+	if err := r.DB.Unscoped().Select("id, username").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	names := make(map[uint]string, len(users))
+	for _, user := range users {
+		names[user.ID] = user.Username
+	}
+	return names, nil
+}
+
 // FindMaster finds a master user
 func (r *UserRepository) FindMaster() (*models.User, error) {
 	var user models.User
@@ -74,17 +138,13 @@ func (r *UserRepository) FindMaster() (*models.User, error) {
 
 // GetUsers gets all users
 func (r *UserRepository) GetUsers() ([]models.User, error) {
-	var users []models.User
-	if err := r.DB.Find(&users).Error; err != nil {
-		return nil, err
-	}
-	return users, nil
+	return r.find(r.DB.Model(&models.User{}))
 }
 
 // GetUsersByRole gets users by role
 func (r *UserRepository) GetUsersByRole(role models.Role) ([]models.User, error) {
-	var users []models.User
-	if err := r.DB.Where("role = ?", role).Find(&users).Error; err != nil {
+	users, err := r.find(r.DB.Model(&models.User{}).Where("role = ?", role))
+	if err != nil {
 		return nil, err
 	}
 
@@ -95,9 +155,26 @@ func (r *UserRepository) GetUsersByRole(role models.Role) ([]models.User, error)
 	return users, nil
 }
 
-// DeleteUser deletes a user by email
+// GetServiceAccounts gets the service accounts
+func (r *UserRepository) GetServiceAccounts() ([]models.User, error) {
+	var users []models.User
+	err := r.DB.Where("service_account = ?", true).Order("created_at DESC").Find(&users).Error
+	return users, err
+}
+
+// DeleteUser moves a user to the trash by email. Its sessions, with their access tokens, and its API
+// keys are revoked in the same transaction, so nothing it was logged in with outlives the deletion.
 func (r *UserRepository) DeleteUser(email string) error {
 	return database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		var user models.User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("email = ?", email).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("user not found")
+		}
+		if err != nil {
+			return err
+		}
+
 		// Verifique se existe algum ticket associado a este usuário
 		var count int64
 		if err := tx.Model(&models.Ticket{}).Where("author_email = ?", email).Count(&count).Error; err != nil {
@@ -108,13 +185,16 @@ func (r *UserRepository) DeleteUser(email string) error {
 			return errors.New("cannot delete user with existing tickets")
 		}
 
-		result := tx.Where("email = ?", email).Delete(&models.User{})
+		now := time.Now()
+		if _, err := revokeSessions(tx, now, "user_id = ?", user.ID); err != nil {
+			return err
+		}
 
-		if result.RowsAffected == 0 {
-			return errors.New("user not found")
+		if _, err := revokeAPIKeys(tx, now, "user_id = ?", user.ID); err != nil {
+			return err
 		}
 
-		return result.Error
+		return tx.Delete(&user).Error
 	})
 }
 
@@ -136,7 +216,12 @@ func (r *UserRepository) DeleteMaster() error {
 			return errors.New("cannot delete master with existing tickets")
 		}
 
-		result := tx.Where("role = ?", models.MasterRole).Delete(&models.User{})
+		if _, err := revokeSessions(tx, time.Now(), "user_id = ?", master.ID); err != nil {
+			return err
+		}
+
+		// The master isn't trashed, a new one can be created right away
+		result := tx.Unscoped().Where("role = ?", models.MasterRole).Delete(&models.User{})
 
 		if result.RowsAffected == 0 {
 			return errors.New("master user not found")
@@ -145,3 +230,111 @@ func (r *UserRepository) DeleteMaster() error {
 		return result.Error
 	})
 }
+
+// trashUserSortColumns are the fields trashed user listings can be sorted by
+var trashUserSortColumns = map[string]sortColumn[models.User]{
+	"created": userSortColumns["created"],
+	"updated": userSortColumns["updated"],
+	"deleted": timeColumn("users.deleted_at", func(u *models.User) time.Time { return u.DeletedAt.Time }),
+}
+
+// GetTrashedUsers gets the users in the trash
+func (r *UserRepository) GetTrashedUsers() ([]models.User, error) {
+	query := r.DB.Unscoped().Model(&models.User{}).Where("users.deleted_at IS NOT NULL")
+	if r.page != nil {
+		return findPage(query, r.page, trashUserSortColumns, "users.id", func(u *models.User) string { return strconv.FormatUint(uint64(u.ID), 10) })
+	}
+
+	var users []models.User
+	if err := query.Order("users.deleted_at DESC").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// RestoreUser brings a user back from the trash
+func (r *UserRepository) RestoreUser(email string) error {
+	result := r.DB.Unscoped().Model(&models.User{}).
+		Where("email = ? AND deleted_at IS NOT NULL", email).
+		Update("deleted_at", nil)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return errors.New("user not found in trash")
+	}
+
+	return nil
+}
+
+// purgeUsers permanently deletes the users selected by the ids subquery. Their queue memberships,
+// API keys and single sign-on identities go with them and the tickets assigned to them are left
+// unassigned.
+func purgeUsers(tx *gorm.DB, ids *gorm.DB) (int64, error) {
+	if err := tx.Where("user_id IN (?)", ids).Delete(&models.QueueMember{}).Error; err != nil {
+		return 0, err
+	}
+
+	if err := tx.Where("user_id IN (?)", ids).Delete(&models.APIKey{}).Error; err != nil {
+		return 0, err
+	}
+
+	if err := tx.Where("user_id IN (?)", ids).Delete(&models.UserIdentity{}).Error; err != nil {
+		return 0, err
+	}
+
+	if err := tx.Unscoped().Model(&models.Ticket{}).Where("assignee_id IN (?)", ids).
+		Update("assignee_id", nil).Error; err != nil {
+		return 0, err
+	}
+
+	// The tickets they were assigned no longer count for them
+	if err := tx.Where("scope <> ? AND owner_id IN (?)", models.GlobalCounters, ids).Delete(&models.Counters{}).Error; err != nil {
+		return 0, err
+	}
+
+	result := tx.Unscoped().Where("id IN (?)", ids).Delete(&models.User{})
+	return result.RowsAffected, result.Error
+}
+
+// PurgeUser permanently deletes a trashed user. Users still authoring tickets, trashed ones included, are kept.
+func (r *UserRepository) PurgeUser(email string) error {
+	return database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Unscoped().Where("email = ? AND deleted_at IS NOT NULL", email).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("user not found in trash")
+			}
+			return err
+		}
+
+		var count int64
+		if err := tx.Unscoped().Model(&models.Ticket{}).Where("author_id = ?", user.ID).Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count > 0 {
+			return errors.New("cannot purge user with existing tickets")
+		}
+
+		_, err := purgeUsers(tx, tx.Unscoped().Model(&models.User{}).Select("id").Where("id = ?", user.ID))
+		return err
+	})
+}
+
+// PurgeTrashedUsers permanently deletes the users trashed before the given date, except the ones
+// still authoring tickets. It returns how many users were purged.
+func (r *UserRepository) PurgeTrashedUsers(before time.Time) (int64, error) {
+	var purged int64
+	err := database.ExecuteInTransaction(r.DB, func(tx *gorm.DB) error {
+		expired := tx.Unscoped().Model(&models.User{}).Select("id").
+			Where("deleted_at < ? AND NOT EXISTS (SELECT 1 FROM tickets WHERE tickets.author_id = users.id)", before)
+
+		var err error
+		purged, err = purgeUsers(tx, expired)
+		return err
+	})
+	return purged, err
+}
@@ -15,6 +15,12 @@ func SetupRoutes(router *gin.Engine) {
 	authController := controllers.NewAuthController()
 	userController := controllers.NewUserController()
 	ticketController := controllers.NewTicketController()
+	queueController := controllers.NewQueueController()
+	tagController := controllers.NewTagController()
+	trashController := controllers.NewTrashController()
+	archiveController := controllers.NewArchiveController()
+	importController := controllers.NewImportController()
+	serviceAccountController := controllers.NewServiceAccountController()
 
 	// Rota de health check (pública)
 	router.GET("/health", func(c *gin.Context) {
@@ -29,6 +35,15 @@ func SetupRoutes(router *gin.Engine) {
 		{
 			auth.POST("/register", authController.Register)
 			auth.POST("/enter", authController.Login) // Rota de login
+			auth.POST("/refresh", authController.Refresh)
+			auth.POST("/verify/request", authController.RequestEmailVerification)
+			auth.POST("/verify/confirm", authController.VerifyEmail)
+			auth.POST("/password/forgot", authController.RequestPasswordReset)
+			auth.POST("/password/reset", authController.ResetPassword)
+			auth.POST("/mfa/enroll", authController.EnrollMFA)
+			auth.POST("/mfa/verify", authController.VerifyMFA)
+			auth.GET("/oidc/start", authController.StartOIDCLogin)
+			auth.POST("/oidc/callback", authController.OIDCCallback)
 		}
 
 		// Rotas MASTER (protegidas por outro mecanismo, não por JWT)
@@ -38,34 +53,148 @@ func SetupRoutes(router *gin.Engine) {
 			master.POST("/delete", authController.DeleteMaster)
 		}
 
-		// Rotas PROTEGIDAS (exigem JWT)
+		// Rotas PROTEGIDAS (exigem JWT ou API key de service account)
 		protected := api.Group("/")
-		protected.Use(middlewares.AuthMiddleware()) // Middleware de JWT
+		protected.Use(middlewares.AuthMiddleware()) // Middleware de JWT e API keys
 		{
+			// Sessões do próprio usuário
+			session := protected.Group("/session")
+			session.Use(middlewares.JWTOnly())
+			{
+				session.POST("/logout", authController.Logout)
+				session.GET("/list", authController.GetSessions)
+				session.POST("/revoke-all", authController.RevokeSessions)
+			}
+
+			// Autenticação em dois fatores do próprio usuário
+			mfa := protected.Group("/mfa")
+			mfa.Use(middlewares.JWTOnly())
+			{
+				mfa.GET("/status", authController.GetMFAStatus)
+				mfa.POST("/setup", authController.SetupMFA)
+				mfa.POST("/activate", authController.ActivateMFA)
+				mfa.POST("/recovery-codes", authController.RegenerateRecoveryCodes)
+				mfa.POST("/disable", authController.DisableMFA)
+			}
+
 			// Rotas de usuário (apenas admin e master)
 			user := protected.Group("/user")
-			user.Use(middlewares.RoleAuthorization(models.AdminRole, models.MasterRole))
+			user.Use(middlewares.RoleAuthorization(models.AdminRole, models.MasterRole), middlewares.RequireScope(models.UsersScope))
 			{
 				user.GET("/fetch", userController.GetUsers)
 				user.POST("/create", userController.CreateUser)
 				user.POST("/delete", userController.DeleteUser)
+				// Sessões e segundo fator dos usuários (apenas com JWT)
+				user.POST("/sessions/revoke", middlewares.JWTOnly(), authController.RevokeUserSessions)
+				user.POST("/mfa/reset", middlewares.JWTOnly(), authController.ResetUserMFA)
+			}
+
+			// Service accounts e suas API keys (admin e master, apenas com JWT)
+			serviceAccount := protected.Group("/service-account")
+			serviceAccount.Use(middlewares.JWTOnly(), middlewares.RoleAuthorization(models.AdminRole, models.MasterRole))
+			{
+				serviceAccount.POST("/create", serviceAccountController.CreateServiceAccount)
+				serviceAccount.GET("/list", serviceAccountController.GetServiceAccounts)
+				serviceAccount.POST("/key/create", serviceAccountController.CreateAPIKey)
+				serviceAccount.GET("/key/list", serviceAccountController.GetAPIKeys)
+				serviceAccount.POST("/key/revoke", serviceAccountController.RevokeAPIKey)
+			}
+
+			// Rotas de filas (listagem para todos, gestão apenas master)
+			queue := protected.Group("/queue")
+			queue.Use(middlewares.RequireScope(models.QueuesScope))
+			{
+				queue.GET("/list", queueController.GetQueues)
+				queue.GET("/members", middlewares.RoleAuthorization(models.AdminRole, models.MasterRole), queueController.GetMembers)
+				queue.POST("/fields", middlewares.RoleAuthorization(models.AdminRole, models.MasterRole), queueController.UpdateFieldSchema)
+
+				manageQueue := queue.Group("/")
+				manageQueue.Use(middlewares.RoleAuthorization(models.MasterRole))
+				{
+					manageQueue.POST("/create", queueController.CreateQueue)
+					manageQueue.POST("/delete", queueController.DeleteQueue)
+					manageQueue.POST("/member/add", queueController.AddMember)
+					manageQueue.POST("/member/remove", queueController.RemoveMember)
+				}
+			}
+
+			// Rotas de tags (admin e master)
+			tag := protected.Group("/tag")
+			tag.Use(middlewares.RoleAuthorization(models.AdminRole, models.MasterRole), middlewares.RequireScope(models.TagsScope))
+			{
+				tag.GET("/list", tagController.GetTags)
+				tag.POST("/rename", tagController.RenameTag)
+				tag.POST("/merge", tagController.MergeTags)
+			}
+
+			// Rotas da lixeira (admin e master, purga apenas master)
+			trash := protected.Group("/trash")
+			trash.Use(middlewares.RoleAuthorization(models.AdminRole, models.MasterRole), middlewares.RequireScope(models.TrashScope))
+			{
+				trash.GET("/tickets", trashController.GetTrashedTickets)
+				trash.GET("/users", trashController.GetTrashedUsers)
+				trash.POST("/restore", trashController.Restore)
+				trash.POST("/purge", middlewares.RoleAuthorization(models.MasterRole), trashController.Purge)
+			}
+
+			// Rotas do arquivo de tickets (apenas master)
+			archive := protected.Group("/archive")
+			archive.Use(middlewares.RoleAuthorization(models.MasterRole))
+			{
+				archive.GET("/search", archiveController.SearchArchive)
+				archive.GET("/info", archiveController.GetArchivedTicket)
+				archive.POST("/import", archiveController.ImportArchivedTicket)
 			}
 
 			// Rotas de tickets
 			ticket := protected.Group("/ticket")
+			ticket.Use(middlewares.RequireScope(models.TicketsScope))
 			{
 				ticket.POST("/create", ticketController.CreateTicket)
 				ticket.POST("/remove", ticketController.DeleteTicket)
 				ticket.GET("/count", ticketController.CountTicket)
 
+				// Nome e descrição (autor enquanto pendente, admin/master sempre)
+				ticket.POST("/content/edit", ticketController.EditTicketContent)
+
+				// Tickets do próprio usuário (filtrados pelo userId do JWT)
+				ticket.GET("/mine", ticketController.GetMyTickets)
+				ticket.GET("/mine/info", ticketController.GetMyTicketDetails)
+				ticket.GET("/mine/images", ticketController.GetMyTicketImages)
+				ticket.GET("/mine/history", ticketController.GetMyTicketHistory)
+				ticket.POST("/mine/reply", ticketController.ReplyToTicket)
+
+				// Conteúdo das imagens (autor do ticket ou admin/master)
+				ticket.GET("/image", ticketController.DownloadImage)
+				ticket.POST("/image/upload", ticketController.UploadTicketImages)
+
+				// Mensagens da conversa (autor da mensagem ou admin/master)
+				ticket.POST("/message/edit", ticketController.EditTicketMessage)
+				ticket.POST("/message/delete", ticketController.DeleteTicketMessage)
+
 				// Subgrupo com permissão adicional (admin/master)
 				authTicket := ticket.Group("/")
 				authTicket.Use(middlewares.RoleAuthorization(models.AdminRole, models.MasterRole))
 				{
 					authTicket.GET("/fetch", ticketController.GetTickets)
+					authTicket.GET("/search", ticketController.SearchTickets)
+					authTicket.GET("/export", ticketController.ExportTickets)
+					authTicket.POST("/import", importController.ImportTickets)
 					authTicket.GET("/info", ticketController.GetTicketDetails)
 					authTicket.POST("/edit", ticketController.UpdateTicketStatus)
+					authTicket.GET("/timeline", ticketController.GetStatusTimeline)
 					authTicket.POST("/update", ticketController.UpdateTicketHistory)
+					authTicket.GET("/message/audit", ticketController.GetMessageAudit)
+					authTicket.POST("/priority", ticketController.UpdateTicketPriority)
+					authTicket.POST("/assign", ticketController.AssignTicket)
+					authTicket.POST("/unassign", ticketController.UnassignTicket)
+					authTicket.GET("/workload", ticketController.GetWorkloads)
+					authTicket.GET("/metrics", ticketController.GetMetrics)
+					authTicket.GET("/count/authors", ticketController.GetAuthorCounters)
+					authTicket.GET("/count/agents", ticketController.GetAgentCounters)
+					authTicket.POST("/count/recompute", ticketController.RecomputeCounters)
+					authTicket.POST("/tags/add", ticketController.AddTicketTags)
+					authTicket.POST("/tags/remove", ticketController.RemoveTicketTags)
 				}
 			}
 		}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"hcall/api/archive"
+	"hcall/api/models"
+	"hcall/api/repository"
+	"hcall/api/utils"
+)
+
+// ArchiveService searches the tickets exported by the retention worker and brings them back
+type ArchiveService struct {
+	archiver    *archive.Archiver
+	archiveRepo *repository.ArchiveRepository
+}
+
+func NewArchiveService() *ArchiveService {
+	return &ArchiveService{
+		archiver:    archive.NewArchiver(),
+		archiveRepo: repository.NewArchiveRepository(),
+	}
+}
+
+// SearchArchive gets a page of the archive index entries matching the query, most recently archived first
+func (s *ArchiveService) SearchArchive(query utils.SearchArchiveQuery) ([]models.ArchivedTicket, *models.Page, error) {
+	filter := repository.ArchiveFilter{
+		Text:        query.Query,
+		AuthorEmail: query.Author,
+		Queue:       query.Queue,
+		Tag:         query.Tag,
+	}
+
+	var err error
+	if filter.Statuses, err = parseEnumList("invalid status filter", query.Status, validStatuses); err != nil {
+		return nil, nil, err
+	}
+
+	if query.ArchivedFrom != "" {
+		from, err := parseDay(query.ArchivedFrom)
+		if err != nil {
+			return nil, nil, err
+		}
+		filter.ArchivedFrom = &from
+	}
+
+	// Include the tickets archived during the last day
+	if query.ArchivedTo != "" {
+		to, err := parseDay(query.ArchivedTo)
+		if err != nil {
+			return nil, nil, err
+		}
+		to = to.AddDate(0, 0, 1)
+		filter.ArchivedTo = &to
+	}
+
+	page := newSortedPage(query.PageQuery, "archived")
+	entries, err := s.archiveRepo.Paginate(page).SearchEntries(filter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return entries, page, nil
+}
+
+// GetArchivedTicket reads an archived ticket from its archive file
+func (s *ArchiveService) GetArchivedTicket(ticketID string) (*models.ArchiveRecord, error) {
+	return s.archiver.GetRecord(ticketID)
+}
+
+// ImportTicket brings an archived ticket back into the tickets
+func (s *ArchiveService) ImportTicket(ticketID string) (*models.Ticket, error) {
+	return s.archiver.Import(ticketID)
+}
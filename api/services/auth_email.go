@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"hcall/api/config"
+	"hcall/api/logger"
+	"hcall/api/mailer"
+	"hcall/api/models"
+	"hcall/api/utils"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// emailSendTimeout bounds the delivery of an email, which happens after the request is answered
+const emailSendTimeout = time.Minute
+
+// emailTemplate is the content of the emails sending a token, the link holds the token
+type emailTemplate struct {
+	subject string
+	path    string
+	body    string
+}
+
+var emailTemplates = map[models.EmailTokenPurpose]emailTemplate{
+	models.VerifyEmailPurpose: {
+		subject: "Verify your email",
+		path:    "/verify-email",
+		body: `Hello %s,
+
+Please verify your email by opening the link below:
+
+%s
+
+The link expires in %s. If you didn't create an account, you can ignore this email.
+`,
+	},
+	models.ResetPasswordPurpose: {
+		subject: "Reset your password",
+		path:    "/reset-password",
+		body: `Hello %s,
+
+A password reset was asked for your account. Choose a new password by opening the link below:
+
+%s
+
+The link expires in %s. If you didn't ask for it, you can ignore this email, your password is unchanged.
+`,
+	},
+}
+
+// emailTokenLifetime is how long a token of the purpose can be used
+func emailTokenLifetime(purpose models.EmailTokenPurpose) time.Duration {
+	if purpose == models.ResetPasswordPurpose {
+		return time.Duration(config.AppConfig.PasswordResetMinutes) * time.Minute
+	}
+	return time.Duration(config.AppConfig.EmailVerificationHours) * time.Hour
+}
+
+// describeLifetime writes the lifetime of a token for the emails
+func describeLifetime(lifetime time.Duration) string {
+	if lifetime < time.Hour {
+		return fmt.Sprintf("%d minutes", int(lifetime.Minutes()))
+	}
+	if lifetime == time.Hour {
+		return "1 hour"
+	}
+	return fmt.Sprintf("%d hours", int(lifetime.Hours()))
+}
+
+// checkEmailRate records a request to send a token to the email, failing past the rate limit.
+// Unknown emails are counted too so the limit doesn't tell which emails are registered.
+func (s *AuthService) checkEmailRate(email string, purpose models.EmailTokenPurpose) error {
+	window := time.Duration(config.AppConfig.EmailRateWindowMinutes) * time.Minute
+	count, err := s.tokenRepo.RecordRequest(strings.ToLower(strings.TrimSpace(email)), purpose, window)
+	if err != nil {
+		return err
+	}
+
+	if count > int64(config.AppConfig.EmailRateLimit) {
+		return errors.New("too many email requests")
+	}
+	return nil
+}
+
+// sendEmailToken creates a token for the user and emails it, the previous tokens for the same
+// purpose stop working. The email is delivered in the background, failures are only logged.
+func (s *AuthService) sendEmailToken(user *models.User, purpose models.EmailTokenPurpose) error {
+	token, err := newSecretToken()
+	if err != nil {
+		return err
+	}
+
+	lifetime := emailTokenLifetime(purpose)
+	err = s.tokenRepo.CreateToken(&models.EmailToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(lifetime),
+	})
+	if err != nil {
+		return err
+	}
+
+	template := emailTemplates[purpose]
+	link := strings.TrimRight(config.AppConfig.AppURL, "/") + template.path + "?token=" + token
+	message := mailer.Message{
+		To:      user.Email,
+		Subject: template.subject,
+		Body:    fmt.Sprintf(template.body, user.Username, link, describeLifetime(lifetime)),
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), emailSendTimeout)
+		defer cancel()
+
+		if err := s.mailer.Send(ctx, message); err != nil {
+			logger.Error("Auth Service: Failed to send email", map[string]interface{}{
+				"email":   user.Email,
+				"purpose": purpose,
+				"error":   err.Error(),
+			})
+		}
+	}()
+	return nil
+}
+
+// RequestEmailVerification sends a new verification email to the user with the email, when its
+// email isn't verified yet. Nothing tells whether the email is registered.
+func (s *AuthService) RequestEmailVerification(email string) error {
+	if err := s.checkEmailRate(email, models.VerifyEmailPurpose); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.FindByEmail(email)
+	if err != nil {
+		if err.Error() == "user not found" {
+			return nil
+		}
+		return err
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	return s.sendEmailToken(user, models.VerifyEmailPurpose)
+}
+
+// VerifyEmail verifies the email of the user of a verification token, the token can't be used again
+func (s *AuthService) VerifyEmail(token string) error {
+	return s.tokenRepo.VerifyEmail(hashToken(token))
+}
+
+// RequestPasswordReset sends a password reset email to the user with the email. Nothing tells
+// whether the email is registered.
+func (s *AuthService) RequestPasswordReset(email string) error {
+	if err := s.checkEmailRate(email, models.ResetPasswordPurpose); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.FindByEmail(email)
+	if err != nil {
+		if err.Error() == "user not found" {
+			return nil
+		}
+		return err
+	}
+
+	// Service accounts have no mailbox and no password to reset, single sign-on users have no
+	// password to reset either
+	if user.ServiceAccount || (user.Role != models.MasterRole && config.AppConfig.PasswordLoginDisabled(user.Email)) {
+		return nil
+	}
+
+	return s.sendEmailToken(user, models.ResetPasswordPurpose)
+}
+
+// ResetPassword replaces the password of the user of a reset token and logs the user out of
+// every session, the token can't be used again
+func (s *AuthService) ResetPassword(token, password string) error {
+	// A password refused by the policy doesn't use the token
+	if err := utils.ValidatePassword(password); err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	return s.tokenRepo.ResetPassword(hashToken(token), string(hashedPassword))
+}
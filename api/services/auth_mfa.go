@@ -0,0 +1,356 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"hcall/api/config"
+	"hcall/api/models"
+	"hcall/api/utils"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// mfaLockWindow and mfaLockAttempts limit the wrong codes given by a user over all its login
+	// challenges, so logging in again doesn't give more attempts
+	mfaLockWindow   = 15 * time.Minute
+	mfaLockAttempts = 10
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newRecoveryCodes generates the recovery codes of a user, returned along with their hashes
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, config.AppConfig.MFARecoveryCodes)
+	hashes := make([]string, len(codes))
+	for i := range codes {
+		data := make([]byte, 7)
+		if _, err := rand.Read(data); err != nil {
+			return nil, nil, err
+		}
+
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(data))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hashToken(code)
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode ignores the case and the separators of a recovery code
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// getMFA gets the second factor of a user, nil when there is none
+func (s *AuthService) getMFA(userID uint) (*models.UserMFA, error) {
+	mfa, err := s.mfaRepo.GetMFA(userID)
+	if err != nil {
+		if err.Error() == "mfa not found" {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return mfa, nil
+}
+
+// startChallenge creates the login challenge of a user whose password was checked, nil when the
+// user has no second factor and its role doesn't require one
+func (s *AuthService) startChallenge(user *models.User) (*models.MFAChallengeResponse, error) {
+	mfa, err := s.getMFA(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := mfa != nil && mfa.EnabledAt != nil
+	if !enabled && !config.AppConfig.MFARequired(string(user.Role)) {
+		return nil, nil
+	}
+
+	attempts, err := s.mfaRepo.CountFailedAttempts(user.ID, time.Now().Add(-mfaLockWindow))
+	if err != nil {
+		return nil, err
+	}
+
+	if attempts >= mfaLockAttempts {
+		return nil, errors.New("too many mfa attempts")
+	}
+
+	token, err := newSecretToken()
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(time.Duration(config.AppConfig.MFAChallengeMinutes) * time.Minute)
+	err = s.mfaRepo.CreateChallenge(&models.MFAChallenge{
+		ID:        uuid.NewString(),
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.MFAChallengeResponse{
+		Token:              token,
+		ExpiresAt:          expiresAt,
+		EnrollmentRequired: !enabled,
+	}, nil
+}
+
+// findChallenge finds the login challenge of a token and its user
+func (s *AuthService) findChallenge(mfaToken string) (*models.MFAChallenge, *models.User, error) {
+	challenge, err := s.mfaRepo.FindChallenge(hashToken(mfaToken), config.AppConfig.MFAMaxAttempts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Trashed users can't finish their login
+	user, err := s.userRepo.FindByID(challenge.UserID)
+	if err != nil {
+		return nil, nil, errors.New("invalid mfa token")
+	}
+
+	return challenge, user, nil
+}
+
+// checkMFACode checks a TOTP code, or a recovery code, of an enabled second factor. An accepted
+// code can't be used again.
+func (s *AuthService) checkMFACode(mfa *models.UserMFA, code string) error {
+	if step, ok := utils.ValidateTOTP(mfa.Secret, code, time.Now(), mfa.LastUsedStep); ok {
+		return s.mfaRepo.UseTOTPStep(mfa.UserID, step)
+	}
+
+	normalized := normalizeRecoveryCode(code)
+	if len(normalized) != 10 {
+		return errors.New("invalid mfa code")
+	}
+	return s.mfaRepo.UseRecoveryCode(mfa.UserID, hashToken(normalized))
+}
+
+// setupMFA gives a user a new pending second factor to set up in an authenticator app
+func (s *AuthService) setupMFA(user *models.User) (*models.MFASetup, error) {
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.mfaRepo.SavePendingMFA(user.ID, secret); err != nil {
+		return nil, err
+	}
+
+	return &models.MFASetup{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(config.AppConfig.MFAIssuer, user.Email, secret),
+	}, nil
+}
+
+// activateMFA enables the pending second factor of a user with its first code, returning the
+// recovery codes of the user
+func (s *AuthService) activateMFA(mfa *models.UserMFA, code string) ([]string, error) {
+	step, ok := utils.ValidateTOTP(mfa.Secret, code, time.Now(), mfa.LastUsedStep)
+	if !ok {
+		return nil, errors.New("invalid mfa code")
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.mfaRepo.EnableMFA(mfa.UserID, step, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// EnrollMFAChallenge sets up a second factor during the login of a user whose role requires one
+// and who has none yet. The challenge is then answered with a code of the new factor.
+func (s *AuthService) EnrollMFAChallenge(mfaToken string) (*models.MFASetup, error) {
+	_, user, err := s.findChallenge(mfaToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.setupMFA(user)
+}
+
+// VerifyMFAChallenge answers a login challenge with a TOTP or recovery code and opens the session.
+// When the challenge enrolls the second factor, the factor is enabled and its recovery codes are returned.
+func (s *AuthService) VerifyMFAChallenge(mfaToken, code string, client Client) (*models.User, *models.TokenPair, []string, error) {
+	challenge, user, err := s.findChallenge(mfaToken)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	mfa, err := s.getMFA(user.ID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if mfa == nil {
+		return nil, nil, nil, errors.New("mfa enrollment required")
+	}
+
+	var recoveryCodes []string
+	if mfa.EnabledAt == nil {
+		recoveryCodes, err = s.activateMFA(mfa, code)
+	} else {
+		err = s.checkMFACode(mfa, code)
+	}
+	if err != nil {
+		if err.Error() == "invalid mfa code" {
+			if err := s.mfaRepo.FailChallenge(challenge.ID); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+		return nil, nil, nil, err
+	}
+
+	if err := s.mfaRepo.UseChallenge(challenge.ID); err != nil {
+		return nil, nil, nil, err
+	}
+
+	tokens, err := s.startSession(user, client)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return user, tokens, recoveryCodes, nil
+}
+
+// GetMFAStatus tells whether a user has a second factor
+func (s *AuthService) GetMFAStatus(userID uint, role models.Role) (*models.MFAStatus, error) {
+	status := &models.MFAStatus{Required: config.AppConfig.MFARequired(string(role))}
+
+	mfa, err := s.getMFA(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if mfa == nil || mfa.EnabledAt == nil {
+		return status, nil
+	}
+
+	status.Enabled = true
+	status.EnabledAt = mfa.EnabledAt
+	status.RecoveryCodes, err = s.mfaRepo.CountRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// SetupMFA gives the user a new pending second factor, enabled by ActivateMFA
+func (s *AuthService) SetupMFA(userID uint) (*models.MFASetup, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.setupMFA(user)
+}
+
+// ActivateMFA enables the pending second factor of the user with its first code, returning the
+// recovery codes of the user
+func (s *AuthService) ActivateMFA(userID uint, code string) ([]string, error) {
+	mfa, err := s.getMFA(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if mfa == nil {
+		return nil, errors.New("mfa not set up")
+	}
+
+	if mfa.EnabledAt != nil {
+		return nil, errors.New("mfa already enabled")
+	}
+
+	return s.activateMFA(mfa, code)
+}
+
+// getEnabledMFA gets the enabled second factor of a user
+func (s *AuthService) getEnabledMFA(userID uint) (*models.UserMFA, error) {
+	mfa, err := s.getMFA(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if mfa == nil || mfa.EnabledAt == nil {
+		return nil, errors.New("mfa not enabled")
+	}
+	return mfa, nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user, a code of the second factor is
+// asked so a stolen session can't get them
+func (s *AuthService) RegenerateRecoveryCodes(userID uint, code string) ([]string, error) {
+	mfa, err := s.getEnabledMFA(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkMFACode(mfa, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.mfaRepo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableMFA removes the second factor of the user, after checking its password and a code. Users
+// whose role requires a second factor can't remove it.
+func (s *AuthService) DisableMFA(userID uint, password, code string) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if config.AppConfig.MFARequired(string(user.Role)) {
+		return errors.New("mfa required for role")
+	}
+
+	if err := user.ComparePassword(password); err != nil {
+		return errors.New("password is incorrect")
+	}
+
+	mfa, err := s.getEnabledMFA(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkMFACode(mfa, code); err != nil {
+		return err
+	}
+
+	return s.mfaRepo.DeleteMFA(userID, false)
+}
+
+// ResetUserMFA removes the second factor of the user with the email, when it lost both its
+// authenticator app and its recovery codes, and revokes its sessions. Only a master can reset the
+// second factor of a master.
+func (s *AuthService) ResetUserMFA(email string, requesterRole models.Role) error {
+	user, err := s.userRepo.FindByEmail(email)
+	if err != nil {
+		return err
+	}
+
+	if user.Role == models.MasterRole && requesterRole != models.MasterRole {
+		return errors.New("only a master can reset the mfa of a master")
+	}
+
+	return s.mfaRepo.DeleteMFA(user.ID, true)
+}
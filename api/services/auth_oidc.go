@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"hcall/api/config"
+	"hcall/api/logger"
+	"hcall/api/models"
+	"hcall/api/oidc"
+)
+
+// StartOIDCLogin starts a single sign-on login, giving the URL of the identity provider the user
+// logs in at and the token the client sends back with the code
+func (s *AuthService) StartOIDCLogin(ctx context.Context) (*models.OIDCAuthorization, error) {
+	if s.oidc == nil {
+		return nil, errors.New("oidc not enabled")
+	}
+
+	secrets := make([]string, 3)
+	for i := range secrets {
+		secret, err := newSecretToken()
+		if err != nil {
+			return nil, err
+		}
+		secrets[i] = secret
+	}
+	state, token, nonce := secrets[0], secrets[1], secrets[2]
+
+	verifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		return nil, err
+	}
+
+	authURL, err := s.oidc.AuthCodeURL(ctx, state, nonce, oidc.CodeChallenge(verifier))
+	if err != nil {
+		logger.Error("Auth Service: Failed to reach the identity provider", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, errors.New("oidc provider unavailable")
+	}
+
+	expiresAt := time.Now().Add(time.Duration(config.AppConfig.OIDCStateMinutes) * time.Minute)
+	err = s.oidcRepo.CreateState(&models.OIDCState{
+		StateHash:    hashToken(state),
+		TokenHash:    hashToken(token),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    expiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.OIDCAuthorization{
+		URL:       authURL,
+		Token:     token,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// OIDCLogin finishes a single sign-on login with the code and the state the user came back with,
+// and opens a session like Login does. The user is found by its identity at the provider, linked
+// by email the first time, or provisioned.
+func (s *AuthService) OIDCLogin(ctx context.Context, code, state, token string, client Client) (*models.User, *models.TokenPair, *models.MFAChallengeResponse, error) {
+	if s.oidc == nil {
+		return nil, nil, nil, errors.New("oidc not enabled")
+	}
+
+	login, err := s.oidcRepo.ConsumeState(hashToken(state), hashToken(token), time.Now())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	claims, err := s.oidc.Exchange(ctx, code, login.CodeVerifier, login.Nonce)
+	if err != nil {
+		logger.Warning("Auth Service: Single sign-on refused", map[string]interface{}{
+			"ip":    client.IP,
+			"error": err.Error(),
+		})
+		return nil, nil, nil, errors.New("oidc login failed")
+	}
+
+	user, err := s.oidcUser(claims)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// A second factor enforced by the identity provider isn't asked again
+	if !config.AppConfig.OIDCTrustMFA {
+		challenge, err := s.startChallenge(user)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		if challenge != nil {
+			return user, nil, challenge, nil
+		}
+	}
+
+	tokens, err := s.startSession(user, client)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return user, tokens, nil, nil
+}
+
+// oidcUser gets the user of verified claims, linking or provisioning it on its first single sign-on
+// login. When groups are mapped to roles, the role of the user follows its groups.
+func (s *AuthService) oidcUser(claims *oidc.Claims) (*models.User, error) {
+	var role models.Role
+	if config.AppConfig.OIDCGroupRoles != "" {
+		role = models.Role(config.AppConfig.OIDCRole(claims.Groups))
+		if role == "" {
+			return nil, errors.New("no role for oidc groups")
+		}
+	}
+
+	now := time.Now()
+	var user *models.User
+	identity, err := s.oidcRepo.FindIdentity(claims.Issuer, claims.Subject)
+	switch {
+	case err == nil:
+		// Trashed users can't log in
+		user, err = s.userRepo.FindByID(identity.UserID)
+		if err != nil {
+			if err.Error() == "user not found" {
+				return nil, errors.New("oidc account disabled")
+			}
+			return nil, err
+		}
+	case err.Error() == "identity not found":
+		user, identity, err = s.linkOIDCUser(claims, role, now)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	if user.ServiceAccount || user.Role == models.MasterRole {
+		return nil, errors.New("oidc login not allowed")
+	}
+
+	if err := s.oidcRepo.RecordLogin(identity, role, now); err != nil {
+		return nil, err
+	}
+
+	if role != "" {
+		user.Role = role
+	}
+	return user, nil
+}
+
+// linkOIDCUser links the user with the email of the claims to its identity, or provisions a new
+// user when there is none. Anyone can claim an email at some providers, so an existing user is only
+// linked when the provider says the email is verified, otherwise its account would be taken over.
+func (s *AuthService) linkOIDCUser(claims *oidc.Claims, role models.Role, now time.Time) (*models.User, *models.UserIdentity, error) {
+	if claims.Email == "" {
+		return nil, nil, errors.New("oidc email missing")
+	}
+
+	identity := &models.UserIdentity{
+		Issuer:      claims.Issuer,
+		Subject:     claims.Subject,
+		LastLoginAt: now,
+	}
+
+	user, err := s.userRepo.FindByEmail(claims.Email)
+	if err == nil {
+		if err := checkOIDCLink(claims, user); err != nil {
+			return nil, nil, err
+		}
+
+		identity.UserID = user.ID
+		if err := s.oidcRepo.LinkIdentity(identity); err != nil {
+			return nil, nil, err
+		}
+		return user, identity, nil
+	}
+
+	if err.Error() != "user not found" {
+		return nil, nil, err
+	}
+
+	if claims.EmailVerified != nil && !*claims.EmailVerified {
+		return nil, nil, errors.New("oidc email not verified")
+	}
+
+	// Trashed users keep their email until they are purged
+	inUse, err := s.userRepo.EmailInUse(claims.Email)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if inUse {
+		return nil, nil, errors.New("oidc account disabled")
+	}
+
+	if !config.AppConfig.OIDCAutoProvision {
+		return nil, nil, errors.New("oidc provisioning disabled")
+	}
+
+	if role == "" {
+		role = models.Role(config.AppConfig.OIDCRole(claims.Groups))
+		if role == "" {
+			return nil, nil, errors.New("no role for oidc groups")
+		}
+	}
+
+	// The user logs in with single sign-on, no one knows its password
+	password, err := newSecretToken()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user = &models.User{
+		Username: oidcUsername(claims),
+		Email:    claims.Email,
+		Password: password,
+		Role:     role,
+	}
+	if claims.EmailVerified != nil && *claims.EmailVerified {
+		user.EmailVerifiedAt = &now
+	}
+	if err := s.oidcRepo.ProvisionUser(user, identity); err != nil {
+		return nil, nil, err
+	}
+
+	logger.Info("Auth Service: User provisioned by single sign-on", map[string]interface{}{
+		"email": user.Email,
+		"role":  user.Role,
+	})
+	return user, identity, nil
+}
+
+// checkOIDCLink tells why the existing user with the email of the claims can't be linked to their
+// identity, nil when it can
+func checkOIDCLink(claims *oidc.Claims, user *models.User) error {
+	if user.ServiceAccount || user.Role == models.MasterRole {
+		return errors.New("oidc login not allowed")
+	}
+
+	if claims.EmailVerified == nil || !*claims.EmailVerified {
+		return errors.New("oidc email not verified")
+	}
+	return nil
+}
+
+// oidcUsername picks the name of a provisioned user from its claims
+func oidcUsername(claims *oidc.Claims) string {
+	for _, name := range []string{claims.Name, claims.PreferredUsername} {
+		if name = strings.TrimSpace(name); name != "" {
+			return name
+		}
+	}
+	local, _, _ := strings.Cut(claims.Email, "@")
+	return local
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"testing"
+
+	"hcall/api/models"
+	"hcall/api/oidc"
+)
+
+func TestCheckOIDCLink(t *testing.T) {
+	verified, unverified := true, false
+
+	tests := []struct {
+		name     string
+		verified *bool
+		user     models.User
+		err      string
+	}{
+		{name: "verified email", verified: &verified, user: models.User{Role: models.UserRole}},
+		{name: "verified email of an admin", verified: &verified, user: models.User{Role: models.AdminRole}},
+		{name: "unverified email", verified: &unverified, user: models.User{Role: models.UserRole}, err: "oidc email not verified"},
+		// An admin account must not be taken over by claiming its email at the provider
+		{name: "unverified email of an admin", verified: &unverified, user: models.User{Role: models.AdminRole}, err: "oidc email not verified"},
+		{name: "email verification unknown", verified: nil, user: models.User{Role: models.AdminRole}, err: "oidc email not verified"},
+		{name: "master", verified: &verified, user: models.User{Role: models.MasterRole}, err: "oidc login not allowed"},
+		{name: "service account", verified: &verified, user: models.User{Role: models.UserRole, ServiceAccount: true}, err: "oidc login not allowed"},
+	}
+
+	for _, test := range tests {
+		claims := &oidc.Claims{Subject: "user-42", Email: "janesmith@example.com", EmailVerified: test.verified}
+		err := checkOIDCLink(claims, &test.user)
+
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: link refused with %v", test.name, err)
+		case test.err != "" && (err == nil || err.Error() != test.err):
+			t.Errorf("%s: checkOIDCLink returned %v, want %s", test.name, err, test.err)
+		}
+	}
+}
+
+func TestOIDCUsername(t *testing.T) {
+	tests := []struct {
+		claims oidc.Claims
+		name   string
+	}{
+		{oidc.Claims{Name: "Jane Smith", PreferredUsername: "jsmith", Email: "janesmith@example.com"}, "Jane Smith"},
+		{oidc.Claims{Name: "  ", PreferredUsername: "jsmith", Email: "janesmith@example.com"}, "jsmith"},
+		{oidc.Claims{Email: "janesmith@example.com"}, "janesmith"},
+	}
+
+	for _, test := range tests {
+		if name := oidcUsername(&test.claims); name != test.name {
+			t.Errorf("oidcUsername(%+v) is %q, want %q", test.claims, name, test.name)
+		}
+	}
+}
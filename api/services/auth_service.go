@@ -1,35 +1,129 @@
 package services
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"time"
 
+	"hcall/api/config"
+	"hcall/api/logger"
+	"hcall/api/mailer"
 	"hcall/api/models"
+	"hcall/api/oidc"
 	"hcall/api/repository"
 	"hcall/api/utils"
+
+	"github.com/google/uuid"
 )
 
 type AuthService struct {
-	userRepo *repository.UserRepository
+	userRepo    *repository.UserRepository
+	sessionRepo *repository.SessionRepository
+	tokenRepo   *repository.EmailTokenRepository
+	mfaRepo     *repository.MFARepository
+	oidcRepo    *repository.OIDCRepository
+	mailer      mailer.Mailer
+	oidc        *oidc.Provider // nil when single sign-on is disabled
 }
 
 func NewAuthService() *AuthService {
 	return &AuthService{
-		userRepo: repository.NewUserRepository(),
+		userRepo:    repository.NewUserRepository(),
+		sessionRepo: repository.NewSessionRepository(),
+		tokenRepo:   repository.NewEmailTokenRepository(),
+		mfaRepo:     repository.NewMFARepository(),
+		oidcRepo:    repository.NewOIDCRepository(),
+		mailer:      mailer.Default,
+		oidc:        oidc.Default,
+	}
+}
+
+// Client is the device a session is opened from
+type Client struct {
+	UserAgent string
+	IP        string
+}
+
+// hashToken hashes a refresh or email token, tokens are random enough for a plain hash
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newSecretToken generates a random refresh or email token
+func newSecretToken() (string, error) {
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
 	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// startSession opens a session for the user and gives its first tokens
+func (s *AuthService) startSession(user *models.User, client Client) (*models.TokenPair, error) {
+	refreshToken, err := newSecretToken()
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID := uuid.NewString()
+	accessToken, jti, expiresAt, err := utils.GenerateToken(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	userAgent := client.UserAgent
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	session := &models.Session{
+		ID:              sessionID,
+		UserID:          user.ID,
+		RefreshHash:     hashToken(refreshToken),
+		AccessJTI:       jti,
+		AccessExpiresAt: expiresAt,
+		UserAgent:       userAgent,
+		IP:              client.IP,
+		ExpiresAt:       now.AddDate(0, 0, config.AppConfig.RefreshTokenDays),
+		CreatedAt:       now,
+		LastUsedAt:      now,
+	}
+	if err := s.sessionRepo.CreateSession(session); err != nil {
+		return nil, err
+	}
+
+	return &models.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    expiresAt,
+	}, nil
 }
 
 // Register registers a new user
-func (s *AuthService) Register(username, email, password string) (*models.User, string, error) {
+func (s *AuthService) Register(username, email, password string, client Client) (*models.User, *models.TokenPair, error) {
 	// Check if user already exists
-	existingUser, err := s.userRepo.FindByEmail(email)
-	if err == nil && existingUser != nil {
-		return nil, "", errors.New("email already exists")
+	// Trashed users keep their email until they are purged
+	inUse, err := s.userRepo.EmailInUse(email)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if inUse {
+		return nil, nil, errors.New("email already exists")
+	}
+
+	// The users of these domains are provisioned by single sign-on
+	if config.AppConfig.PasswordLoginDisabled(email) {
+		return nil, nil, errors.New("password login disabled")
 	}
 
 	err = utils.ValidateCredentials(email, password, username)
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 
 	// Create the new user
@@ -42,69 +136,183 @@ func (s *AuthService) Register(username, email, password string) (*models.User,
 
 	// Save the user to the database
 	if err := s.userRepo.CreateUser(user); err != nil {
-		return nil, "", err
+		return nil, nil, err
+	}
+
+	// The user can ask for another verification email when this one isn't sent
+	if err := s.sendEmailToken(user, models.VerifyEmailPurpose); err != nil {
+		logger.Error("Auth Service: Failed to send the verification email", map[string]interface{}{
+			"email": user.Email,
+			"error": err.Error(),
+		})
+	}
+
+	// No session until the email is verified
+	if config.AppConfig.EmailVerificationRequired {
+		return user, nil, nil
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateToken(user)
+	// Open a session
+	tokens, err := s.startSession(user, client)
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 
-	return user, token, nil
+	return user, tokens, nil
 }
 
-// Login logs in a user and returns a JWT token
-func (s *AuthService) Login(email, password string) (*models.User, string, error) {
+// Login logs in a user and opens a session, returning its access and refresh tokens. Users with a
+// second factor, or whose role requires one, get a challenge to answer instead of the tokens.
+func (s *AuthService) Login(email, password string, client Client) (*models.User, *models.TokenPair, *models.MFAChallengeResponse, error) {
 	// Find user by email
 	user, err := s.userRepo.FindByEmail(email)
 	if err != nil {
-		return nil, "", errors.New("email aren't registered")
+		return nil, nil, nil, errors.New("email aren't registered")
+	}
+
+	// Service accounts only use API keys, their password is never given out
+	if user.ServiceAccount {
+		return nil, nil, nil, errors.New("password is incorrect")
+	}
+
+	// The master keeps its password, to log in while the identity provider is down
+	if user.Role != models.MasterRole && config.AppConfig.PasswordLoginDisabled(user.Email) {
+		return nil, nil, nil, errors.New("password login disabled")
 	}
 
 	// Compare passwords
 	if err := user.ComparePassword(password); err != nil {
-		return nil, "", errors.New("password is incorrect")
+		return nil, nil, nil, errors.New("password is incorrect")
+	}
+
+	if config.AppConfig.EmailVerificationRequired && user.EmailVerifiedAt == nil {
+		return nil, nil, nil, errors.New("email not verified")
+	}
+
+	// The second factor is asked before opening the session
+	challenge, err := s.startChallenge(user)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if challenge != nil {
+		return user, nil, challenge, nil
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateToken(user)
+	// Open a session
+	tokens, err := s.startSession(user, client)
 	if err != nil {
-		return nil, "", err
+		return nil, nil, nil, err
 	}
 
-	return user, token, nil
+	return user, tokens, nil, nil
 }
 
 // CreateMaster creates a master user if one doesn't exist
-func (s *AuthService) CreateMaster(email, password string) (*models.User, string, error) {
+func (s *AuthService) CreateMaster(email, password string, client Client) (*models.User, *models.TokenPair, error) {
 	// Check if a master user already exists
 	_, err := s.userRepo.FindMaster()
 	if err == nil {
-		return nil, "", errors.New("master user already exists")
+		return nil, nil, errors.New("master user already exists")
 	}
 
-	// Create the master user
+	// Create the master user, whoever can create it doesn't have to verify its email
+	now := time.Now()
 	master := &models.User{
-		Username:  "Master",
-		Email:     email,
-		Password:  password,
-		Role:      models.MasterRole,
-		CreatedAt: time.Now(),
+		Username:        "Master",
+		Email:           email,
+		Password:        password,
+		Role:            models.MasterRole,
+		EmailVerifiedAt: &now,
+		CreatedAt:       now,
 	}
 
 	// Save the master user to the database
 	if err := s.userRepo.CreateUser(master); err != nil {
-		return nil, "", err
+		return nil, nil, err
+	}
+
+	// Open a session
+	tokens, err := s.startSession(master, client)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateToken(master)
+	return master, tokens, nil
+}
+
+// Refresh gives new tokens to the session of a refresh token, the refresh token is replaced and
+// can't be used again
+func (s *AuthService) Refresh(refreshToken string) (*models.TokenPair, error) {
+	hash := hashToken(refreshToken)
+	session, err := s.sessionRepo.FindByRefreshHash(hash)
 	if err != nil {
-		return nil, "", err
+		return nil, err
+	}
+
+	// Trashed users can't use their sessions anymore
+	user, err := s.userRepo.FindByID(session.UserID)
+	if err != nil {
+		return nil, errors.New("invalid refresh token")
+	}
+
+	newRefreshToken, err := newSecretToken()
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, jti, expiresAt, err := utils.GenerateToken(user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.sessionRepo.RotateSession(session.ID, hash, hashToken(newRefreshToken), jti, expiresAt); err != nil {
+		return nil, err
+	}
+
+	return &models.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: newRefreshToken,
+		ExpiresAt:    expiresAt,
+	}, nil
+}
+
+// Logout revokes the session of the user, its tokens can't be used anymore
+func (s *AuthService) Logout(sessionID string, userID uint) error {
+	return s.sessionRepo.RevokeSession(sessionID, userID)
+}
+
+// GetSessions lists the active sessions of the user, flagging the current one
+func (s *AuthService) GetSessions(userID uint, currentSessionID string) ([]models.Session, error) {
+	sessions, err := s.sessionRepo.GetActiveSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+	return sessions, nil
+}
+
+// RevokeSessions revokes every session of the user, returning how many were active
+func (s *AuthService) RevokeSessions(userID uint) (int64, error) {
+	return s.sessionRepo.RevokeUserSessions(userID)
+}
+
+// RevokeUserSessions revokes every session of the user with the email, only a master can revoke
+// the sessions of a master
+func (s *AuthService) RevokeUserSessions(email string, requesterRole models.Role) (int64, error) {
+	user, err := s.userRepo.FindByEmail(email)
+	if err != nil {
+		return 0, err
+	}
+
+	if user.Role == models.MasterRole && requesterRole != models.MasterRole {
+		return 0, errors.New("only a master can revoke the sessions of a master")
 	}
 
-	return master, token, nil
+	return s.sessionRepo.RevokeUserSessions(user.ID)
 }
 
 // DeleteMaster deletes the master user
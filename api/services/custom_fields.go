@@ -0,0 +1,208 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"hcall/api/models"
+)
+
+// ErrInvalidFieldSchema and ErrInvalidCustomField wrap the reason a schema or a value was refused
+var (
+	ErrInvalidFieldSchema = errors.New("invalid field schema")
+	ErrInvalidCustomField = errors.New("invalid custom field")
+)
+
+// fieldNamePattern matches the names of the custom fields
+var fieldNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+// validateFieldSchema checks a schema only uses the supported subset of JSON Schema
+func validateFieldSchema(schema *models.FieldSchema) error {
+	if schema.Type == "" {
+		schema.Type = "object"
+	}
+	if schema.Type != "object" {
+		return fmt.Errorf("%w: type must be object", ErrInvalidFieldSchema)
+	}
+
+	for name, field := range schema.Properties {
+		if !fieldNamePattern.MatchString(name) {
+			return fmt.Errorf("%w: invalid field name %q", ErrInvalidFieldSchema, name)
+		}
+
+		if err := validateFieldDefinition(field); err != nil {
+			return fmt.Errorf("%w: field %s: %s", ErrInvalidFieldSchema, name, err)
+		}
+	}
+
+	for _, name := range schema.Required {
+		if _, ok := schema.Properties[name]; !ok {
+			return fmt.Errorf("%w: required field %s is not defined", ErrInvalidFieldSchema, name)
+		}
+	}
+
+	return nil
+}
+
+// validateFieldDefinition checks the keywords of a field are consistent with its type
+func validateFieldDefinition(field models.FieldDefinition) error {
+	switch field.Type {
+	case models.FieldTypeString:
+		if field.Minimum != nil || field.Maximum != nil {
+			return errors.New("minimum and maximum only apply to numbers")
+		}
+		switch field.Format {
+		case "", models.FieldFormatDate, models.FieldFormatUser:
+		default:
+			return fmt.Errorf("unsupported format %q", field.Format)
+		}
+		if field.Pattern != "" {
+			if _, err := regexp.Compile(field.Pattern); err != nil {
+				return errors.New("invalid pattern")
+			}
+		}
+	case models.FieldTypeNumber, models.FieldTypeInteger:
+		if field.Format != "" || len(field.Enum) > 0 || field.Pattern != "" || field.MinLength != nil || field.MaxLength != nil {
+			return errors.New("numbers only accept minimum and maximum")
+		}
+	default:
+		return fmt.Errorf("unsupported type %q", field.Type)
+	}
+
+	if len(field.Enum) > 0 && field.Format != "" {
+		return errors.New("enum can't have a format")
+	}
+
+	return nil
+}
+
+// validateCustomFields checks the custom fields of a new ticket against the schema of its queue.
+// Undefined fields are refused, user references are checked with userExists.
+func validateCustomFields(schema *models.FieldSchema, values map[string]interface{}, userExists func(email string) bool) (models.CustomFields, error) {
+	if schema == nil || len(schema.Properties) == 0 {
+		if len(values) > 0 {
+			return nil, fmt.Errorf("%w: the queue has no custom fields", ErrInvalidCustomField)
+		}
+		return nil, nil
+	}
+
+	// Report the problems in a stable order
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fields := models.CustomFields{}
+	for _, name := range names {
+		field, ok := schema.Properties[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is not defined", ErrInvalidCustomField, name)
+		}
+
+		value, err := validateFieldValue(field, values[name], userExists)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s %s", ErrInvalidCustomField, name, err)
+		}
+
+		// null and blank texts are the same as leaving the field out
+		if value != nil {
+			fields[name] = value
+		}
+	}
+
+	for _, name := range schema.Required {
+		if _, ok := fields[name]; !ok {
+			return nil, fmt.Errorf("%w: %s is required", ErrInvalidCustomField, name)
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return fields, nil
+}
+
+// customFieldFilter checks the names of the custom fields a ticket listing is filtered by
+func customFieldFilter(values map[string]interface{}) (map[string]interface{}, error) {
+	for name := range values {
+		if !fieldNamePattern.MatchString(name) {
+			return nil, errors.New("invalid custom field filter")
+		}
+	}
+	return values, nil
+}
+
+// validateFieldValue checks a value against its field definition and returns it normalized
+func validateFieldValue(field models.FieldDefinition, value interface{}, userExists func(email string) bool) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	switch field.Type {
+	case models.FieldTypeNumber, models.FieldTypeInteger:
+		number, ok := value.(float64)
+		if !ok {
+			return nil, errors.New("must be a number")
+		}
+		if field.Type == models.FieldTypeInteger && number != math.Trunc(number) {
+			return nil, errors.New("must be an integer")
+		}
+		if field.Minimum != nil && number < *field.Minimum {
+			return nil, fmt.Errorf("must be at least %v", *field.Minimum)
+		}
+		if field.Maximum != nil && number > *field.Maximum {
+			return nil, fmt.Errorf("must be at most %v", *field.Maximum)
+		}
+		return number, nil
+	}
+
+	text, ok := value.(string)
+	if !ok {
+		return nil, errors.New("must be a text")
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, nil
+	}
+
+	if len(field.Enum) > 0 {
+		for _, option := range field.Enum {
+			if text == option {
+				return text, nil
+			}
+		}
+		return nil, fmt.Errorf("must be one of %s", strings.Join(field.Enum, ", "))
+	}
+
+	switch field.Format {
+	case models.FieldFormatDate:
+		if _, err := time.Parse("2006-01-02", text); err != nil {
+			return nil, errors.New("must be a YYYY-MM-DD date")
+		}
+		return text, nil
+	case models.FieldFormatUser:
+		if !userExists(text) {
+			return nil, errors.New("must be the email of a registered user")
+		}
+		return text, nil
+	}
+
+	length := len([]rune(text))
+	if field.MinLength != nil && length < *field.MinLength {
+		return nil, fmt.Errorf("must have at least %d characters", *field.MinLength)
+	}
+	if field.MaxLength != nil && length > *field.MaxLength {
+		return nil, fmt.Errorf("must have at most %d characters", *field.MaxLength)
+	}
+	if field.Pattern != "" && !regexp.MustCompile(field.Pattern).MatchString(text) {
+		return nil, errors.New("doesn't match the expected pattern")
+	}
+
+	return text, nil
+}
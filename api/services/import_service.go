@@ -0,0 +1,611 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"hcall/api/models"
+	"hcall/api/repository"
+)
+
+// importChunkSize is the number of rows imported in each transaction
+const importChunkSize = 100
+
+// ImportOptions tune a ticket import
+type ImportOptions struct {
+	Format       string // csv or ndjson
+	Placeholders bool   // create users for the unknown authors instead of rejecting their rows
+	DryRun       bool   // only check the rows, nothing is written
+}
+
+// ImportFormat guesses the format of an import file from its name, it is empty when the name doesn't tell
+func ImportFormat(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return "csv"
+	case ".ndjson", ".jsonl":
+		return "ndjson"
+	default:
+		return ""
+	}
+}
+
+// importMessage is a message of the conversation of an imported ticket
+type importMessage struct {
+	AuthorEmail string `json:"author_email"`
+	Message     string `json:"message"`
+	Visibility  string `json:"visibility"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// importRow is a ticket read from an import file
+type importRow struct {
+	AuthorEmail string          `json:"author_email"`
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	Status      string          `json:"status"`
+	Priority    string          `json:"priority"`
+	CreatedAt   string          `json:"created_at"`
+	ResolvedAt  string          `json:"resolved_at"`
+	History     []importMessage `json:"history"`
+
+	line int
+	err  error // why the row couldn't be read
+}
+
+// importColumns are the columns of the CSV import files, the history is a JSON array of messages
+var importColumns = map[string]func(row *importRow, value string) error{
+	"author_email": func(row *importRow, value string) error { row.AuthorEmail = value; return nil },
+	"title":        func(row *importRow, value string) error { row.Title = value; return nil },
+	"description":  func(row *importRow, value string) error { row.Description = value; return nil },
+	"status":       func(row *importRow, value string) error { row.Status = value; return nil },
+	"priority":     func(row *importRow, value string) error { row.Priority = value; return nil },
+	"created_at":   func(row *importRow, value string) error { row.CreatedAt = value; return nil },
+	"resolved_at":  func(row *importRow, value string) error { row.ResolvedAt = value; return nil },
+	"history": func(row *importRow, value string) error {
+		if strings.TrimSpace(value) == "" {
+			return nil
+		}
+		if err := json.Unmarshal([]byte(value), &row.History); err != nil {
+			return errors.New("history must be a JSON array of messages")
+		}
+		return nil
+	},
+}
+
+// importReader reads the rows of an import file one at a time, it returns io.EOF after the last one.
+// Rows that can't be read carry their error, the error returned stops the import.
+type importReader interface {
+	next() (*importRow, error)
+}
+
+// csvImportReader reads an import file made of a CSV header and a row per ticket
+type csvImportReader struct {
+	reader  *csv.Reader
+	columns []string
+}
+
+func newCSVImportReader(input io.Reader) (*csvImportReader, error) {
+	reader := csv.NewReader(input)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("invalid import header")
+	}
+
+	seen := map[string]bool{}
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		if _, ok := importColumns[column]; !ok || seen[column] {
+			return nil, errors.New("invalid import header")
+		}
+		seen[column] = true
+		header[i] = column
+	}
+
+	if !seen["author_email"] || !seen["title"] || !seen["description"] {
+		return nil, errors.New("invalid import header")
+	}
+
+	return &csvImportReader{reader: reader, columns: header}, nil
+}
+
+func (r *csvImportReader) next() (*importRow, error) {
+	record, err := r.reader.Read()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+
+	if err != nil {
+		// The other rows can still be read after a row with a wrong number of fields, not after broken quotes
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) && errors.Is(err, csv.ErrFieldCount) {
+			return &importRow{line: parseErr.StartLine, err: errors.New("wrong number of fields")}, nil
+		}
+		return nil, errors.New("invalid import file")
+	}
+
+	row := &importRow{}
+	row.line, _ = r.reader.FieldPos(0)
+
+	for i, value := range record {
+		if err := importColumns[r.columns[i]](row, value); err != nil {
+			row.err = err
+			break
+		}
+	}
+	return row, nil
+}
+
+// ndjsonImportReader reads an import file made of a JSON object per line
+type ndjsonImportReader struct {
+	reader *bufio.Reader
+	line   int
+}
+
+func (r *ndjsonImportReader) next() (*importRow, error) {
+	for {
+		data, err := r.reader.ReadBytes('\n')
+		if err != nil && !(err == io.EOF && len(data) > 0) {
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			return nil, err
+		}
+		r.line++
+
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			continue
+		}
+
+		row := &importRow{}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(row); err != nil {
+			row = &importRow{err: fmt.Errorf("invalid JSON: %v", err)}
+		}
+		row.line = r.line
+		return row, nil
+	}
+}
+
+// ImportService imports tickets exported from another helpdesk
+type ImportService struct {
+	ticketRepo *repository.TicketRepository
+	userRepo   *repository.UserRepository
+	queueRepo  *repository.QueueRepository
+}
+
+func NewImportService() *ImportService {
+	return &ImportService{
+		ticketRepo: repository.NewTicketRepository(),
+		userRepo:   repository.NewUserRepository(),
+		queueRepo:  repository.NewQueueRepository(),
+	}
+}
+
+// ticketImport is the state of an import in progress
+type ticketImport struct {
+	*ImportService
+	options ImportOptions
+	report  *models.ImportReport
+	queue   *models.Queue
+	users   map[string]*models.User // authors already resolved, by email
+	// placeholders are the users to create with the tickets of the current chunk
+	placeholders []*models.User
+}
+
+// ImportTickets imports the tickets of a CSV or JSON lines file, a chunk of rows per transaction. The
+// rows that can't be imported are listed in the report with their error. When the file itself is
+// broken the rows before the failure stay imported and the report of them is returned with the error.
+func (s *ImportService) ImportTickets(input io.Reader, options ImportOptions) (*models.ImportReport, error) {
+	var reader importReader
+	switch options.Format {
+	case "csv":
+		csvReader, err := newCSVImportReader(input)
+		if err != nil {
+			return nil, err
+		}
+		reader = csvReader
+	case "ndjson":
+		reader = &ndjsonImportReader{reader: bufio.NewReader(input)}
+	default:
+		return nil, errors.New("invalid import format")
+	}
+
+	// Imported tickets land where the tickets created without a queue do
+	queue, err := s.queueRepo.GetDefaultQueue()
+	if err != nil {
+		return nil, err
+	}
+
+	run := &ticketImport{
+		ImportService: s,
+		options:       options,
+		queue:         queue,
+		users:         map[string]*models.User{},
+		report: &models.ImportReport{
+			DryRun:       options.DryRun,
+			Placeholders: []string{},
+			Errors:       []models.ImportError{},
+		},
+	}
+
+	chunk := make([]*importRow, 0, importChunkSize)
+	for {
+		row, err := reader.next()
+		if err != nil && err != io.EOF {
+			// The rows read before the failure are still imported
+			if len(chunk) > 0 {
+				if err := run.importChunk(chunk); err != nil {
+					return run.report, err
+				}
+			}
+			return run.report, err
+		}
+
+		if row != nil {
+			chunk = append(chunk, row)
+		}
+
+		if len(chunk) == importChunkSize || (err == io.EOF && len(chunk) > 0) {
+			if err := run.importChunk(chunk); err != nil {
+				return run.report, err
+			}
+			chunk = chunk[:0]
+		}
+
+		if err == io.EOF {
+			return run.report, nil
+		}
+	}
+}
+
+// importChunk imports a chunk of rows in one transaction
+func (r *ticketImport) importChunk(rows []*importRow) error {
+	r.report.Rows += len(rows)
+
+	r.placeholders = nil
+	if err := r.resolveUsers(rows); err != nil {
+		return err
+	}
+
+	tickets := make([]repository.ImportedTicket, 0, len(rows))
+	lines := make([]int, 0, len(rows))
+	for _, row := range rows {
+		if row.err != nil {
+			r.fail(row.line, row.err)
+			continue
+		}
+
+		ticket, closing, err := r.newTicket(row)
+		if err != nil {
+			r.fail(row.line, err)
+			continue
+		}
+
+		tickets = append(tickets, repository.ImportedTicket{Ticket: ticket, Closing: closing})
+		lines = append(lines, row.line)
+	}
+
+	if r.options.DryRun {
+		r.reportPlaceholders()
+		r.report.Imported += len(tickets)
+		return nil
+	}
+
+	// The placeholders are created with the tickets, and rolled back with them
+	errs, err := r.ticketRepo.ImportTickets(r.placeholders, tickets)
+	if err != nil {
+		return err
+	}
+	r.reportPlaceholders()
+
+	for i, err := range errs {
+		if err != nil {
+			r.fail(lines[i], err)
+			continue
+		}
+		r.report.Imported++
+	}
+	return nil
+}
+
+// reportPlaceholders adds the placeholders of the chunk to the report
+func (r *ticketImport) reportPlaceholders() {
+	for _, user := range r.placeholders {
+		r.report.Placeholders = append(r.report.Placeholders, user.Email)
+	}
+	r.placeholders = nil
+}
+
+// fail reports a row that wasn't imported
+func (r *ticketImport) fail(line int, err error) {
+	r.report.Failed++
+	r.report.Errors = append(r.report.Errors, models.ImportError{Line: line, Error: err.Error()})
+}
+
+// resolveUsers finds the authors of the tickets and messages of the rows that aren't known yet, and
+// creates placeholders for the unregistered ones when asked to
+func (r *ticketImport) resolveUsers(rows []*importRow) error {
+	var emails []string
+	wanted := map[string]bool{}
+	want := func(email string) {
+		if _, known := r.users[email]; !known && email != "" && !wanted[email] {
+			wanted[email] = true
+			emails = append(emails, email)
+		}
+	}
+	for _, row := range rows {
+		if row.err != nil {
+			continue
+		}
+		want(strings.TrimSpace(row.AuthorEmail))
+		for _, message := range row.History {
+			want(strings.TrimSpace(message.AuthorEmail))
+		}
+	}
+
+	if len(emails) == 0 {
+		return nil
+	}
+
+	users, err := r.userRepo.FindByEmails(emails)
+	if err != nil {
+		return err
+	}
+	for i := range users {
+		r.users[users[i].Email] = &users[i]
+	}
+
+	for _, email := range emails {
+		if _, found := r.users[email]; found {
+			continue
+		}
+
+		// Unknown authors stay unresolved, the rows using them fail
+		r.users[email] = nil
+		if !r.options.Placeholders || !strings.Contains(email, "@") || !strings.Contains(email, ".") {
+			continue
+		}
+
+		// Trashed users keep their email until they are purged
+		inUse, err := r.userRepo.EmailInUse(email)
+		if err != nil {
+			return err
+		}
+		if inUse {
+			continue
+		}
+
+		placeholder, err := r.newPlaceholder(email)
+		if err != nil {
+			return err
+		}
+		r.users[email] = placeholder
+		r.placeholders = append(r.placeholders, placeholder)
+	}
+	return nil
+}
+
+// newPlaceholder makes a user for an author of the old helpdesk who isn't registered, it is created
+// with the tickets of the chunk. Its password is random, the user gets access by having it reset.
+func (r *ticketImport) newPlaceholder(email string) (*models.User, error) {
+	user := &models.User{
+		Username: strings.SplitN(email, "@", 2)[0],
+		Email:    email,
+		Role:     models.UserRole,
+	}
+
+	if r.options.DryRun {
+		return user, nil
+	}
+
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	user.Password = hex.EncodeToString(secret)
+	return user, nil
+}
+
+// author gets the resolved user of an email of the import file
+func (r *ticketImport) author(email string) (*models.User, error) {
+	email = strings.TrimSpace(email)
+	if user := r.users[email]; user != nil {
+		return user, nil
+	}
+
+	inUse, err := r.userRepo.EmailInUse(email)
+	if err == nil && inUse {
+		return nil, fmt.Errorf("user %s is in the trash", email)
+	}
+	return nil, fmt.Errorf("user %s not found", email)
+}
+
+// newTicket checks a row and converts it to the ticket to create. A closed ticket comes with the
+// status change that closed it, made by the last agent who wrote in its conversation or by its author.
+func (r *ticketImport) newTicket(row *importRow) (*models.Ticket, *models.TicketStatusChange, error) {
+	if strings.TrimSpace(row.AuthorEmail) == "" {
+		return nil, nil, errors.New("author_email is required")
+	}
+
+	title := strings.TrimSpace(row.Title)
+	if title == "" {
+		return nil, nil, errors.New("title is required")
+	}
+	if len(title) > 255 {
+		return nil, nil, errors.New("title is longer than 255 characters")
+	}
+
+	if strings.TrimSpace(row.Description) == "" {
+		return nil, nil, errors.New("description is required")
+	}
+
+	author, err := r.author(row.AuthorEmail)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	status := models.PendingStatus
+	if row.Status != "" {
+		statuses, err := parseEnumList("invalid status", []string{row.Status}, validStatuses)
+		if err != nil || len(statuses) != 1 {
+			return nil, nil, fmt.Errorf("invalid status %q", row.Status)
+		}
+		status = statuses[0]
+	}
+
+	priority := models.NormalPriority
+	if row.Priority != "" {
+		priorities, err := parseEnumList("invalid priority", []string{row.Priority}, validPriorities)
+		if err != nil || len(priorities) != 1 {
+			return nil, nil, fmt.Errorf("invalid priority %q", row.Priority)
+		}
+		priority = priorities[0]
+	}
+
+	createdAt := time.Now()
+	if row.CreatedAt != "" {
+		if createdAt, err = parseImportTime(row.CreatedAt); err != nil {
+			return nil, nil, fmt.Errorf("invalid created_at %q", row.CreatedAt)
+		}
+	}
+
+	ticket := &models.Ticket{
+		Name:        title,
+		Explanation: row.Description,
+		Status:      status,
+		Priority:    priority,
+		AuthorID:    author.ID,
+		AuthorEmail: author.Email,
+		CreatedAt:   createdAt,
+		UpdatedAt:   createdAt,
+	}
+
+	if r.queue != nil {
+		ticket.QueueID = &r.queue.ID
+	}
+
+	closer := author
+	for i, message := range row.History {
+		entry, err := r.newMessage(message, createdAt)
+		if err != nil {
+			return nil, nil, fmt.Errorf("history %d: %w", i+1, err)
+		}
+
+		if entry.AuthorRole != "" && entry.AuthorRole != models.UserRole {
+			closer = r.users[strings.TrimSpace(message.AuthorEmail)]
+		}
+
+		ticket.History = append(ticket.History, *entry)
+		if entry.CreatedAt.After(ticket.UpdatedAt) {
+			ticket.UpdatedAt = entry.CreatedAt
+		}
+
+		// The first public answer of an agent is the first response, as for the tickets created here
+		if ticket.FirstResponseAt == nil && entry.Visibility == models.PublicMessage && entry.AuthorRole != "" && entry.AuthorRole != models.UserRole {
+			respondedAt := entry.CreatedAt
+			ticket.FirstResponseAt = &respondedAt
+		}
+	}
+
+	if status.IsClosed() {
+		resolvedAt := ticket.UpdatedAt
+		if row.ResolvedAt != "" {
+			if resolvedAt, err = parseImportTime(row.ResolvedAt); err != nil {
+				return nil, nil, fmt.Errorf("invalid resolved_at %q", row.ResolvedAt)
+			}
+			if resolvedAt.Before(createdAt) {
+				return nil, nil, errors.New("resolved_at is before created_at")
+			}
+			if resolvedAt.After(ticket.UpdatedAt) {
+				ticket.UpdatedAt = resolvedAt
+			}
+		}
+		ticket.ResolvedAt = &resolvedAt
+	}
+
+	if ticket.ResolvedAt == nil {
+		return ticket, nil, nil
+	}
+
+	// The closing counts in the metrics like the tickets closed here. A placeholder gets its ID when
+	// it is created, its email tells which one it is.
+	closing := &models.TicketStatusChange{
+		FromStatus: models.PendingStatus,
+		ToStatus:   status,
+		Reason:     "Closed before the import",
+		ActorID:    closer.ID,
+		ActorEmail: closer.Email,
+		CreatedAt:  *ticket.ResolvedAt,
+	}
+	return ticket, closing, nil
+}
+
+// newMessage checks a message of the history of a row and converts it to a conversation message.
+// Messages without an author are system entries.
+func (r *ticketImport) newMessage(message importMessage, ticketCreatedAt time.Time) (*models.TicketHistory, error) {
+	if strings.TrimSpace(message.Message) == "" {
+		return nil, errors.New("message is required")
+	}
+
+	entry := &models.TicketHistory{
+		Message:    message.Message,
+		Visibility: models.PublicMessage,
+		CreatedAt:  ticketCreatedAt,
+	}
+
+	switch models.MessageVisibility(message.Visibility) {
+	case "", models.PublicMessage:
+	case models.InternalMessage:
+		entry.Visibility = models.InternalMessage
+	default:
+		return nil, fmt.Errorf("invalid visibility %q", message.Visibility)
+	}
+
+	if message.CreatedAt != "" {
+		createdAt, err := parseImportTime(message.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid created_at %q", message.CreatedAt)
+		}
+		if createdAt.Before(ticketCreatedAt) {
+			return nil, errors.New("message is older than the ticket")
+		}
+		entry.CreatedAt = createdAt
+	}
+	entry.UpdatedAt = entry.CreatedAt
+
+	if strings.TrimSpace(message.AuthorEmail) != "" {
+		author, err := r.author(message.AuthorEmail)
+		if err != nil {
+			return nil, err
+		}
+		entry.AuthorID = &author.ID
+		entry.AuthorName = author.Username
+		entry.AuthorRole = author.Role
+	}
+
+	return entry, nil
+}
+
+// parseImportTime parses an RFC 3339 timestamp, or a UTC "YYYY-MM-DD HH:MM:SS" one, or a day
+func parseImportTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("invalid date format")
+}
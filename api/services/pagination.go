@@ -0,0 +1,55 @@
+package services
+
+import (
+	"hcall/api/config"
+	"hcall/api/models"
+	"hcall/api/utils"
+)
+
+// newPage builds the page of a listing from its query parameters, applying the configured limits.
+// Listings are sorted by creation date, newest first, unless asked otherwise.
+func newPage(query utils.PageQuery) *models.Page {
+	return newSortedPage(query, "created")
+}
+
+// newSortedPage builds the page of a listing whose default sort isn't the creation date
+func newSortedPage(query utils.PageQuery, defaultSort string) *models.Page {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = config.AppConfig.PageDefaultLimit
+	}
+	if limit > config.AppConfig.PageMaxLimit {
+		limit = config.AppConfig.PageMaxLimit
+	}
+
+	sort := query.Sort
+	if sort == "" {
+		sort = defaultSort
+	}
+
+	order := query.Order
+	if order == "" {
+		order = models.SortDescending
+		// Closest deadlines first
+		if sort == "due_date" {
+			order = models.SortAscending
+		}
+	}
+
+	return &models.Page{
+		Limit:  limit,
+		Offset: query.Offset,
+		Cursor: query.Cursor,
+		Sort:   sort,
+		Order:  order,
+	}
+}
+
+// IsPaginationError tells whether a listing failed because of its pagination parameters
+func IsPaginationError(err error) bool {
+	switch err.Error() {
+	case "invalid sort field", "invalid sort order", "invalid cursor":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"hcall/api/models"
+	"hcall/api/repository"
+)
+
+type QueueService struct {
+	queueRepo *repository.QueueRepository
+	userRepo  *repository.UserRepository
+}
+
+func NewQueueService() *QueueService {
+	return &QueueService{
+		queueRepo: repository.NewQueueRepository(),
+		userRepo:  repository.NewUserRepository(),
+	}
+}
+
+// CreateQueue creates a new queue, a default queue receives the tickets created without a queue
+func (s *QueueService) CreateQueue(name, description string, isDefault bool) (*models.Queue, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("queue name is required")
+	}
+
+	queues, err := s.queueRepo.GetQueues()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, queue := range queues {
+		if strings.EqualFold(queue.Name, name) {
+			return nil, errors.New("queue already exists")
+		}
+	}
+
+	queue := &models.Queue{
+		Name:        name,
+		Description: strings.TrimSpace(description),
+		IsDefault:   isDefault,
+	}
+
+	if err := s.queueRepo.CreateQueue(queue); err != nil {
+		return nil, err
+	}
+
+	return queue, nil
+}
+
+// GetQueues gets all the queues, requesters pick one of them when creating a ticket
+func (s *QueueService) GetQueues() ([]models.Queue, error) {
+	return s.queueRepo.GetQueues()
+}
+
+// UpdateFieldSchema replaces the custom field schema of a queue, only masters and the queue members can
+// change it. It applies to the tickets created afterwards, an empty schema removes the custom fields.
+func (s *QueueService) UpdateFieldSchema(queueID uint, schema *models.FieldSchema, userID uint, userRole models.Role) error {
+	if _, err := s.queueRepo.GetQueue(queueID); err != nil {
+		return err
+	}
+
+	if userRole != models.MasterRole {
+		member, err := s.queueRepo.IsMember(queueID, userID)
+		if err != nil {
+			return err
+		}
+
+		if !member {
+			return errors.New("you don't have access to this queue")
+		}
+	}
+
+	if schema != nil && len(schema.Properties) == 0 {
+		schema = nil
+	}
+
+	if schema != nil {
+		if err := validateFieldSchema(schema); err != nil {
+			return err
+		}
+	}
+
+	return s.queueRepo.UpdateFieldSchema(queueID, schema)
+}
+
+// DeleteQueue deletes a queue without tickets
+func (s *QueueService) DeleteQueue(queueID uint) error {
+	return s.queueRepo.DeleteQueue(queueID)
+}
+
+// AddMember gives an admin access to the tickets of a queue
+func (s *QueueService) AddMember(queueID uint, email string) error {
+	if _, err := s.queueRepo.GetQueue(queueID); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.FindByEmail(email)
+	if err != nil {
+		return err
+	}
+
+	if user.Role != models.AdminRole {
+		return errors.New("only admin users can be queue members")
+	}
+
+	return s.queueRepo.AddMember(queueID, user.ID)
+}
+
+// RemoveMember removes the access of an admin to the tickets of a queue
+func (s *QueueService) RemoveMember(queueID uint, email string) error {
+	user, err := s.userRepo.FindByEmail(email)
+	if err != nil {
+		return err
+	}
+
+	return s.queueRepo.RemoveMember(queueID, user.ID)
+}
+
+// GetMembers gets the members of a queue
+func (s *QueueService) GetMembers(queueID uint) ([]models.QueueMemberResponse, error) {
+	if _, err := s.queueRepo.GetQueue(queueID); err != nil {
+		return nil, err
+	}
+
+	members, err := s.queueRepo.GetMembers(queueID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]models.QueueMemberResponse, len(members))
+	for i := range members {
+		responses[i] = members[i].ToResponse()
+	}
+	return responses, nil
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"hcall/api/models"
+	"hcall/api/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// apiKeyPrefix starts every API key, so leaked keys are easy to recognize and to tell from JWTs
+	apiKeyPrefix = "hc_"
+	// apiKeyShownLength is the length of the start of a key kept to recognize it
+	apiKeyShownLength = len(apiKeyPrefix) + 8
+	// serviceAccountDomain is the domain of the emails given to the service accounts, it can't receive emails
+	serviceAccountDomain = "service-accounts.invalid"
+)
+
+type ServiceAccountService struct {
+	userRepo *repository.UserRepository
+	keyRepo  *repository.APIKeyRepository
+}
+
+func NewServiceAccountService() *ServiceAccountService {
+	return &ServiceAccountService{
+		userRepo: repository.NewUserRepository(),
+		keyRepo:  repository.NewAPIKeyRepository(),
+	}
+}
+
+// IsAPIKey tells whether a credential is an API key rather than a JWT
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, apiKeyPrefix)
+}
+
+// CreateServiceAccount creates a service account with the role. It gets a generated email, used to
+// manage it like any user, and a random password no one knows, so it can only use API keys.
+func (s *ServiceAccountService) CreateServiceAccount(name string, role models.Role) (*models.User, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("service account name is required")
+	}
+
+	if role != models.UserRole && role != models.AdminRole {
+		return nil, errors.New("invalid service account role")
+	}
+
+	password, err := newSecretToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user := &models.User{
+		Username:        name,
+		Email:           "service-" + strings.ReplaceAll(uuid.NewString(), "-", "")[:12] + "@" + serviceAccountDomain,
+		Password:        password,
+		Role:            role,
+		EmailVerifiedAt: &now,
+		ServiceAccount:  true,
+	}
+
+	if err := s.userRepo.CreateUser(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetServiceAccounts gets the service accounts
+func (s *ServiceAccountService) GetServiceAccounts() ([]models.User, error) {
+	return s.userRepo.GetServiceAccounts()
+}
+
+// getServiceAccount gets the service account with the email
+func (s *ServiceAccountService) getServiceAccount(email string) (*models.User, error) {
+	user, err := s.userRepo.FindByEmail(email)
+	if err != nil {
+		if err.Error() == "user not found" {
+			return nil, errors.New("service account not found")
+		}
+		return nil, err
+	}
+
+	if !user.ServiceAccount {
+		return nil, errors.New("service account not found")
+	}
+	return user, nil
+}
+
+// normalizeScopes checks the scopes of a key, sorted and without duplicates
+func normalizeScopes(scopes []string) (models.APIKeyScopes, error) {
+	seen := make(map[string]bool)
+	normalized := models.APIKeyScopes{}
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		area, access, _ := strings.Cut(scope, ":")
+		if !slices.Contains(models.ScopeAreas, area) || (access != models.ReadAccess && access != models.WriteAccess) {
+			return nil, errors.New("invalid api key scope")
+		}
+
+		if !seen[scope] {
+			seen[scope] = true
+			normalized = append(normalized, scope)
+		}
+	}
+
+	if len(normalized) == 0 {
+		return nil, errors.New("invalid api key scope")
+	}
+
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+// CreateKey creates an API key for the service account with the email. The key is only returned
+// here, its hash is kept. It expires after the days given, never when 0.
+func (s *ServiceAccountService) CreateKey(email, name string, scopes []string, expiresInDays int, createdBy uint) (*models.CreatedAPIKey, error) {
+	user, err := s.getServiceAccount(email)
+	if err != nil {
+		return nil, err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("api key name is required")
+	}
+
+	normalized, err := normalizeScopes(scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := newSecretToken()
+	if err != nil {
+		return nil, err
+	}
+	key := apiKeyPrefix + secret
+
+	apiKey := models.APIKey{
+		UserID:    user.ID,
+		Name:      name,
+		Prefix:    key[:apiKeyShownLength],
+		KeyHash:   hashToken(key),
+		Scopes:    normalized,
+		CreatedBy: createdBy,
+	}
+	if expiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, expiresInDays)
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	if err := s.keyRepo.CreateKey(&apiKey); err != nil {
+		return nil, err
+	}
+
+	return &models.CreatedAPIKey{APIKey: apiKey, Key: key}, nil
+}
+
+// GetKeys gets the API keys of the service account with the email
+func (s *ServiceAccountService) GetKeys(email string) ([]models.APIKey, error) {
+	user, err := s.getServiceAccount(email)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.keyRepo.GetKeys(user.ID)
+}
+
+// RevokeKey revokes an API key of the service account with the email
+func (s *ServiceAccountService) RevokeKey(email string, keyID uint) error {
+	user, err := s.getServiceAccount(email)
+	if err != nil {
+		return err
+	}
+
+	return s.keyRepo.RevokeKey(keyID, user.ID)
+}
+
+// Authenticate finds the service account of an API key and records the use of the key
+func (s *ServiceAccountService) Authenticate(key string) (*models.APIKey, *models.User, error) {
+	if !IsAPIKey(key) {
+		return nil, nil, errors.New("invalid api key")
+	}
+
+	now := time.Now()
+	apiKey, user, err := s.keyRepo.FindActiveKey(hashToken(key), now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.keyRepo.TouchKey(apiKey.ID, now); err != nil {
+		return nil, nil, err
+	}
+
+	return apiKey, user, nil
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"hcall/api/models"
+	"hcall/api/repository"
+)
+
+// tagPattern matches the tag names, lower case letters, digits, dots, dashes and underscores
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,49}$`)
+
+type TagService struct {
+	tagRepo *repository.TagRepository
+}
+
+func NewTagService() *TagService {
+	return &TagService{
+		tagRepo: repository.NewTagRepository(),
+	}
+}
+
+// normalizeTag trims and lower cases a tag name and checks it is valid
+func normalizeTag(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !tagPattern.MatchString(name) {
+		return "", errors.New("invalid tag name")
+	}
+	return name, nil
+}
+
+// normalizeTags normalizes a list of tag names, dropping the duplicates
+func normalizeTags(names []string) ([]string, error) {
+	var tags []string
+	seen := map[string]bool{}
+	for _, name := range splitList(names) {
+		tag, err := normalizeTag(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+// GetTags gets every tag with the number of tickets labeled with it
+func (s *TagService) GetTags() ([]models.TagUsage, error) {
+	return s.tagRepo.GetTagUsage()
+}
+
+// RenameTag changes the name of a tag, an existing name must be merged instead
+func (s *TagService) RenameTag(name, newName string) error {
+	tag, err := s.findTag(name)
+	if err != nil {
+		return err
+	}
+
+	newName, err = normalizeTag(newName)
+	if err != nil {
+		return err
+	}
+
+	if newName == tag.Name {
+		return errors.New("new name is the same as the current name")
+	}
+
+	if _, err := s.tagRepo.GetTagByName(newName); err == nil {
+		return errors.New("tag already exists")
+	} else if err.Error() != "tag not found" {
+		return err
+	}
+
+	return s.tagRepo.RenameTag(tag.ID, newName)
+}
+
+// MergeTags moves the tickets of the source tag to the target tag, the source tag is deleted
+func (s *TagService) MergeTags(sourceName, targetName string) error {
+	source, err := s.findTag(sourceName)
+	if err != nil {
+		return err
+	}
+
+	target, err := s.findTag(targetName)
+	if err != nil {
+		return err
+	}
+
+	if source.ID == target.ID {
+		return errors.New("a tag can't be merged into itself")
+	}
+
+	return s.tagRepo.MergeTags(source.ID, target.ID)
+}
+
+// findTag gets a tag by a name given by the user
+func (s *TagService) findTag(name string) (*models.Tag, error) {
+	name, err := normalizeTag(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.tagRepo.GetTagByName(name)
+}
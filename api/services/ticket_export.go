@@ -0,0 +1,295 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"hcall/api/models"
+	"hcall/api/repository"
+	"hcall/api/utils"
+)
+
+// exportBatchSize is the number of tickets read and written at once by an export
+const exportBatchSize = 500
+
+// exportedMessage is a message of the conversation of an exported ticket
+type exportedMessage struct {
+	Author     string `json:"author,omitempty"`
+	AuthorRole string `json:"author_role,omitempty"`
+	Visibility string `json:"visibility"`
+	Message    string `json:"message"`
+	CreatedAt  string `json:"created_at"`
+}
+
+// exportRow is an exported ticket with the names of its users
+type exportRow struct {
+	ticket *models.Ticket
+	author string
+}
+
+// exportColumn is a column of the ticket exports. Values are strings, string lists, custom fields,
+// messages, or nil when the ticket has no value.
+type exportColumn struct {
+	name  string
+	value func(row *exportRow) interface{}
+}
+
+// exportColumns are the columns the exports can hold, in their order
+var exportColumns = []exportColumn{
+	{"id", func(row *exportRow) interface{} { return row.ticket.ID }},
+	{"name", func(row *exportRow) interface{} { return row.ticket.Name }},
+	{"description", func(row *exportRow) interface{} { return row.ticket.Explanation }},
+	{"status", func(row *exportRow) interface{} { return string(row.ticket.Status) }},
+	{"priority", func(row *exportRow) interface{} { return string(row.ticket.Priority) }},
+	{"sla_state", func(row *exportRow) interface{} { return string(row.ticket.SLAState) }},
+	{"author", func(row *exportRow) interface{} { return row.author }},
+	{"author_email", func(row *exportRow) interface{} { return row.ticket.AuthorEmail }},
+	{"assignee", func(row *exportRow) interface{} {
+		if row.ticket.Assignee == nil {
+			return nil
+		}
+		return row.ticket.Assignee.Username
+	}},
+	{"assignee_email", func(row *exportRow) interface{} {
+		if row.ticket.Assignee == nil {
+			return nil
+		}
+		return row.ticket.Assignee.Email
+	}},
+	{"queue", func(row *exportRow) interface{} {
+		if row.ticket.Queue == nil {
+			return nil
+		}
+		return row.ticket.Queue.Name
+	}},
+	{"tags", func(row *exportRow) interface{} { return row.ticket.TagNames() }},
+	{"custom_fields", func(row *exportRow) interface{} { return row.ticket.CustomFields }},
+	{"created_at", func(row *exportRow) interface{} { return exportTime(&row.ticket.CreatedAt) }},
+	{"updated_at", func(row *exportRow) interface{} { return exportTime(&row.ticket.UpdatedAt) }},
+	{"first_response_due_at", func(row *exportRow) interface{} { return exportTime(row.ticket.FirstResponseDueAt) }},
+	{"resolution_due_at", func(row *exportRow) interface{} { return exportTime(row.ticket.ResolutionDueAt) }},
+	{"first_response_at", func(row *exportRow) interface{} { return exportTime(row.ticket.FirstResponseAt) }},
+	{"resolved_at", func(row *exportRow) interface{} { return exportTime(row.ticket.ResolvedAt) }},
+	{"history", func(row *exportRow) interface{} {
+		messages := make([]exportedMessage, len(row.ticket.History))
+		for i, message := range row.ticket.History {
+			messages[i] = exportedMessage{
+				Author:     message.AuthorName,
+				AuthorRole: string(message.AuthorRole),
+				Visibility: string(message.Visibility),
+				Message:    message.Message,
+				CreatedAt:  message.CreatedAt.UTC().Format(time.RFC3339),
+			}
+		}
+		return messages
+	}},
+}
+
+// historyColumn is the conversation column, only exported when asked for as it makes the export much larger
+const historyColumn = "history"
+
+// exportTime formats a timestamp of an export, in UTC
+func exportTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// TicketExport writes the tickets matching the filters of a ticket listing as CSV or JSON lines
+type TicketExport struct {
+	service *TicketService
+	filter  repository.TicketFilter
+	format  string
+	columns []exportColumn
+	history bool
+}
+
+// NewTicketExport prepares the export of the tickets the agent can see, nothing is read before it is written
+func (s *TicketService) NewTicketExport(query utils.ExportTicketsQuery, userID uint, userRole models.Role) (*TicketExport, error) {
+	filter, err := ticketFilterFromQuery(query.FetchTicketsQuery, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.restrictToQueues(&filter, userID, userRole); err != nil {
+		return nil, err
+	}
+
+	export := &TicketExport{
+		service: s,
+		filter:  filter,
+		format:  query.Format,
+	}
+	if export.format == "" {
+		export.format = "csv"
+	}
+
+	names := splitList(query.Columns)
+	if len(names) == 0 {
+		for _, column := range exportColumns {
+			if column.name != historyColumn {
+				export.columns = append(export.columns, column)
+			}
+		}
+		return export, nil
+	}
+
+	for _, name := range names {
+		found := false
+		for _, column := range exportColumns {
+			if column.name == name {
+				export.columns = append(export.columns, column)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.New("invalid export columns")
+		}
+		export.history = export.history || name == historyColumn
+	}
+
+	return export, nil
+}
+
+// ContentType is the media type of the export
+func (e *TicketExport) ContentType() string {
+	if e.format == "ndjson" {
+		return "application/x-ndjson"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// FileName is the name the export is downloaded as
+func (e *TicketExport) FileName(now time.Time) string {
+	return fmt.Sprintf("tickets-%s.%s", now.UTC().Format("20060102-150405"), e.format)
+}
+
+// Write streams the export, calling flush after each batch of tickets. It returns how many tickets
+// were written, which is less than the matching ones when it fails midway.
+func (e *TicketExport) Write(w io.Writer, flush func()) (int, error) {
+	var csvWriter *csv.Writer
+	if e.format == "csv" {
+		csvWriter = csv.NewWriter(w)
+		header := make([]string, len(e.columns))
+		for i, column := range e.columns {
+			header[i] = column.name
+		}
+		if err := csvWriter.Write(header); err != nil {
+			return 0, err
+		}
+	}
+
+	written := 0
+	err := e.service.ticketRepo.EachTicket(e.filter, e.history, exportBatchSize, func(tickets []models.Ticket) error {
+		authorIDs := make([]uint, len(tickets))
+		for i := range tickets {
+			authorIDs[i] = tickets[i].AuthorID
+		}
+
+		authors, err := e.service.userRepo.GetUsernames(authorIDs)
+		if err != nil {
+			return err
+		}
+
+		for i := range tickets {
+			row := &exportRow{ticket: &tickets[i], author: authors[tickets[i].AuthorID]}
+			if csvWriter != nil {
+				err = csvWriter.Write(e.csvRecord(row))
+			} else {
+				err = e.writeJSONLine(w, row)
+			}
+			if err != nil {
+				return err
+			}
+			written++
+		}
+
+		if csvWriter != nil {
+			csvWriter.Flush()
+			if err := csvWriter.Error(); err != nil {
+				return err
+			}
+		}
+		flush()
+		return nil
+	})
+	if err != nil {
+		return written, err
+	}
+
+	if csvWriter != nil {
+		csvWriter.Flush()
+		return written, csvWriter.Error()
+	}
+	return written, nil
+}
+
+// csvRecord converts a ticket to a CSV record, lists are comma separated and the conversation has
+// a message per line
+func (e *TicketExport) csvRecord(row *exportRow) []string {
+	record := make([]string, len(e.columns))
+	for i, column := range e.columns {
+		var text string
+		switch value := column.value(row).(type) {
+		case nil:
+		case string:
+			text = value
+		case []string:
+			text = strings.Join(value, ",")
+		case models.CustomFields:
+			if len(value) > 0 {
+				data, _ := json.Marshal(value)
+				text = string(data)
+			}
+		case []exportedMessage:
+			lines := make([]string, len(value))
+			for j, message := range value {
+				lines[j] = fmt.Sprintf("[%s] %s (%s): %s", message.CreatedAt, message.Author, message.Visibility, message.Message)
+			}
+			text = strings.Join(lines, "\n")
+		}
+		record[i] = csvSafe(text)
+	}
+	return record
+}
+
+// csvSafe keeps spreadsheets from running the values that look like formulas
+func csvSafe(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+// writeJSONLine writes a ticket as a JSON object on its own line, keeping the order of the columns
+func (e *TicketExport) writeJSONLine(w io.Writer, row *exportRow) error {
+	var line bytes.Buffer
+	line.WriteByte('{')
+	for i, column := range e.columns {
+		if i > 0 {
+			line.WriteByte(',')
+		}
+
+		key, _ := json.Marshal(column.name)
+		value, err := json.Marshal(column.value(row))
+		if err != nil {
+			return err
+		}
+
+		line.Write(key)
+		line.WriteByte(':')
+		line.Write(value)
+	}
+	line.WriteString("}\n")
+
+	_, err := w.Write(line.Bytes())
+	return err
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"hcall/api/models"
+	"hcall/api/repository"
+	"hcall/api/utils"
+)
+
+var (
+	validStatuses   = []models.TicketStatus{models.PendingStatus, models.DoingStatus, models.OnHoldStatus, models.ConcluedStatus, models.CancelledStatus, models.ReopenedStatus}
+	validPriorities = []models.TicketPriority{models.LowPriority, models.NormalPriority, models.HighPriority, models.UrgentPriority}
+	validSLAStates  = []models.SLAState{models.SLAOk, models.SLAAtRisk, models.SLABreached}
+)
+
+// ticketFilterFromQuery turns the query parameters of a ticket listing into a repository filter
+func ticketFilterFromQuery(query utils.FetchTicketsQuery, userID uint) (repository.TicketFilter, error) {
+	filter := repository.TicketFilter{
+		AuthorEmails:  splitList(query.Author),
+		ExcludeAuthor: splitList(query.ExcludeAuthor),
+		Name:          strings.TrimSpace(query.Name),
+	}
+
+	var err error
+	if filter.Statuses, err = parseEnumList("invalid status filter", query.Status, validStatuses); err != nil {
+		return filter, err
+	}
+	if filter.ExcludeStatuses, err = parseEnumList("invalid status filter", query.ExcludeStatus, validStatuses); err != nil {
+		return filter, err
+	}
+	if filter.Priorities, err = parseEnumList("invalid priority filter", query.Priority, validPriorities); err != nil {
+		return filter, err
+	}
+	if filter.ExcludePriorities, err = parseEnumList("invalid priority filter", query.ExcludePriority, validPriorities); err != nil {
+		return filter, err
+	}
+	if filter.SLAStates, err = parseEnumList("invalid sla filter", query.SLA, validSLAStates); err != nil {
+		return filter, err
+	}
+
+	if filter.QueueIDs, err = parseIDList("invalid queue filter", query.Queue); err != nil {
+		return filter, err
+	}
+	if filter.ExcludeQueueIDs, err = parseIDList("invalid queue filter", query.ExcludeQueue); err != nil {
+		return filter, err
+	}
+	if filter.TagsAny, err = normalizeTags(query.TagsAny); err != nil {
+		return filter, err
+	}
+	if filter.TagsAll, err = normalizeTags(query.TagsAll); err != nil {
+		return filter, err
+	}
+	if filter.CustomFields, err = customFieldFilter(query.Fields); err != nil {
+		return filter, err
+	}
+
+	switch query.Assigned {
+	case "":
+	case "me":
+		filter.AssigneeID = &userID
+	case "none":
+		filter.Unassigned = true
+	default:
+		return filter, errors.New("invalid assigned filter")
+	}
+
+	// date is the former name of date_from
+	dateFrom := query.DateFrom
+	if dateFrom == "" {
+		dateFrom = query.Date
+	}
+
+	if dateFrom != "" {
+		from, err := parseDay(dateFrom)
+		if err != nil {
+			return filter, err
+		}
+		filter.CreatedFrom = &from
+	}
+
+	// Include the tickets created during the last day
+	if query.DateTo != "" {
+		to, err := parseDay(query.DateTo)
+		if err != nil {
+			return filter, err
+		}
+		to = to.AddDate(0, 0, 1)
+		filter.CreatedTo = &to
+	}
+
+	// Include the tickets due during the given day
+	if query.DueBefore != "" {
+		dueBefore, err := parseDay(query.DueBefore)
+		if err != nil {
+			return filter, err
+		}
+		dueBefore = dueBefore.AddDate(0, 0, 1)
+		filter.DueBefore = &dueBefore
+	}
+
+	return filter, nil
+}
+
+// parseDay parses a YYYY-MM-DD date
+func parseDay(value string) (time.Time, error) {
+	day, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, errors.New("invalid date format")
+	}
+	return day, nil
+}
+
+// splitList flattens query values given repeated or comma separated
+func splitList(values []string) []string {
+	var list []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
+
+// parseEnumList splits the query values and checks each one is a valid value of the enum
+func parseEnumList[T ~string](message string, values []string, valid []T) ([]T, error) {
+	var list []T
+	for _, item := range splitList(values) {
+		found := false
+		for _, v := range valid {
+			if T(item) == v {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, errors.New(message)
+		}
+		list = append(list, T(item))
+	}
+	return list, nil
+}
+
+// IsTicketFilterError tells whether a ticket listing failed because of an invalid filter value
+func IsTicketFilterError(err error) bool {
+	switch err.Error() {
+	case "invalid status filter", "invalid priority filter", "invalid sla filter", "invalid queue filter", "invalid tag name", "invalid custom field filter":
+		return true
+	}
+	return false
+}
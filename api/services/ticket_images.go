@@ -0,0 +1,221 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"hcall/api/config"
+	"hcall/api/models"
+	"hcall/api/storage"
+	"hcall/api/utils"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrImageTooLarge      = errors.New("image too large")
+	ErrInvalidImageFormat = errors.New("invalid image format")
+	ErrTooManyImages      = errors.New("too many images")
+)
+
+// ImageUpload is an image sent by a client, either as a multipart file or as base64
+type ImageUpload struct {
+	Name    string
+	Size    int64
+	Content io.Reader
+}
+
+// imageSignatures are the magic bytes of the accepted image formats
+var imageSignatures = []struct {
+	prefix      []byte
+	contentType string
+}{
+	{[]byte("\xFF\xD8\xFF"), "image/jpeg"},
+	{[]byte("\x89PNG\r\n\x1A\n"), "image/png"},
+	{[]byte("GIF87a"), "image/gif"},
+	{[]byte("GIF89a"), "image/gif"},
+}
+
+// sniffImageType detects the image format from the first bytes of the content
+func sniffImageType(head []byte) (string, bool) {
+	for _, signature := range imageSignatures {
+		if bytes.HasPrefix(head, signature.prefix) {
+			return signature.contentType, true
+		}
+	}
+	return "", false
+}
+
+// ImagesFromDTO decodes the base64 images of a JSON request, refusing the ones above the size limit
+// before decoding them
+func ImagesFromDTO(images []utils.ImageDTO) ([]ImageUpload, error) {
+	maxSize := config.AppConfig.ImageMaxFileSize()
+
+	uploads := make([]ImageUpload, 0, len(images))
+	for _, img := range images {
+		if int64(base64DecodedLen(img.Content)) > maxSize {
+			return nil, ErrImageTooLarge
+		}
+
+		content, err := decodeBase64Image(img.Content)
+		if err != nil {
+			return nil, err
+		}
+
+		uploads = append(uploads, ImageUpload{
+			Name:    img.Name,
+			Size:    int64(len(content)),
+			Content: bytes.NewReader(content),
+		})
+	}
+	return uploads, nil
+}
+
+// base64DecodedLen estimates the decoded size of a base64 image, ignoring a data URL prefix
+func base64DecodedLen(content string) int {
+	if i := strings.Index(content, ";base64,"); i >= 0 && strings.HasPrefix(content, "data:") {
+		content = content[i+len(";base64,"):]
+	}
+	return len(strings.TrimSpace(content)) / 4 * 3
+}
+
+// checkImageLimits validates the uploads against the per-file and per-ticket limits, taking into
+// account the images the ticket already has
+func checkImageLimits(uploads []ImageUpload, existingCount int64, existingSize int64) error {
+	if existingCount+int64(len(uploads)) > int64(config.AppConfig.ImageMaxPerTicket) {
+		return ErrTooManyImages
+	}
+
+	total := existingSize
+	for _, upload := range uploads {
+		if upload.Size > config.AppConfig.ImageMaxFileSize() {
+			return ErrImageTooLarge
+		}
+		total += upload.Size
+	}
+
+	if total > config.AppConfig.ImageMaxTicketSize() {
+		return ErrImageTooLarge
+	}
+	return nil
+}
+
+// detectImageType reads the head of an upload to find its real format and returns a reader
+// with the whole content
+func detectImageType(upload ImageUpload) (string, io.Reader, error) {
+	head := make([]byte, 8)
+	n, err := io.ReadFull(upload.Content, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", nil, err
+	}
+	head = head[:n]
+
+	contentType, ok := sniffImageType(head)
+	if !ok {
+		return "", nil, ErrInvalidImageFormat
+	}
+
+	return contentType, io.MultiReader(bytes.NewReader(head), upload.Content), nil
+}
+
+// preparedImage is an upload whose real format was already detected
+type preparedImage struct {
+	name        string
+	contentType string
+	size        int64
+	content     io.Reader
+}
+
+// prepareImages checks the real format of every upload, so nothing is stored when one of them is refused
+func prepareImages(uploads []ImageUpload) ([]preparedImage, error) {
+	prepared := make([]preparedImage, 0, len(uploads))
+	for _, upload := range uploads {
+		contentType, content, err := detectImageType(upload)
+		if err != nil {
+			return nil, err
+		}
+
+		prepared = append(prepared, preparedImage{
+			name:        upload.Name,
+			contentType: contentType,
+			size:        upload.Size,
+			content:     content,
+		})
+	}
+	return prepared, nil
+}
+
+// putImages writes the prepared images to the blob store and returns their records, to be created
+// with the ticket, and their keys. Nothing is left in the store when one of them fails.
+func putImages(ctx context.Context, ticketID string, prepared []preparedImage) ([]models.Image, []string, error) {
+	images := make([]models.Image, 0, len(prepared))
+	keys := make([]string, 0, len(prepared))
+	for _, upload := range prepared {
+		key := "tickets/" + ticketID + "/" + uuid.New().String()
+		if err := storage.Store.Put(ctx, key, upload.content, upload.size, upload.contentType); err != nil {
+			storage.DeleteAll(ctx, keys)
+			return nil, nil, fmt.Errorf("failed to store image: %w", err)
+		}
+		keys = append(keys, key)
+
+		images = append(images, models.Image{
+			Name:        upload.name,
+			ContentType: upload.contentType,
+			Size:        upload.size,
+			StorageKey:  key,
+			UploadedAt:  time.Now(),
+		})
+	}
+	return images, keys, nil
+}
+
+// saveImages writes the prepared images to the blob store
+func (s *TicketService) saveImages(ticketID string, prepared []preparedImage) ([]models.Image, error) {
+	images := make([]models.Image, 0, len(prepared))
+	for _, upload := range prepared {
+		image, err := s.storeImage(ticketID, upload.name, upload.contentType, upload.content, upload.size)
+		if err != nil {
+			return images, err
+		}
+		images = append(images, *image)
+	}
+	return images, nil
+}
+
+// AddTicketImages attaches images to an existing ticket. The author can add images while the ticket
+// is open, agents at any time.
+func (s *TicketService) AddTicketImages(ticketID string, userID uint, userRole models.Role, uploads []ImageUpload) ([]models.Image, error) {
+	if userRole != models.AdminRole && userRole != models.MasterRole {
+		ticket, err := s.getOwnedTicket(ticketID, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		if ticket.Status.IsClosed() {
+			return nil, errors.New("ticket is closed")
+		}
+	} else if _, err := s.getAgentTicket(ticketID, userID, userRole); err != nil {
+		return nil, err
+	}
+
+	count, size, err := s.ticketRepo.GetTicketImageUsage(ticketID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkImageLimits(uploads, count, size); err != nil {
+		return nil, err
+	}
+
+	prepared, err := prepareImages(uploads)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.saveImages(ticketID, prepared)
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"hcall/api/models"
+	"hcall/api/repository"
+	"hcall/api/utils"
+)
+
+const (
+	// metricsDefaultDays is the length of the metrics range when it has no start
+	metricsDefaultDays = 30
+	// metricsMaxDays bounds the metrics range, every day of it is listed in the answer
+	metricsMaxDays = 366
+)
+
+// GetMetrics computes the helpdesk metrics of the tickets the agent can see over a range of days.
+// The range defaults to the last 30 days, up to today.
+func (s *TicketService) GetMetrics(query utils.TicketMetricsQuery, userID uint, userRole models.Role) (*models.HelpdeskMetrics, error) {
+	now := time.Now().UTC()
+	to := now.Truncate(24 * time.Hour)
+	if query.DateTo != "" {
+		day, err := parseDay(query.DateTo)
+		if err != nil {
+			return nil, err
+		}
+		to = day
+	}
+
+	from := to.AddDate(0, 0, 1-metricsDefaultDays)
+	if query.DateFrom != "" {
+		day, err := parseDay(query.DateFrom)
+		if err != nil {
+			return nil, err
+		}
+		from = day
+	}
+
+	// Include the last day
+	end := to.AddDate(0, 0, 1)
+	if from.After(to) || end.After(from.AddDate(0, 0, metricsMaxDays)) {
+		return nil, errors.New("invalid date range")
+	}
+
+	filter := repository.TicketFilter{}
+	if err := s.restrictToQueues(&filter, userID, userRole); err != nil {
+		return nil, err
+	}
+
+	groupBy := models.MetricsGroupBy(query.GroupBy)
+	responses, err := s.ticketRepo.GetFirstResponseStats(filter, groupBy, from, end)
+	if err != nil {
+		return nil, err
+	}
+
+	resolutions, err := s.ticketRepo.GetResolutionStats(filter, groupBy, from, end)
+	if err != nil {
+		return nil, err
+	}
+
+	ages, err := s.ticketRepo.GetBacklogAges(filter, groupBy, now)
+	if err != nil {
+		return nil, err
+	}
+
+	created, closed, err := s.ticketRepo.GetDailyVolumes(filter, groupBy, from, end)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every group with something to report is listed, the ungrouped metrics always are
+	keys := map[string]bool{}
+	if groupBy == "" {
+		keys[""] = true
+	}
+	for key := range responses {
+		keys[key] = true
+	}
+	for key := range resolutions {
+		keys[key] = true
+	}
+	for key := range ages {
+		keys[key] = true
+	}
+	for key := range created {
+		keys[key] = true
+	}
+	for key := range closed {
+		keys[key] = true
+	}
+
+	groups := make([]models.MetricsGroup, 0, len(keys))
+	for key := range keys {
+		group := models.MetricsGroup{
+			Key:           key,
+			Label:         s.metricsLabel(groupBy, key),
+			FirstResponse: responses[key],
+			Resolution:    resolutions[key],
+			BacklogAge:    make([]models.AgeBucket, len(repository.BacklogBuckets)),
+		}
+
+		for i, bucket := range repository.BacklogBuckets {
+			group.BacklogAge[i].Bucket = bucket.Name
+			if counts, ok := ages[key]; ok {
+				group.BacklogAge[i].Count = counts[i]
+			}
+		}
+
+		for day := from; day.Before(end); day = day.AddDate(0, 0, 1) {
+			name := day.Format("2006-01-02")
+			group.Daily = append(group.Daily, models.DailyVolume{
+				Day:     name,
+				Created: created[key][name],
+				Closed:  closed[key][name],
+			})
+		}
+
+		groups = append(groups, group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Label != groups[j].Label {
+			return groups[i].Label < groups[j].Label
+		}
+		return groups[i].Key < groups[j].Key
+	})
+
+	return &models.HelpdeskMetrics{
+		DateFrom: from.Format("2006-01-02"),
+		DateTo:   to.Format("2006-01-02"),
+		GroupBy:  groupBy,
+		Groups:   groups,
+	}, nil
+}
+
+// metricsLabel names a metrics group, the agents and queues that don't exist anymore are reported as unknown
+func (s *TicketService) metricsLabel(groupBy models.MetricsGroupBy, key string) string {
+	switch groupBy {
+	case models.MetricsByStatus:
+		return key
+	case models.MetricsByAgent:
+		if key == "" {
+			return "Unassigned"
+		}
+		id, err := strconv.ParseUint(key, 10, 64)
+		if err != nil {
+			return "Unknown User"
+		}
+		user, err := s.userRepo.FindByID(uint(id))
+		if err != nil {
+			return "Unknown User"
+		}
+		return user.Username
+	case models.MetricsByCategory:
+		if key == "" {
+			return "No queue"
+		}
+		id, err := strconv.ParseUint(key, 10, 64)
+		if err != nil {
+			return "Unknown queue"
+		}
+		queue, err := s.queueRepo.GetQueue(uint(id))
+		if err != nil {
+			return "Unknown queue"
+		}
+		return queue.Name
+	default:
+		return "all"
+	}
+}
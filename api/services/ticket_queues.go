@@ -0,0 +1,101 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+
+	"hcall/api/models"
+	"hcall/api/repository"
+)
+
+// resolveQueue gets the queue picked for a new ticket, tickets created without a queue go to the
+// default queue when there is one
+func (s *TicketService) resolveQueue(queueID *uint) (*models.Queue, error) {
+	if queueID == nil {
+		return s.queueRepo.GetDefaultQueue()
+	}
+
+	return s.queueRepo.GetQueue(*queueID)
+}
+
+// canWorkQueue tells whether an agent may see and work the tickets of a queue. Masters work every
+// queue and the tickets without a queue are shared by all the agents.
+func (s *TicketService) canWorkQueue(queueID *uint, userID uint, userRole models.Role) (bool, error) {
+	switch {
+	case userRole == models.MasterRole:
+		return true, nil
+	case userRole != models.AdminRole:
+		return false, nil
+	case queueID == nil:
+		return true, nil
+	}
+
+	return s.queueRepo.IsMember(*queueID, userID)
+}
+
+// getAgentTicket loads a ticket worked by an agent. Tickets of the queues the agent isn't a member of
+// are reported as not found so their existence isn't leaked.
+func (s *TicketService) getAgentTicket(ticketID string, userID uint, userRole models.Role) (*models.Ticket, error) {
+	ticket, err := s.ticketRepo.GetTicket(ticketID)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed, err := s.canWorkQueue(ticket.QueueID, userID, userRole)
+	if err != nil {
+		return nil, err
+	}
+
+	if !allowed {
+		return nil, errors.New("ticket not found")
+	}
+
+	return ticket, nil
+}
+
+// restrictToQueues limits a ticket listing to the queues the agent is a member of
+func (s *TicketService) restrictToQueues(filter *repository.TicketFilter, userID uint, userRole models.Role) error {
+	if userRole == models.MasterRole {
+		return nil
+	}
+
+	queueIDs, err := s.queueRepo.GetMemberQueueIDs(userID)
+	if err != nil {
+		return err
+	}
+
+	filter.RestrictQueues = true
+	filter.VisibleQueueIDs = queueIDs
+	return nil
+}
+
+// parseIDList splits the query values and parses each one as an ID
+func parseIDList(message string, values []string) ([]uint, error) {
+	var list []uint
+	for _, item := range splitList(values) {
+		id, err := strconv.ParseUint(item, 10, 64)
+		if err != nil {
+			return nil, errors.New(message)
+		}
+		list = append(list, uint(id))
+	}
+	return list, nil
+}
+
+// GetQueueCounters counts the tickets of a queue per status, only its members and the masters can see them
+func (s *TicketService) GetQueueCounters(queueID uint, userID uint, userRole models.Role) (*models.Counters, error) {
+	if _, err := s.queueRepo.GetQueue(queueID); err != nil {
+		return nil, err
+	}
+
+	allowed, err := s.canWorkQueue(&queueID, userID, userRole)
+	if err != nil {
+		return nil, err
+	}
+
+	if !allowed {
+		return nil, errors.New("you don't have access to this queue")
+	}
+
+	return s.ticketRepo.CountTicketsByStatus(repository.TicketFilter{QueueIDs: []uint{queueID}})
+}
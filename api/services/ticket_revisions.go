@@ -0,0 +1,149 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"hcall/api/models"
+)
+
+// EditTicketContent changes the name and explanation of a ticket, nil leaves a field as it is.
+// Authors can edit their tickets while they are pending, agents can edit the tickets of their queues
+// at any time. Every edit is kept as a revision of the ticket.
+func (s *TicketService) EditTicketContent(ticketID string, name, explanation *string, actorID uint, actorRole models.Role) error {
+	var ticket *models.Ticket
+	var onlyStatus models.TicketStatus
+
+	if actorRole == models.AdminRole || actorRole == models.MasterRole {
+		var err error
+		ticket, err = s.getAgentTicket(ticketID, actorID, actorRole)
+		if err != nil && err.Error() != "ticket not found" {
+			return err
+		}
+	}
+
+	// Agents editing their own tickets outside their queues follow the author rules
+	if ticket == nil {
+		var err error
+		ticket, err = s.getOwnedTicket(ticketID, actorID)
+		if err != nil {
+			return err
+		}
+
+		if ticket.Status != models.PendingStatus {
+			return errors.New("ticket can only be edited while pending")
+		}
+		onlyStatus = models.PendingStatus
+	}
+
+	actor, err := s.userRepo.FindByID(actorID)
+	if err != nil {
+		return err
+	}
+
+	revision := &models.TicketRevision{
+		TicketID:  ticketID,
+		ActorID:   actor.ID,
+		ActorName: actor.Username,
+		ActorRole: actor.Role,
+		CreatedAt: time.Now(),
+	}
+
+	var diff []string
+	if name != nil {
+		newName := strings.TrimSpace(*name)
+		if newName == "" {
+			return errors.New("ticket name can't be empty")
+		}
+
+		if newName != ticket.Name {
+			revision.PreviousName = ticket.Name
+			revision.Name = newName
+			diff = append(diff, "@@ ticket_name", lineDiff(ticket.Name, newName))
+		}
+	}
+
+	if explanation != nil {
+		newExplanation := strings.TrimSpace(*explanation)
+		if newExplanation == "" {
+			return errors.New("ticket explanation can't be empty")
+		}
+
+		if newExplanation != ticket.Explanation {
+			revision.PreviousExplanation = ticket.Explanation
+			revision.Explanation = newExplanation
+			diff = append(diff, "@@ ticket_explain", lineDiff(ticket.Explanation, newExplanation))
+		}
+	}
+
+	if len(diff) == 0 {
+		return errors.New("new content is the same as the current content")
+	}
+	revision.Diff = strings.Join(diff, "\n")
+
+	return s.ticketRepo.UpdateTicketContent(revision, onlyStatus)
+}
+
+// maxDiffLines is the number of lines, before and after, above which lineDiff doesn't look for the
+// unchanged lines, its table would take too much memory
+const maxDiffLines = 2000
+
+// lineDiff compares two texts line by line. Removed lines start with "- ", added lines with "+ "
+// and unchanged lines with two spaces.
+func lineDiff(before, after string) string {
+	a := strings.Split(before, "\n")
+	b := strings.Split(after, "\n")
+
+	// The whole text is replaced
+	if len(a)+len(b) > maxDiffLines {
+		lines := make([]string, 0, len(a)+len(b))
+		for _, line := range a {
+			lines = append(lines, "- "+line)
+		}
+		for _, line := range b {
+			lines = append(lines, "+ "+line)
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	lines := make([]string, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, "  "+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, "- "+a[i])
+			i++
+		default:
+			lines = append(lines, "+ "+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, "- "+a[i])
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, "+ "+b[j])
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"hcall/api/models"
+	"hcall/api/utils"
+)
+
+// SearchTickets gets the tickets whose name, explanation or conversation match the search text and the
+// listing filters, most relevant first. Admins only get the tickets of their queues. The highlights of each ticket are returned by ticket ID.
+func (s *TicketService) SearchTickets(query utils.SearchTicketsQuery, userID uint, userRole models.Role) ([]models.Ticket, map[string]models.TicketHighlight, *models.Page, error) {
+	text := strings.TrimSpace(query.Query)
+	if text == "" {
+		return nil, nil, nil, errors.New("search text is required")
+	}
+
+	filter, err := ticketFilterFromQuery(query.FetchTicketsQuery, userID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if err := s.restrictToQueues(&filter, userID, userRole); err != nil {
+		return nil, nil, nil, err
+	}
+
+	page := newSortedPage(query.PageQuery, "relevance")
+	tickets, err := s.ticketRepo.Paginate(page).SearchTickets(filter, text)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	ticketIDs := make([]string, len(tickets))
+	for i, ticket := range tickets {
+		ticketIDs[i] = ticket.ID
+	}
+
+	highlights, err := s.ticketRepo.GetSearchHighlights(ticketIDs, text)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Only keep the snippets where the text was found
+	for id, highlight := range highlights {
+		highlight.Name = markedOnly(highlight.Name)
+		highlight.Explanation = markedOnly(highlight.Explanation)
+		highlight.Messages = markedOnly(highlight.Messages)
+		highlights[id] = highlight
+	}
+
+	return tickets, highlights, page, nil
+}
+
+// markedOnly drops a snippet without matched words, ts_headline returns the start of the text for them
+func markedOnly(snippet string) string {
+	if !strings.Contains(snippet, "<mark>") {
+		return ""
+	}
+	return snippet
+}
@@ -1,151 +1,281 @@
 package services
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"io"
+	"strings"
 	"time"
 
+	"hcall/api/config"
+	"hcall/api/logger"
 	"hcall/api/models"
 	"hcall/api/repository"
+	"hcall/api/storage"
 	"hcall/api/utils"
+
+	"github.com/google/uuid"
 )
 
 type TicketService struct {
 	ticketRepo *repository.TicketRepository
 	userRepo   *repository.UserRepository
+	queueRepo  *repository.QueueRepository
+	tagRepo    *repository.TagRepository
 }
 
 func NewTicketService() *TicketService {
 	return &TicketService{
 		ticketRepo: repository.NewTicketRepository(),
 		userRepo:   repository.NewUserRepository(),
+		queueRepo:  repository.NewQueueRepository(),
+		tagRepo:    repository.NewTagRepository(),
 	}
 }
 
-// CreateTicket creates a new ticket
-func (s *TicketService) CreateTicket(authorID uint, authorEmail, name, explanation string, images []utils.ImageDTO) error {
-	// Create the ticket
-	ticket := &models.Ticket{
-		Name:        name,
-		Explanation: explanation,
-		Status:      models.PendingStatus,
-		AuthorID:    authorID,
-		AuthorEmail: authorEmail,
-		Images:      []models.Image{},
-		CreatedAt:   time.Now(),
+// CreateTicket creates a new ticket in the given queue, or in the default queue when none is given.
+// The custom fields are validated against the field schema of the queue.
+func (s *TicketService) CreateTicket(authorID uint, authorEmail, name, explanation string, priority models.TicketPriority, queueID *uint, customFields map[string]interface{}, images []ImageUpload) error {
+	if priority == "" {
+		priority = models.NormalPriority
 	}
 
-	// Save the ticket to the database
-	if err := s.ticketRepo.CreateTicket(ticket); err != nil {
+	queue, err := s.resolveQueue(queueID)
+	if err != nil {
 		return err
 	}
 
-	ticketStatus := string(ticket.Status)
-	if err := s.ticketRepo.CountTicket(ticketStatus); err != nil {
+	var schema *models.FieldSchema
+	if queue != nil {
+		queueID = &queue.ID
+		schema = queue.FieldSchema
+	}
+
+	fields, err := validateCustomFields(schema, customFields, s.userExists)
+	if err != nil {
 		return err
 	}
 
-	// Process images if there are any
-	if len(images) > 0 {
-		// Save each image directly to the database with base64 content
-		for _, img := range images {
-			// Create image record in database
-			image := &models.Image{
-				TicketID:    ticket.ID,
-				Name:        img.Name,
-				ContentType: img.Type,
-				Base64:      img.Content, // Salva o conteúdo base64 diretamente
-				UploadedAt:  time.Now(),
-			}
+	// Refuse the images before creating anything
+	if err := checkImageLimits(images, 0, 0); err != nil {
+		return err
+	}
 
-			if err := s.ticketRepo.CreateImage(image); err != nil {
-				return err
-			}
-		}
+	prepared, err := prepareImages(images)
+	if err != nil {
+		return err
+	}
+
+	// The ID is known before the insert so the images content can be stored first
+	now := time.Now()
+	firstResponseDue, resolutionDue := slaDeadlines(priority, now)
+	ticket := &models.Ticket{
+		ID:                 "ticket_" + uuid.New().String(),
+		Name:               name,
+		Explanation:        explanation,
+		Status:             models.PendingStatus,
+		AuthorID:           authorID,
+		AuthorEmail:        authorEmail,
+		QueueID:            queueID,
+		CustomFields:       fields,
+		Priority:           priority,
+		SLAState:           models.SLAOk,
+		FirstResponseDueAt: firstResponseDue,
+		ResolutionDueAt:    resolutionDue,
+		CreatedAt:          now,
+	}
+
+	// Save each image content in the blob store, the ticket keeps only their references
+	ctx := context.Background()
+	stored, keys, err := putImages(ctx, ticket.ID, prepared)
+	if err != nil {
+		return err
+	}
+	ticket.Images = stored
+
+	// Save the ticket with its images to the database, its counters are updated with it
+	if err := s.ticketRepo.CreateTicket(ticket); err != nil {
+		// Don't leave orphan content behind
+		storage.DeleteAll(ctx, keys)
+		return err
 	}
 
 	return nil
 }
 
-func checkDate(date string) bool {
-	// check if date is in format YYYY-MM-DD and using "-" to separate year, month and day
-	if len(date) != 10 {
-		return false
+// decodeBase64Image decodes the content of an image sent as base64, with or without a data URL prefix
+func decodeBase64Image(content string) ([]byte, error) {
+	if i := strings.Index(content, ";base64,"); i >= 0 && strings.HasPrefix(content, "data:") {
+		content = content[i+len(";base64,"):]
 	}
-	return true
-}
 
-// GetTickets gets all tickets or tickets by author or status
-func (s *TicketService) GetTickets(authorEmail string, status string, date string, name string) ([]models.Ticket, error) {
-	if date != "" {
-		if !checkDate(date) {
-			return nil, errors.New("invalid date format")
-		}
-		date = date[:10]
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(content))
+	if err != nil {
+		return nil, errors.New("invalid image content")
 	}
+	return decoded, nil
+}
+
+// storeImage writes the image content to the blob store and creates its record
+func (s *TicketService) storeImage(ticketID, name, contentType string, content io.Reader, size int64) (*models.Image, error) {
+	key := "tickets/" + ticketID + "/" + uuid.New().String()
+	ctx := context.Background()
 
-	// Get all tickets
-	if authorEmail == "" && status == "" && date == "" && name == "" {
-		return s.ticketRepo.GetTickets()
+	if err := storage.Store.Put(ctx, key, content, size, contentType); err != nil {
+		return nil, fmt.Errorf("failed to store image: %w", err)
 	}
 
-	// Get tickets by name only
-	if authorEmail == "" && status == "" && date == "" && name != "" {
-		return s.ticketRepo.GetTicketsByName(name)
+	image := &models.Image{
+		TicketID:    ticketID,
+		Name:        name,
+		ContentType: contentType,
+		Size:        size,
+		StorageKey:  key,
+		UploadedAt:  time.Now(),
 	}
 
-	// Get tickets by author
-	if authorEmail != "" && status == "" && date == "" && name == "" {
-		return s.ticketRepo.GetTicketsByAuthor(authorEmail)
+	if err := s.ticketRepo.CreateImage(image); err != nil {
+		// Don't leave orphan content behind
+		storage.DeleteAll(ctx, []string{key})
+		return nil, err
 	}
 
-	// Get tickets by status
-	if authorEmail == "" && status != "" && date == "" && name == "" {
-		statusEnum := models.TicketStatus(status)
-		return s.ticketRepo.GetTicketsByStatus(statusEnum)
+	return image, nil
+}
+
+// MigrateLegacyImages moves a batch of images still kept as base64 in the database to the blob store,
+// skipping the images of skipIDs. An image that can't be moved is logged and left as it is so the
+// others still move, its id is returned with the failed ones. No moved and no failed images means
+// there is nothing left to migrate.
+func (s *TicketService) MigrateLegacyImages(batchSize int, skipIDs []string) (int, []string, error) {
+	images, err := s.ticketRepo.GetImagesWithoutStorage(batchSize, skipIDs)
+	if err != nil {
+		return 0, nil, err
 	}
 
-	if authorEmail == "" && status == "" && date != "" {
-		return s.ticketRepo.GetTicketsByDate(date)
+	ctx := context.Background()
+	migrated := 0
+	var failed []string
+	for _, image := range images {
+		if err := s.migrateLegacyImage(ctx, image); err != nil {
+			logger.Error("Ticket Service: Failed to migrate image", map[string]interface{}{
+				"image_id":  image.ID,
+				"ticket_id": image.TicketID,
+				"error":     err.Error(),
+			})
+			failed = append(failed, image.ID)
+			continue
+		}
+		migrated++
 	}
 
-	if authorEmail != "" && status != "" && date == "" {
-		statusEnum := models.TicketStatus(status)
-		return s.ticketRepo.GetTicketsByAuthorAndStatus(authorEmail, statusEnum)
+	return migrated, failed, nil
+}
+
+// migrateLegacyImage moves the base64 content of an image to the blob store
+func (s *TicketService) migrateLegacyImage(ctx context.Context, image models.Image) error {
+	content, err := decodeBase64Image(image.Base64)
+	if err != nil {
+		return err
 	}
 
-	if authorEmail != "" && status == "" && date != "" {
-		return s.ticketRepo.GetTicketsByAuthorAndDate(authorEmail, date)
+	key := "tickets/" + image.TicketID + "/" + uuid.New().String()
+	if err := storage.Store.Put(ctx, key, bytes.NewReader(content), int64(len(content)), image.ContentType); err != nil {
+		return err
 	}
 
-	if authorEmail == "" && status != "" && date != "" {
-		statusEnum := models.TicketStatus(status)
-		return s.ticketRepo.GetTicketsByStatusAndDate(statusEnum, date)
+	if err := s.ticketRepo.MoveImageToStorage(image.ID, key, int64(len(content))); err != nil {
+		storage.DeleteAll(ctx, []string{key})
+		return err
 	}
+	return nil
+}
 
-	if authorEmail != "" && status != "" && date != "" {
-		statusEnum := models.TicketStatus(status)
-		return s.ticketRepo.GetTicketsByAuthorAndStatusAndDate(authorEmail, statusEnum, date)
+// OpenImage gets an image and opens its content, only agents and the author of the ticket can read it.
+// The caller must close the returned reader.
+func (s *TicketService) OpenImage(imageID string, userID uint, userRole models.Role) (*models.Image, io.ReadCloser, error) {
+	image, err := s.ticketRepo.GetImage(imageID)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// Add new combinations with name
-	if name != "" {
-		if authorEmail != "" {
-			return s.ticketRepo.GetTicketsByAuthorAndName(authorEmail, name)
+	if userRole != models.AdminRole && userRole != models.MasterRole {
+		if _, err := s.getOwnedTicket(image.TicketID, userID); err != nil {
+			return nil, nil, errors.New("image not found")
 		}
-		if status != "" {
-			statusEnum := models.TicketStatus(status)
-			return s.ticketRepo.GetTicketsByStatusAndName(statusEnum, name)
+	} else if _, err := s.getAgentTicket(image.TicketID, userID, userRole); err != nil {
+		return nil, nil, errors.New("image not found")
+	}
+
+	// Images not moved by the migrate-images command yet are still kept as base64 in the database
+	if !image.IsStored() {
+		content, err := decodeBase64Image(image.Base64)
+		if err != nil {
+			return nil, nil, err
 		}
-		if date != "" {
-			return s.ticketRepo.GetTicketsByDateAndName(date, name)
+		image.Size = int64(len(content))
+		return image, io.NopCloser(bytes.NewReader(content)), nil
+	}
+
+	content, err := storage.Store.Get(context.Background(), image.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, nil, errors.New("image not found")
 		}
+		return nil, nil, err
+	}
+
+	return image, content, nil
+}
+
+// slaDeadlines computes the first response and resolution due dates of a ticket opened at the given time
+func slaDeadlines(priority models.TicketPriority, openedAt time.Time) (*time.Time, *time.Time) {
+	policy, ok := config.AppConfig.SLAPolicies[string(priority)]
+	if !ok {
+		return nil, nil
+	}
+
+	firstResponseDue := openedAt.Add(time.Duration(policy.FirstResponseHours) * time.Hour)
+	resolutionDue := openedAt.Add(time.Duration(policy.ResolutionHours) * time.Hour)
+	return &firstResponseDue, &resolutionDue
+}
+
+// GetTickets gets the tickets matching the query filters, all of them are combined in a single query.
+// Admins only get the tickets of their queues. The listing is paginated, the returned page holds the
+// total and the next cursor.
+func (s *TicketService) GetTickets(query utils.FetchTicketsQuery, userID uint, userRole models.Role) ([]models.Ticket, *models.Page, error) {
+	filter, err := ticketFilterFromQuery(query, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.restrictToQueues(&filter, userID, userRole); err != nil {
+		return nil, nil, err
+	}
+
+	page := newPage(query.PageQuery)
+	tickets, err := s.ticketRepo.Paginate(page).FindTickets(filter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tickets, page, nil
+}
+
+// GetTicketDetails gets the details of a ticket of a queue the agent works
+func (s *TicketService) GetTicketDetails(ticketID string, userID uint, userRole models.Role) (*models.Ticket, error) {
+	if _, err := s.getAgentTicket(ticketID, userID, userRole); err != nil {
+		return nil, err
 	}
 
-	return nil, errors.New("invalid query parameters")
+	return s.ticketDetails(ticketID)
 }
 
-// GetTicketDetails gets the details of a ticket
-func (s *TicketService) GetTicketDetails(ticketID string) (*models.Ticket, error) {
+// ticketDetails gets a ticket with its images and history
+func (s *TicketService) ticketDetails(ticketID string) (*models.Ticket, error) {
 	ticket, err := s.ticketRepo.GetTicketWithDetails(ticketID)
 	if err != nil {
 		return nil, err
@@ -164,31 +294,375 @@ func (s *TicketService) GetTicketDetails(ticketID string) (*models.Ticket, error
 	return ticket, nil
 }
 
-// UpdateTicketStatus updates the status of a ticket
-func (s *TicketService) UpdateTicketStatus(ticketID string, status models.TicketStatus) error {
-	if err := s.ticketRepo.UpdateTicketStatus(ticketID, status); err != nil {
+// GetOwnTickets gets the tickets created by the given user
+func (s *TicketService) GetOwnTickets(userID uint, query utils.FetchMyTicketsQuery) ([]models.Ticket, *models.Page, error) {
+	statuses, err := parseEnumList("invalid status filter", query.Status, validStatuses)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	page := newPage(query.PageQuery)
+	tickets, err := s.ticketRepo.Paginate(page).FindTickets(repository.TicketFilter{
+		AuthorID: &userID,
+		Statuses: statuses,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tickets, page, nil
+}
+
+// GetOwnTicketDetails gets the details of a ticket owned by the given user
+func (s *TicketService) GetOwnTicketDetails(ticketID string, userID uint) (*models.Ticket, error) {
+	if _, err := s.getOwnedTicket(ticketID, userID); err != nil {
+		return nil, err
+	}
+
+	ticket, err := s.ticketDetails(ticketID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Internal notes are only visible to agents
+	public := []models.TicketHistory{}
+	for _, message := range ticket.History {
+		if !message.IsInternal() {
+			public = append(public, message)
+		}
+	}
+	ticket.History = public
+
+	return ticket, nil
+}
+
+// GetOwnTicketImages gets the images of a ticket owned by the given user
+func (s *TicketService) GetOwnTicketImages(ticketID string, userID uint) ([]models.Image, error) {
+	if _, err := s.getOwnedTicket(ticketID, userID); err != nil {
+		return nil, err
+	}
+
+	return s.ticketRepo.GetTicketImages(ticketID)
+}
+
+// GetOwnTicketHistory gets the history of a ticket owned by the given user
+func (s *TicketService) GetOwnTicketHistory(ticketID string, userID uint) ([]models.TicketHistory, error) {
+	if _, err := s.getOwnedTicket(ticketID, userID); err != nil {
+		return nil, err
+	}
+
+	return s.ticketRepo.GetTicketHistory(ticketID, false)
+}
+
+// getOwnedTicket loads a ticket and makes sure it belongs to the given user.
+// Tickets of other users are reported as not found so their existence isn't leaked.
+func (s *TicketService) getOwnedTicket(ticketID string, userID uint) (*models.Ticket, error) {
+	ticket, err := s.ticketRepo.GetTicket(ticketID)
+	if err != nil {
+		return nil, err
+	}
+
+	if ticket.AuthorID != userID {
+		return nil, errors.New("ticket not found")
+	}
+
+	return ticket, nil
+}
+
+// UpdateTicketStatus moves a ticket to a new status following the ticket workflow
+func (s *TicketService) UpdateTicketStatus(ticketID string, status models.TicketStatus, reason string, actorID uint, actorEmail string, actorRole models.Role) error {
+	ticket, err := s.getAgentTicket(ticketID, actorID, actorRole)
+	if err != nil {
 		return err
 	}
 
-	if err := s.ticketRepo.CountTicket(string(status)); err != nil {
+	if ticket.Status == status {
+		return errors.New("new status is the same as the current status")
+	}
+
+	if !canTransition(ticket.Status, status) {
+		return fmt.Errorf("a ticket can't go from %s to %s", ticket.Status, status)
+	}
+
+	reason = strings.TrimSpace(reason)
+	if reasonRequired[status] && reason == "" {
+		return fmt.Errorf("a reason is required to move a ticket to %s", status)
+	}
+
+	change := &models.TicketStatusChange{
+		TicketID:   ticketID,
+		FromStatus: ticket.Status,
+		ToStatus:   status,
+		Reason:     reason,
+		ActorID:    actorID,
+		ActorEmail: actorEmail,
+		CreatedAt:  time.Now(),
+	}
+
+	// The counters move the ticket to its new status in the same transaction
+	return s.ticketRepo.TransitionTicketStatus(change)
+}
+
+// GetStatusTimeline gets every status change of a ticket in chronological order
+func (s *TicketService) GetStatusTimeline(ticketID string, userID uint, userRole models.Role) ([]models.TicketStatusChange, error) {
+	if _, err := s.getAgentTicket(ticketID, userID, userRole); err != nil {
+		return nil, err
+	}
+
+	return s.ticketRepo.GetStatusChanges(ticketID)
+}
+
+// UpdateTicketPriority changes the priority of a ticket and recomputes its SLA deadlines
+func (s *TicketService) UpdateTicketPriority(ticketID string, priority models.TicketPriority, userID uint, userRole models.Role) error {
+	ticket, err := s.getAgentTicket(ticketID, userID, userRole)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	if ticket.Priority == priority {
+		return errors.New("new priority is the same as the current priority")
+	}
+
+	// Deadlines are always counted from the moment the ticket was opened
+	firstResponseDue, resolutionDue := slaDeadlines(priority, ticket.CreatedAt)
+	if err := s.ticketRepo.UpdateTicketPriority(ticketID, priority, firstResponseDue, resolutionDue); err != nil {
+		return err
+	}
+
+	_, err = s.recordHistory(ticketID, fmt.Sprintf("Ticket priority changed from %s to %s", ticket.Priority, priority))
+	return err
 }
 
-// AddTicketHistory adds a history entry to a ticket
-func (s *TicketService) AddTicketHistory(ticketID, message string) error {
+// AssignTicket assigns a ticket to an admin or master user working its queue, replacing the current assignee
+func (s *TicketService) AssignTicket(ticketID, assigneeEmail string, userID uint, userRole models.Role) error {
+	ticket, err := s.getAgentTicket(ticketID, userID, userRole)
+	if err != nil {
+		return err
+	}
+
+	assignee, err := s.userRepo.FindByEmail(assigneeEmail)
+	if err != nil {
+		return err
+	}
+
+	if assignee.Role != models.AdminRole && assignee.Role != models.MasterRole {
+		return errors.New("tickets can only be assigned to admin or master users")
+	}
+
+	allowed, err := s.canWorkQueue(ticket.QueueID, assignee.ID, assignee.Role)
+	if err != nil {
+		return err
+	}
+
+	if !allowed {
+		return errors.New("assignee is not a member of the ticket queue")
+	}
+
+	if ticket.AssigneeID != nil && *ticket.AssigneeID == assignee.ID {
+		return errors.New("ticket is already assigned to this user")
+	}
+
+	if err := s.ticketRepo.UpdateTicketAssignee(ticketID, &assignee.ID); err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("Ticket assigned to %s", assignee.Username)
+	if ticket.Assignee != nil {
+		message = fmt.Sprintf("Ticket reassigned from %s to %s", ticket.Assignee.Username, assignee.Username)
+	}
+
+	_, err = s.recordHistory(ticketID, message)
+	return err
+}
+
+// UnassignTicket removes the assignee of a ticket
+func (s *TicketService) UnassignTicket(ticketID string, userID uint, userRole models.Role) error {
+	ticket, err := s.getAgentTicket(ticketID, userID, userRole)
+	if err != nil {
+		return err
+	}
+
+	if ticket.Assignee == nil {
+		return errors.New("ticket is not assigned")
+	}
+
+	if err := s.ticketRepo.UpdateTicketAssignee(ticketID, nil); err != nil {
+		return err
+	}
+
+	_, err = s.recordHistory(ticketID, fmt.Sprintf("Ticket unassigned from %s", ticket.Assignee.Username))
+	return err
+}
+
+// GetWorkloads gets the open ticket count of every agent and of the unassigned pool, only the
+// tickets of the queues the user is a member of are counted
+func (s *TicketService) GetWorkloads(userID uint, userRole models.Role) ([]models.AgentWorkload, int64, error) {
+	filter := repository.TicketFilter{}
+	if err := s.restrictToQueues(&filter, userID, userRole); err != nil {
+		return nil, 0, err
+	}
+
+	workloads, err := s.ticketRepo.GetAgentWorkloads(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	unassigned, err := s.ticketRepo.CountUnassignedOpenTickets(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return workloads, unassigned, nil
+}
+
+// AddTicketHistory adds an agent message to a ticket conversation, internal messages are agent-only notes
+func (s *TicketService) AddTicketHistory(ticketID, message string, internal bool, authorID uint, authorRole models.Role) error {
+	if _, err := s.getAgentTicket(ticketID, authorID, authorRole); err != nil {
+		return err
+	}
+
+	visibility := models.PublicMessage
+	if internal {
+		visibility = models.InternalMessage
+	}
+
+	history, err := s.addMessage(ticketID, message, visibility, authorID)
+	if err != nil {
+		return err
+	}
+
+	if internal {
+		return nil
+	}
+
+	// The first public answer on the ticket stops the first response SLA clock
+	return s.ticketRepo.MarkFirstResponse(ticketID, history.CreatedAt)
+}
+
+// ReplyToOwnTicket adds a message of the requester to the conversation of their ticket
+func (s *TicketService) ReplyToOwnTicket(ticketID, message string, userID uint) error {
+	ticket, err := s.getOwnedTicket(ticketID, userID)
+	if err != nil {
+		return err
+	}
+
+	if ticket.Status.IsClosed() {
+		return errors.New("ticket is closed")
+	}
+
+	_, err = s.addMessage(ticketID, message, models.PublicMessage, userID)
+	return err
+}
+
+// EditTicketMessage changes the content of a message, only its author can edit it
+func (s *TicketService) EditTicketMessage(messageID uint, message string, actorID uint, actorEmail string) error {
+	history, err := s.ticketRepo.GetTicketMessage(messageID)
+	if err != nil {
+		return err
+	}
+
+	if history.AuthorID == nil || *history.AuthorID != actorID {
+		return errors.New("only the author can edit this message")
+	}
+
+	if history.Message == message {
+		return errors.New("new message is the same as the current message")
+	}
+
+	revision := &models.TicketHistoryRevision{
+		HistoryID:       history.ID,
+		Action:          models.MessageEdited,
+		PreviousMessage: history.Message,
+		ActorID:         actorID,
+		ActorEmail:      actorEmail,
+		CreatedAt:       time.Now(),
+	}
+
+	return s.ticketRepo.EditTicketMessage(history.ID, message, revision)
+}
+
+// DeleteTicketMessage removes a message from the conversation. Authors can delete their
+// own messages and admins can delete any message of the tickets of their queues.
+func (s *TicketService) DeleteTicketMessage(messageID uint, actorID uint, actorEmail string, actorRole models.Role) error {
+	history, err := s.ticketRepo.GetTicketMessage(messageID)
+	if err != nil {
+		return err
+	}
+
+	isAuthor := history.AuthorID != nil && *history.AuthorID == actorID
+	if !isAuthor {
+		if _, err := s.getAgentTicket(history.TicketID, actorID, actorRole); err != nil {
+			return errors.New("you don't have permission to delete this message")
+		}
+	}
+
+	revision := &models.TicketHistoryRevision{
+		HistoryID:       history.ID,
+		Action:          models.MessageDeleted,
+		PreviousMessage: history.Message,
+		ActorID:         actorID,
+		ActorEmail:      actorEmail,
+		CreatedAt:       time.Now(),
+	}
+
+	return s.ticketRepo.DeleteTicketMessage(history.ID, revision)
+}
+
+// GetMessageAudit gets the edit and delete trail of a message of a ticket the agent works
+func (s *TicketService) GetMessageAudit(messageID uint, userID uint, userRole models.Role) ([]models.TicketHistoryRevision, error) {
+	history, err := s.ticketRepo.GetTicketMessage(messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.getAgentTicket(history.TicketID, userID, userRole); err != nil {
+		return nil, err
+	}
+
+	return s.ticketRepo.GetMessageRevisions(messageID)
+}
+
+// addMessage writes a message of a user to a ticket conversation
+func (s *TicketService) addMessage(ticketID, message string, visibility models.MessageVisibility, authorID uint) (*models.TicketHistory, error) {
+	author, err := s.userRepo.FindByID(authorID)
+	if err != nil {
+		return nil, err
+	}
+
+	history := models.TicketHistory{
+		TicketID:   ticketID,
+		AuthorID:   &author.ID,
+		AuthorName: author.Username,
+		AuthorRole: author.Role,
+		Visibility: visibility,
+		Message:    message,
+		CreatedAt:  time.Now(),
+	}
+
+	if err := s.ticketRepo.AddTicketHistory(&history); err != nil {
+		return nil, err
+	}
+
+	return &history, nil
+}
+
+// recordHistory adds a system entry to the conversation, it doesn't count as an answer to the requester
+func (s *TicketService) recordHistory(ticketID, message string) (*models.TicketHistory, error) {
 	history := models.TicketHistory{
-		TicketID:  ticketID,
-		Message:   message,
-		CreatedAt: time.Now(),
+		TicketID:   ticketID,
+		Visibility: models.PublicMessage,
+		Message:    message,
+		CreatedAt:  time.Now(),
 	}
 
-	return s.ticketRepo.AddTicketHistory(&history)
+	if err := s.ticketRepo.AddTicketHistory(&history); err != nil {
+		return nil, err
+	}
+
+	return &history, nil
 }
 
-// DeleteTicket deletes a ticket
+// DeleteTicket moves a ticket to the trash
 func (s *TicketService) DeleteTicket(ticketID string, userID uint, userRole models.Role) error {
 	// Get the ticket to check ownership
 	ticket, err := s.ticketRepo.GetTicket(ticketID)
@@ -196,15 +670,28 @@ func (s *TicketService) DeleteTicket(ticketID string, userID uint, userRole mode
 		return err
 	}
 
-	// Check if user is admin/master or the owner of the ticket
-	if userRole != models.AdminRole && userRole != models.MasterRole && ticket.AuthorID != userID {
-		return errors.New("you don't have permission to delete this ticket")
+	// Check if user is the owner of the ticket or an admin/master working its queue
+	if ticket.AuthorID != userID {
+		allowed, err := s.canWorkQueue(ticket.QueueID, userID, userRole)
+		if err != nil {
+			return err
+		}
+
+		if !allowed {
+			return errors.New("you don't have permission to delete this ticket")
+		}
 	}
 
-	// Delete the ticket
+	// Move the ticket to the trash, its images stay in the blob store until it is purged
 	return s.ticketRepo.DeleteTicket(ticketID)
 }
 
+// userExists tells whether a user is registered with the email
+func (s *TicketService) userExists(email string) bool {
+	_, err := s.userRepo.FindByEmail(email)
+	return err == nil
+}
+
 func (s *TicketService) GetUserUsername(userID uint) (string, error) {
 	user, err := s.userRepo.FindByID(userID)
 	if err != nil {
@@ -220,3 +707,32 @@ func (s *TicketService) GetCounters() (*models.Counters, error) {
 	}
 	return counters, nil
 }
+
+// GetUserCounters gets the counters of the tickets of each author, or of each agent with the assignee scope.
+// Masters read the kept counters, the other agents only count the tickets of their queues.
+func (s *TicketService) GetUserCounters(scope models.CounterScope, userID uint, userRole models.Role) ([]models.UserCounters, error) {
+	var counters []models.UserCounters
+	var err error
+	if userRole == models.MasterRole {
+		counters, err = s.ticketRepo.GetUserCounters(scope)
+	} else {
+		filter := repository.TicketFilter{}
+		if err := s.restrictToQueues(&filter, userID, userRole); err != nil {
+			return nil, err
+		}
+		counters, err = s.ticketRepo.CountUserTickets(filter, scope)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if counters == nil {
+		counters = []models.UserCounters{}
+	}
+	return counters, nil
+}
+
+// RecomputeCounters rebuilds the counters from the tickets
+func (s *TicketService) RecomputeCounters() error {
+	return s.ticketRepo.RecomputeCounters()
+}
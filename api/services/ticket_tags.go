@@ -0,0 +1,48 @@
+package services
+
+import (
+	"errors"
+
+	"hcall/api/models"
+)
+
+// AddTicketTags labels a ticket of a queue the agent works, the missing tags are created
+func (s *TicketService) AddTicketTags(ticketID string, names []string, userID uint, userRole models.Role) error {
+	tags, err := normalizeTags(names)
+	if err != nil {
+		return err
+	}
+
+	if len(tags) == 0 {
+		return errors.New("at least one tag is required")
+	}
+
+	if _, err := s.getAgentTicket(ticketID, userID, userRole); err != nil {
+		return err
+	}
+
+	records, err := s.tagRepo.GetOrCreateTags(tags)
+	if err != nil {
+		return err
+	}
+
+	return s.tagRepo.AddTicketTags(ticketID, records)
+}
+
+// RemoveTicketTags removes tags from a ticket of a queue the agent works
+func (s *TicketService) RemoveTicketTags(ticketID string, names []string, userID uint, userRole models.Role) error {
+	tags, err := normalizeTags(names)
+	if err != nil {
+		return err
+	}
+
+	if len(tags) == 0 {
+		return errors.New("at least one tag is required")
+	}
+
+	if _, err := s.getAgentTicket(ticketID, userID, userRole); err != nil {
+		return err
+	}
+
+	return s.tagRepo.RemoveTicketTags(ticketID, tags)
+}
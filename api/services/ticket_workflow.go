@@ -0,0 +1,30 @@
+package services
+
+import "hcall/api/models"
+
+// statusTransitions lists, for each ticket status, the statuses it may move to
+var statusTransitions = map[models.TicketStatus][]models.TicketStatus{
+	models.PendingStatus:   {models.DoingStatus, models.OnHoldStatus, models.CancelledStatus},
+	models.DoingStatus:     {models.PendingStatus, models.OnHoldStatus, models.ConcluedStatus, models.CancelledStatus},
+	models.OnHoldStatus:    {models.DoingStatus, models.CancelledStatus},
+	models.ReopenedStatus:  {models.DoingStatus, models.OnHoldStatus, models.CancelledStatus},
+	models.ConcluedStatus:  {models.ReopenedStatus},
+	models.CancelledStatus: {models.ReopenedStatus},
+}
+
+// reasonRequired lists the statuses that can only be entered with a reason
+var reasonRequired = map[models.TicketStatus]bool{
+	models.OnHoldStatus:    true,
+	models.CancelledStatus: true,
+	models.ReopenedStatus:  true,
+}
+
+// canTransition tells whether a ticket may move from one status to another
+func canTransition(from, to models.TicketStatus) bool {
+	for _, allowed := range statusTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
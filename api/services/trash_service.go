@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"hcall/api/config"
+	"hcall/api/models"
+	"hcall/api/repository"
+	"hcall/api/storage"
+	"hcall/api/utils"
+)
+
+// TrashService manages the deleted tickets and users until they are restored or purged
+type TrashService struct {
+	ticketService *TicketService
+	ticketRepo    *repository.TicketRepository
+	userRepo      *repository.UserRepository
+}
+
+func NewTrashService() *TrashService {
+	return &TrashService{
+		ticketService: NewTicketService(),
+		ticketRepo:    repository.NewTicketRepository(),
+		userRepo:      repository.NewUserRepository(),
+	}
+}
+
+// GetTrashedTickets gets a page of the trashed tickets, most recently deleted first.
+// Admins only get the tickets of their queues.
+func (s *TrashService) GetTrashedTickets(query utils.PageQuery, userID uint, userRole models.Role) ([]models.Ticket, *models.Page, error) {
+	var filter repository.TicketFilter
+	if err := s.ticketService.restrictToQueues(&filter, userID, userRole); err != nil {
+		return nil, nil, err
+	}
+
+	page := newSortedPage(query, "deleted")
+	tickets, err := s.ticketRepo.Paginate(page).FindTrashedTickets(filter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tickets, page, nil
+}
+
+// GetTrashedUsers gets a page of the trashed users, most recently deleted first
+func (s *TrashService) GetTrashedUsers(query utils.PageQuery) ([]models.User, *models.Page, error) {
+	page := newSortedPage(query, "deleted")
+	users, err := s.userRepo.Paginate(page).GetTrashedUsers()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return users, page, nil
+}
+
+// GetUserUsername gets the username of a ticket author, trashed authors are reported as not found
+func (s *TrashService) GetUserUsername(userID uint) (string, error) {
+	return s.ticketService.GetUserUsername(userID)
+}
+
+// RestoreTicket brings a ticket of a queue the agent works back from the trash
+func (s *TrashService) RestoreTicket(ticketID string, userID uint, userRole models.Role) error {
+	ticket, err := s.ticketRepo.GetTrashedTicket(ticketID)
+	if err != nil {
+		return err
+	}
+
+	allowed, err := s.ticketService.canWorkQueue(ticket.QueueID, userID, userRole)
+	if err != nil {
+		return err
+	}
+
+	if !allowed {
+		return errors.New("ticket not found in trash")
+	}
+
+	return s.ticketRepo.RestoreTicket(ticketID)
+}
+
+// RestoreUser brings a user back from the trash
+func (s *TrashService) RestoreUser(email string) error {
+	return s.userRepo.RestoreUser(email)
+}
+
+// PurgeTicket permanently deletes a trashed ticket and the content of its images
+func (s *TrashService) PurgeTicket(ticketID string) error {
+	storageKeys, err := s.ticketRepo.PurgeTicket(ticketID)
+	if err != nil {
+		return err
+	}
+
+	storage.DeleteAll(context.Background(), storageKeys)
+	return nil
+}
+
+// PurgeUser permanently deletes a trashed user
+func (s *TrashService) PurgeUser(email string) error {
+	return s.userRepo.PurgeUser(email)
+}
+
+// PurgeExpired permanently deletes what stayed in the trash past the retention, like the trash worker does.
+// It returns how many tickets and users were purged.
+func (s *TrashService) PurgeExpired() (int64, int64, error) {
+	before := time.Now().AddDate(0, 0, -config.AppConfig.TrashRetentionDays)
+
+	storageKeys, tickets, err := s.ticketRepo.PurgeTrashedTickets(before)
+	if err != nil {
+		return 0, 0, err
+	}
+	storage.DeleteAll(context.Background(), storageKeys)
+
+	users, err := s.userRepo.PurgeTrashedUsers(before)
+	if err != nil {
+		return tickets, 0, err
+	}
+
+	return tickets, users, nil
+}
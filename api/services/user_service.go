@@ -2,6 +2,7 @@ package services
 
 import (
 	"errors"
+	"time"
 
 	"hcall/api/models"
 	"hcall/api/repository"
@@ -21,8 +22,13 @@ func NewUserService() *UserService {
 // CreateUser creates a new user
 func (s *UserService) CreateUser(username, email, password string, role models.Role) error {
 	// Check if user already exists
-	existingUser, err := s.userRepo.FindByEmail(email)
-	if err == nil && existingUser != nil {
+	// Trashed users keep their email until they are purged
+	inUse, err := s.userRepo.EmailInUse(email)
+	if err != nil {
+		return err
+	}
+
+	if inUse {
 		return errors.New("email already exists")
 	}
 
@@ -32,26 +38,38 @@ func (s *UserService) CreateUser(username, email, password string, role models.R
 		return err
 	}
 
-	// Create the new user
+	// Create the new user, the email is trusted since an admin gave it
+	now := time.Now()
 	user := &models.User{
-		Username: username,
-		Email:    email,
-		Password: password,
-		Role:     role,
+		Username:        username,
+		Email:           email,
+		Password:        password,
+		Role:            role,
+		EmailVerifiedAt: &now,
 	}
 
 	// Save the user to the database
 	return s.userRepo.CreateUser(user)
 }
 
-// GetUsers gets all users
-func (s *UserService) GetUsers() ([]models.User, error) {
-	return s.userRepo.GetUsers()
+// GetUsers gets a page of all users
+func (s *UserService) GetUsers(query utils.PageQuery) ([]models.User, *models.Page, error) {
+	page := newPage(query)
+	users, err := s.userRepo.Paginate(page).GetUsers()
+	if err != nil {
+		return nil, nil, err
+	}
+	return users, page, nil
 }
 
-// GetUsersByRole gets users by role
-func (s *UserService) GetUsersByRole(role models.Role) ([]models.User, error) {
-	return s.userRepo.GetUsersByRole(role)
+// GetUsersByRole gets a page of the users with the given role
+func (s *UserService) GetUsersByRole(role models.Role, query utils.PageQuery) ([]models.User, *models.Page, error) {
+	page := newPage(query)
+	users, err := s.userRepo.Paginate(page).GetUsersByRole(role)
+	if err != nil {
+		return nil, nil, err
+	}
+	return users, page, nil
 }
 
 // GetUserByEmail gets a user by email
@@ -73,7 +91,7 @@ func (s *UserService) GetUserByEmailAndRole(email string, role models.Role) (*mo
 	return user, nil
 }
 
-// DeleteUser deletes a user by email
+// DeleteUser moves a user to the trash by email, revoking its sessions and API keys
 func (s *UserService) DeleteUser(email string) error {
 	return s.userRepo.DeleteUser(email)
 }
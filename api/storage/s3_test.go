@@ -0,0 +1,284 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	testRegion    = "eu-west-3"
+)
+
+var authorizationPattern = regexp.MustCompile(`^AWS4-HMAC-SHA256 Credential=([^/]+)/(\d{8})/([^/]+)/s3/aws4_request, SignedHeaders=([^,]+), Signature=([0-9a-f]{64})$`)
+
+// fakeS3 is a bucket in memory that checks the Signature Version 4 of every request
+type fakeS3 struct {
+	t       *testing.T
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := f.checkSignature(r); err != nil {
+		f.t.Errorf("%s %s: %v", r.Method, r.URL.Path, err)
+		http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if int64(len(body)) != r.ContentLength {
+			f.t.Errorf("PUT %s: read %d bytes, Content-Length is %d", r.URL.Path, len(body), r.ContentLength)
+		}
+		f.objects[r.URL.Path] = body
+		f.types[r.URL.Path] = r.Header.Get("Content-Type")
+	case http.MethodGet:
+		body, ok := f.objects[r.URL.Path]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", f.types[r.URL.Path])
+		w.Write(body)
+	case http.MethodDelete:
+		if _, ok := f.objects[r.URL.Path]; !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "MethodNotAllowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// checkSignature computes the signature of the request again, the way S3 does
+func (f *fakeS3) checkSignature(r *http.Request) error {
+	match := authorizationPattern.FindStringSubmatch(r.Header.Get("Authorization"))
+	if match == nil {
+		return errors.New("malformed Authorization header: " + r.Header.Get("Authorization"))
+	}
+	accessKey, day, region, signedHeaders, signature := match[1], match[2], match[3], match[4], match[5]
+
+	if accessKey != testAccessKey || region != testRegion {
+		return errors.New("wrong credential scope")
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	date, err := time.Parse("20060102T150405Z", amzDate)
+	if err != nil || date.Format("20060102") != day {
+		return errors.New("X-Amz-Date doesn't match the credential scope")
+	}
+	if time.Since(date) > 15*time.Minute {
+		return errors.New("request is too old")
+	}
+
+	if r.Header.Get("X-Amz-Content-Sha256") != unsignedPayload {
+		return errors.New("X-Amz-Content-Sha256 is not UNSIGNED-PAYLOAD")
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, name := range strings.Split(signedHeaders, ";") {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		r.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		unsignedPayload,
+	}, "\n")
+
+	scope := day + "/" + region + "/s3/aws4_request"
+	hashedRequest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashedRequest[:])
+
+	key := hmacSHA256([]byte("AWS4"+testSecretKey), day)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	if expected := hex.EncodeToString(hmacSHA256(key, stringToSign)); expected != signature {
+		return errors.New("signature doesn't match")
+	}
+	return nil
+}
+
+func newTestS3Store(t *testing.T) (*S3Store, *fakeS3) {
+	t.Helper()
+
+	bucket := &fakeS3{t: t, objects: make(map[string][]byte), types: make(map[string]string)}
+	server := httptest.NewServer(bucket)
+	t.Cleanup(server.Close)
+
+	store, err := NewS3Store(S3Options{
+		Endpoint:  server.URL,
+		Region:    testRegion,
+		Bucket:    "hcall",
+		AccessKey: testAccessKey,
+		SecretKey: testSecretKey,
+		PathStyle: true,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Store: %v", err)
+	}
+	return store, bucket
+}
+
+func TestS3StorePutGetDelete(t *testing.T) {
+	store, bucket := newTestS3Store(t)
+	ctx := context.Background()
+
+	key := "tickets/42/screen shot (1).png"
+	content := "\x89PNG fake image content"
+	if err := store.Put(ctx, key, strings.NewReader(content), int64(len(content)), "image/png"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	stored, ok := bucket.objects["/hcall/"+key]
+	if !ok {
+		t.Fatalf("object not stored under the bucket path, got %v", bucket.objects)
+	}
+	if string(stored) != content {
+		t.Errorf("stored %q, want %q", stored, content)
+	}
+	if contentType := bucket.types["/hcall/"+key]; contentType != "image/png" {
+		t.Errorf("stored content type %q, want image/png", contentType)
+	}
+
+	reader, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	read, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		t.Fatalf("reading the object: %v", err)
+	}
+	if string(read) != content {
+		t.Errorf("Get read %q, want %q", read, content)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok := bucket.objects["/hcall/"+key]; ok {
+		t.Error("object still stored after Delete")
+	}
+
+	if _, err := store.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of a deleted object returned %v, want ErrNotFound", err)
+	}
+
+	// Deleting a missing key is not an error
+	if err := store.Delete(ctx, key); err != nil {
+		t.Errorf("Delete of a missing object: %v", err)
+	}
+}
+
+func TestS3StoreRefusedRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	store, err := NewS3Store(S3Options{
+		Endpoint:  server.URL,
+		Bucket:    "hcall",
+		AccessKey: testAccessKey,
+		SecretKey: testSecretKey,
+		PathStyle: true,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Store: %v", err)
+	}
+
+	err = store.Put(context.Background(), "key", strings.NewReader("x"), 1, "")
+	if err == nil || !strings.Contains(err.Error(), "status 403") || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("Put returned %v, want the 403 answer", err)
+	}
+}
+
+func TestS3StoreSignature(t *testing.T) {
+	store, err := NewS3Store(S3Options{
+		Endpoint:  "https://s3.example.com",
+		Region:    testRegion,
+		Bucket:    "hcall",
+		AccessKey: testAccessKey,
+		SecretKey: testSecretKey,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Store: %v", err)
+	}
+
+	req, err := store.newRequest(context.Background(), http.MethodGet, "tickets/a b+c.png", nil)
+	if err != nil {
+		t.Fatalf("newRequest: %v", err)
+	}
+
+	// Virtual-hosted style puts the bucket in the host name
+	if req.URL.Host != "hcall.s3.example.com" {
+		t.Errorf("host %q, want hcall.s3.example.com", req.URL.Host)
+	}
+	if path := req.URL.EscapedPath(); path != "/tickets/a%20b%2Bc.png" {
+		t.Errorf("escaped path %q, want /tickets/a%%20b%%2Bc.png", path)
+	}
+
+	now := time.Date(2024, 5, 17, 8, 30, 0, 0, time.UTC)
+	store.sign(req, now)
+
+	if date := req.Header.Get("X-Amz-Date"); date != "20240517T083000Z" {
+		t.Errorf("X-Amz-Date %q, want 20240517T083000Z", date)
+	}
+
+	match := authorizationPattern.FindStringSubmatch(req.Header.Get("Authorization"))
+	if match == nil {
+		t.Fatalf("malformed Authorization header %q", req.Header.Get("Authorization"))
+	}
+	if match[2] != "20240517" || match[3] != testRegion {
+		t.Errorf("credential scope %s/%s, want 20240517/%s", match[2], match[3], testRegion)
+	}
+	if match[4] != "host;x-amz-content-sha256;x-amz-date" {
+		t.Errorf("signed headers %q", match[4])
+	}
+
+	// The same request signed at the same time gets the same signature, another secret doesn't
+	again, _ := store.newRequest(context.Background(), http.MethodGet, "tickets/a b+c.png", nil)
+	store.sign(again, now)
+	if again.Header.Get("Authorization") != req.Header.Get("Authorization") {
+		t.Error("signature is not deterministic")
+	}
+
+	store.options.SecretKey = "another secret"
+	other, _ := store.newRequest(context.Background(), http.MethodGet, "tickets/a b+c.png", nil)
+	store.sign(other, now)
+	if other.Header.Get("Authorization") == req.Header.Get("Authorization") {
+		t.Error("signature doesn't depend on the secret key")
+	}
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"hcall/api/config"
+	"hcall/api/logger"
+)
+
+// ErrNotFound is returned when a blob doesn't exist in the store
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore keeps the binary content of attachments outside of the database
+type BlobStore interface {
+	// Put writes size bytes read from body under key, replacing any previous content
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Get opens the content stored under key, the caller must close it
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the content stored under key, missing keys are not an error
+	Delete(ctx context.Context, key string) error
+}
+
+var Store BlobStore
+
+// InitStore initializes the blob store selected by STORAGE_DRIVER
+func InitStore() {
+	store, err := NewStore(config.AppConfig)
+	if err != nil {
+		logger.Fatal("Storage: Failed to initialize blob store", map[string]interface{}{
+			"driver": config.AppConfig.StorageDriver,
+			"error":  err.Error(),
+		})
+	}
+
+	Store = store
+	logger.Info("Storage: Blob store initialized successfully", map[string]interface{}{
+		"driver": config.AppConfig.StorageDriver,
+	})
+}
+
+// NewStore creates the blob store described by the configuration
+func NewStore(cfg config.Config) (BlobStore, error) {
+	switch cfg.StorageDriver {
+	case "local":
+		return NewLocalStore(cfg.StorageLocalPath)
+	case "s3":
+		return NewS3Store(S3Options{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PathStyle: cfg.S3PathStyle,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.StorageDriver)
+	}
+}
+
+// DeleteAll removes the content of every key, failures are only logged since
+// the records pointing to them are already gone
+func DeleteAll(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := Store.Delete(ctx, key); err != nil {
+			logger.Error("Storage: Failed to delete blob", map[string]interface{}{
+				"key":   key,
+				"error": err.Error(),
+			})
+		}
+	}
+}
@@ -36,32 +36,284 @@ type DeleteUserRequest struct {
 	Email string `json:"user_email" binding:"required,email"`
 }
 
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type RevokeUserSessionsRequest struct {
+	Email string `json:"user_email" binding:"required,email"`
+}
+
+// EmailRequest asks for a verification or password reset email
+type EmailRequest struct {
+	Email string `json:"user_email" binding:"required,email"`
+}
+
+// EmailTokenRequest holds the token of the link of a verification email
+type EmailTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// MFAEnrollRequest sets up a second factor during a login that requires one
+type MFAEnrollRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+// MFAVerifyRequest answers a login challenge with a TOTP code or a recovery code
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"mfa_code" binding:"required"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"mfa_code" binding:"required"`
+}
+
+type MFADisableRequest struct {
+	Password string `json:"user_password" binding:"required"`
+	Code     string `json:"mfa_code" binding:"required"`
+}
+
+type ResetUserMFARequest struct {
+	Email string `json:"user_email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"user_password" binding:"required"`
+}
+
+// OIDCCallbackRequest finishes a single sign-on login with what the identity provider redirected
+// the user back with, and the token given when the login started
+type OIDCCallbackRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+	Token string `json:"oidc_token" binding:"required"`
+}
+
+type CreateServiceAccountRequest struct {
+	Name string      `json:"user_name" binding:"required"`
+	Role models.Role `json:"user_role" binding:"required,oneof=user admin"`
+}
+
+// CreateAPIKeyRequest creates a key for a service account, the key never expires when no expiry is given
+type CreateAPIKeyRequest struct {
+	Email         string   `json:"user_email" binding:"required,email"`
+	Name          string   `json:"api_key_name" binding:"required"`
+	Scopes        []string `json:"api_key_scopes" binding:"required,min=1"`
+	ExpiresInDays int      `json:"api_key_expires_in_days" binding:"omitempty,min=1,max=3650"`
+}
+
+type APIKeysQuery struct {
+	Email string `form:"user_email" binding:"required,email"`
+}
+
+type RevokeAPIKeyRequest struct {
+	Email string `json:"user_email" binding:"required,email"`
+	KeyID uint   `json:"api_key_id" binding:"required"`
+}
+
+// CreateTicketRequest is bound from a JSON body or from the fields of a multipart form,
+// in which case the images are sent as "ticket_images" files
 type CreateTicketRequest struct {
-	Name        string     `json:"ticket_name" binding:"required"`
-	Explanation string     `json:"ticket_explain" binding:"required"`
-	Images      []ImageDTO `json:"ticket_images" binding:"omitempty,dive"`
+	Name        string                `json:"ticket_name" form:"ticket_name" binding:"required"`
+	Explanation string                `json:"ticket_explain" form:"ticket_explain" binding:"required,max=20000"`
+	Priority    models.TicketPriority `json:"ticket_priority" form:"ticket_priority" binding:"omitempty,oneof=low normal high urgent"`
+	QueueID     *uint                 `json:"ticket_queue_id" form:"ticket_queue_id"`
+	// CustomFields is sent as a JSON object, also in a multipart form
+	CustomFields map[string]interface{} `json:"ticket_custom_fields" form:"ticket_custom_fields"`
+	Images       []ImageDTO             `json:"ticket_images" form:"-" binding:"omitempty,dive"`
+}
+
+// UploadTicketImagesRequest holds the fields of the multipart form adding images to a ticket
+type UploadTicketImagesRequest struct {
+	TicketID string `form:"ticket_id" binding:"required"`
+}
+
+// FetchTicketsQuery holds the filters of the ticket listing. The list filters accept repeated
+// parameters or comma separated values, the exclude_ ones remove the matching tickets.
+type FetchTicketsQuery struct {
+	Author          []string `form:"author"`
+	ExcludeAuthor   []string `form:"exclude_author"`
+	Status          []string `form:"status"`
+	ExcludeStatus   []string `form:"exclude_status"`
+	Priority        []string `form:"priority"`
+	ExcludePriority []string `form:"exclude_priority"`
+	SLA             []string `form:"sla"`
+	Queue           []string `form:"queue"`
+	ExcludeQueue    []string `form:"exclude_queue"`
+	TagsAny         []string `form:"tags_any"`
+	TagsAll         []string `form:"tags_all"`
+	// Fields is a JSON object of custom field values, e.g. fields={"asset_tag":"A-1234"}
+	Fields    map[string]interface{} `form:"fields"`
+	Date      string                 `form:"date"`
+	DateFrom  string                 `form:"date_from"`
+	DateTo    string                 `form:"date_to"`
+	Name      string                 `form:"name"`
+	Assigned  string                 `form:"assigned" binding:"omitempty,oneof=me none"`
+	DueBefore string                 `form:"due_before"`
+	PageQuery
+}
+
+// SearchTicketsQuery holds the text of a full-text search, combined with the ticket listing filters
+type SearchTicketsQuery struct {
+	Query string `form:"q" binding:"required"`
+	FetchTicketsQuery
+}
+
+// ExportTicketsQuery holds the format and columns of a ticket export, along with the ticket listing
+// filters. The columns accept repeated parameters or comma separated values.
+type ExportTicketsQuery struct {
+	Format  string   `form:"format" binding:"omitempty,oneof=csv ndjson"`
+	Columns []string `form:"columns"`
+	FetchTicketsQuery
+}
+
+// FetchMyTicketsQuery holds the filters of the listing of the user's own tickets
+type FetchMyTicketsQuery struct {
+	Status []string `form:"status"`
+	PageQuery
+}
+
+// TicketMetricsQuery holds the range of the helpdesk metrics, as inclusive YYYY-MM-DD days, and
+// the field they are grouped by
+type TicketMetricsQuery struct {
+	DateFrom string `form:"date_from"`
+	DateTo   string `form:"date_to"`
+	GroupBy  string `form:"group_by" binding:"omitempty,oneof=status agent category"`
+}
+
+// PageQuery holds the pagination parameters of the listings. A cursor comes from the
+// next_cursor of a previous page and takes precedence over the offset.
+type PageQuery struct {
+	Limit  int    `form:"limit" binding:"omitempty,min=1"`
+	Offset int    `form:"offset" binding:"omitempty,min=0"`
+	Cursor string `form:"cursor"`
+	Sort   string `form:"sort" binding:"omitempty,oneof=created updated status priority due_date relevance deleted archived"`
+	Order  string `form:"order" binding:"omitempty,oneof=asc desc"`
 }
 
 type ImageDTO struct {
 	Name    string `json:"image_name" binding:"required"`
-	Content string `json:"image_content" binding:"required"` // Base64 encoded image data (will be stored directly in database)
-	Type    string `json:"image_type" binding:"required,oneof=image/jpeg image/png image/gif"`
+	Content string `json:"image_content" binding:"required"`                                    // Base64 encoded image data (decoded and saved in the blob store)
+	Type    string `json:"image_type" binding:"omitempty,oneof=image/jpeg image/png image/gif"` // Informative, the real type is detected from the content
 }
 
 type UpdateTicketStatusRequest struct {
 	TicketID string              `json:"ticket_id" binding:"required"`
-	Status   models.TicketStatus `json:"ticket_status" binding:"required,oneof=pending doing conclued"`
+	Status   models.TicketStatus `json:"ticket_status" binding:"required,oneof=pending doing on_hold conclued cancelled reopened"`
+	Reason   string              `json:"status_reason"`
+}
+
+// EditTicketContentRequest changes the name and/or the explanation of a ticket, a field left out is kept
+type EditTicketContentRequest struct {
+	TicketID    string  `json:"ticket_id" binding:"required"`
+	Name        *string `json:"ticket_name" binding:"omitempty,max=255"`
+	Explanation *string `json:"ticket_explain" binding:"omitempty,max=20000"`
 }
 
 type UpdateTicketHistoryRequest struct {
 	TicketID string `json:"ticket_id" binding:"required"`
 	Message  string `json:"ticket_return" binding:"required"`
+	Internal bool   `json:"message_internal"`
+}
+
+type ReplyTicketRequest struct {
+	TicketID string `json:"ticket_id" binding:"required"`
+	Message  string `json:"ticket_return" binding:"required"`
+}
+
+type EditTicketMessageRequest struct {
+	MessageID uint   `json:"message_id" binding:"required"`
+	Message   string `json:"ticket_return" binding:"required"`
+}
+
+type DeleteTicketMessageRequest struct {
+	MessageID uint `json:"message_id" binding:"required"`
+}
+
+type UpdateTicketPriorityRequest struct {
+	TicketID string                `json:"ticket_id" binding:"required"`
+	Priority models.TicketPriority `json:"ticket_priority" binding:"required,oneof=low normal high urgent"`
+}
+
+type AssignTicketRequest struct {
+	TicketID      string `json:"ticket_id" binding:"required"`
+	AssigneeEmail string `json:"assignee_email" binding:"required,email"`
+}
+
+type UnassignTicketRequest struct {
+	TicketID string `json:"ticket_id" binding:"required"`
 }
 
 type RemoveTicketRequest struct {
 	TicketID string `json:"ticket_id" binding:"required"`
 }
 
+type TicketTagsRequest struct {
+	TicketID string   `json:"ticket_id" binding:"required"`
+	Tags     []string `json:"ticket_tags" binding:"required,min=1"`
+}
+
+type RenameTagRequest struct {
+	Name    string `json:"tag_name" binding:"required"`
+	NewName string `json:"tag_new_name" binding:"required"`
+}
+
+type MergeTagsRequest struct {
+	Source string `json:"source_tag" binding:"required"`
+	Target string `json:"target_tag" binding:"required"`
+}
+
+type CreateQueueRequest struct {
+	Name        string `json:"queue_name" binding:"required,max=100"`
+	Description string `json:"queue_description"`
+	IsDefault   bool   `json:"queue_default"`
+}
+
+type DeleteQueueRequest struct {
+	QueueID uint `json:"queue_id" binding:"required"`
+}
+
+type UpdateQueueFieldsRequest struct {
+	QueueID     uint                `json:"queue_id" binding:"required"`
+	FieldSchema *models.FieldSchema `json:"queue_field_schema"`
+}
+
+type QueueMemberRequest struct {
+	QueueID uint   `json:"queue_id" binding:"required"`
+	Email   string `json:"user_email" binding:"required,email"`
+}
+
+// SearchArchiveQuery holds the filters of the archive search, q matches part of the ticket name or ID
+type SearchArchiveQuery struct {
+	Query        string   `form:"q"`
+	Author       string   `form:"author"`
+	Status       []string `form:"status"`
+	Queue        string   `form:"queue"`
+	Tag          string   `form:"tag"`
+	ArchivedFrom string   `form:"archived_from"`
+	ArchivedTo   string   `form:"archived_to"`
+	PageQuery
+}
+
+type ImportArchivedTicketRequest struct {
+	TicketID string `json:"ticket_id" binding:"required"`
+}
+
+// ImportTicketsRequest holds the options of a ticket import, sent along with the file in a multipart form
+type ImportTicketsRequest struct {
+	Format       string `form:"format" binding:"omitempty,oneof=csv ndjson"`
+	Placeholders bool   `form:"placeholders"`
+	DryRun       bool   `form:"dry_run"`
+}
+
+// TrashItemRequest points to a trashed ticket or user, only one of them is given
+type TrashItemRequest struct {
+	TicketID string `json:"ticket_id"`
+	Email    string `json:"user_email" binding:"omitempty,email"`
+}
+
 // Response DTOs
 
 type AuthResponse struct {
@@ -101,9 +353,12 @@ type TicketResponse struct {
 }
 
 type GetCountersResponse struct {
-	Total    int  `json:"tickets_total"`
-	Pending  int  `json:"tickets_pending"`
-	Doing    int  `json:"tickets_doing"`
-	Conclued int  `json:"tickets_conclued"`
-	Status   bool `json:"status"`
+	Total     int  `json:"tickets_total"`
+	Pending   int  `json:"tickets_pending"`
+	Doing     int  `json:"tickets_doing"`
+	OnHold    int  `json:"tickets_on_hold"`
+	Conclued  int  `json:"tickets_conclued"`
+	Cancelled int  `json:"tickets_cancelled"`
+	Reopened  int  `json:"tickets_reopened"`
+	Status    bool `json:"status"`
 }
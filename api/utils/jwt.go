@@ -9,6 +9,7 @@ import (
 	"hcall/api/models"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 // Custom claims structure
@@ -16,24 +17,30 @@ type JWTClaims struct {
 	ID    uint        `json:"id"`
 	Email string      `json:"email"`
 	Role  models.Role `json:"role"`
+	// SessionID is the session the token was given to, the token id is in the registered claims
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken generates a JWT token for a user
-func GenerateToken(user *models.User) (string, error) {
+// GenerateToken generates a short-lived JWT access token for a session of a user, along with
+// its id and expiration so the token can be revoked
+func GenerateToken(user *models.User, sessionID string) (string, string, time.Time, error) {
 	logger.Info("Jwt Middleware: Generating token for user", map[string]interface{}{
 		"email": user.Email,
 		"id":    user.ID,
 	})
 
 	// Calculate expiration time
-	expirationTime := time.Now().Add(time.Hour * time.Duration(config.AppConfig.JWTExpirationHours))
+	expirationTime := time.Now().Add(time.Minute * time.Duration(config.AppConfig.JWTAccessMinutes))
+	jti := uuid.NewString()
 
 	claims := &JWTClaims{
-		ID:    user.ID,
-		Email: user.Email,
-		Role:  user.Role,
+		ID:        user.ID,
+		Email:     user.Email,
+		Role:      user.Role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 		},
@@ -46,13 +53,13 @@ func GenerateToken(user *models.User) (string, error) {
 		logger.Error("Jwt middleware: Error generating token", map[string]interface{}{
 			"error": err.Error(),
 		})
-		return "", err
+		return "", "", time.Time{}, err
 	}
 
 	logger.Info("Jwt middleware: Token generated successfully", map[string]interface{}{
 		"email": user.Email,
 	})
-	return tokenString, nil
+	return tokenString, jti, expirationTime, nil
 }
 
 // ValidateToken validates a JWT token
@@ -61,7 +68,7 @@ func ValidateToken(tokenString string) (*JWTClaims, error) {
 
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 		return []byte(config.AppConfig.JWTSecret), nil
-	})
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 
 	if err != nil {
 		logger.Error("Jwt middleware: Token validation error", map[string]interface{}{
@@ -77,6 +84,14 @@ func ValidateToken(tokenString string) (*JWTClaims, error) {
 		return nil, errors.New("invalid token")
 	}
 
+	// Tokens without a session can't be revoked, they were given before sessions existed
+	if claims.RegisteredClaims.ID == "" || claims.SessionID == "" {
+		logger.Error("Jwt middleware: Token has no session", map[string]interface{}{
+			"error": "invalid token",
+		})
+		return nil, errors.New("invalid token")
+	}
+
 	logger.Info("Jwt middleware: Token validated successfully", map[string]interface{}{
 		"email": claims.Email,
 		"id":    claims.ID,
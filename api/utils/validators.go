@@ -16,6 +16,11 @@ func ValidateCredentials(email, password, username string) error {
 		return fmt.Errorf(dictionaries.UsernameTooShort, config.AppConfig.UsernameMinChar)
 	}
 
+	return ValidatePassword(password)
+}
+
+// ValidatePassword checks the password against the password policy
+func ValidatePassword(password string) error {
 	if len(password) < config.AppConfig.PasswordMinChar {
 		return fmt.Errorf(dictionaries.PasswordTooShort, config.AppConfig.PasswordMinChar)
 	}
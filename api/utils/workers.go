@@ -10,16 +10,21 @@ var (
 	once    sync.Once
 )
 
+// Worker is a background job that runs until it is stopped
+type Worker interface {
+	Stop()
+}
+
 type WorkerManager struct {
 	wg      sync.WaitGroup
-	workers map[string]*workers.TicketService
+	workers map[string]Worker
 	mu      sync.Mutex
 }
 
 func GetWorkerManager() *WorkerManager {
 	once.Do(func() {
 		manager = &WorkerManager{
-			workers: make(map[string]*workers.TicketService),
+			workers: make(map[string]Worker),
 		}
 	})
 	return manager
@@ -37,6 +42,33 @@ func (wm *WorkerManager) StartAllWorkers() {
 		defer wm.wg.Done()
 		ticketService.StartTicketWorker()
 	}()
+
+	// Start SLA worker
+	slaService := workers.NewSLAService()
+	wm.workers["sla"] = slaService
+	wm.wg.Add(1)
+	go func() {
+		defer wm.wg.Done()
+		slaService.StartSLAWorker()
+	}()
+
+	// Start trash worker
+	trashService := workers.NewTrashService()
+	wm.workers["trash"] = trashService
+	wm.wg.Add(1)
+	go func() {
+		defer wm.wg.Done()
+		trashService.StartTrashWorker()
+	}()
+
+	// Start session worker
+	sessionService := workers.NewSessionService()
+	wm.workers["session"] = sessionService
+	wm.wg.Add(1)
+	go func() {
+		defer wm.wg.Done()
+		sessionService.StartSessionWorker()
+	}()
 }
 
 func (wm *WorkerManager) StopAllWorkers() {
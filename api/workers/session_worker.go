@@ -0,0 +1,113 @@
+package workers
+
+import (
+	"time"
+
+	"hcall/api/config"
+	"hcall/api/logger"
+	"hcall/api/repository"
+)
+
+type SessionService struct {
+	sessionRepo *repository.SessionRepository
+	tokenRepo   *repository.EmailTokenRepository
+	mfaRepo     *repository.MFARepository
+	oidcRepo    *repository.OIDCRepository
+	stopChan    chan bool
+}
+
+func NewSessionService() *SessionService {
+	return &SessionService{
+		sessionRepo: repository.NewSessionRepository(),
+		tokenRepo:   repository.NewEmailTokenRepository(),
+		mfaRepo:     repository.NewMFARepository(),
+		oidcRepo:    repository.NewOIDCRepository(),
+		stopChan:    make(chan bool),
+	}
+}
+
+// StartSessionWorker periodically deletes the sessions, revoked tokens, email tokens, login challenges and
+// single sign-on logins that expired
+func (s *SessionService) StartSessionWorker() {
+	ticker := time.NewTicker(time.Duration(config.AppConfig.WorkerSessionLooptime) * time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.PurgeExpired()
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// PurgeExpired deletes what can't be used anymore, an expired token is refused anyway
+func (s *SessionService) PurgeExpired() {
+	now := time.Now()
+	sessions, tokens, err := s.sessionRepo.PurgeExpired(now)
+	if err != nil {
+		logger.Error("Session Worker: Failed to purge expired sessions", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if sessions > 0 || tokens > 0 {
+		logger.Info("Session Worker: Expired sessions purged", map[string]interface{}{
+			"sessions": sessions,
+			"tokens":   tokens,
+		})
+	}
+
+	// The email requests are kept as long as they count in the rate limit
+	window := time.Duration(config.AppConfig.EmailRateWindowMinutes) * time.Minute
+	emailTokens, requests, err := s.tokenRepo.PurgeExpired(now, window)
+	if err != nil {
+		logger.Error("Session Worker: Failed to purge expired email tokens", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if emailTokens > 0 || requests > 0 {
+		logger.Info("Session Worker: Expired email tokens purged", map[string]interface{}{
+			"tokens":   emailTokens,
+			"requests": requests,
+		})
+	}
+
+	// Challenges are kept past their expiration as their wrong codes count in the login limit
+	challenges, err := s.mfaRepo.PurgeExpiredChallenges(now.Add(-time.Hour))
+	if err != nil {
+		logger.Error("Session Worker: Failed to purge expired login challenges", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if challenges > 0 {
+		logger.Info("Session Worker: Expired login challenges purged", map[string]interface{}{
+			"challenges": challenges,
+		})
+	}
+
+	states, err := s.oidcRepo.PurgeExpiredStates(now)
+	if err != nil {
+		logger.Error("Session Worker: Failed to purge expired single sign-on logins", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if states > 0 {
+		logger.Info("Session Worker: Expired single sign-on logins purged", map[string]interface{}{
+			"logins": states,
+		})
+	}
+}
+
+// Stop the scheduler when needed (e.g., during application shutdown)
+func (s *SessionService) Stop() {
+	s.stopChan <- true
+}
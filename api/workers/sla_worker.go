@@ -0,0 +1,65 @@
+package workers
+
+import (
+	"time"
+
+	"hcall/api/config"
+	"hcall/api/logger"
+	"hcall/api/repository"
+)
+
+type SLAService struct {
+	ticketRepo *repository.TicketRepository
+	stopChan   chan bool
+}
+
+func NewSLAService() *SLAService {
+	return &SLAService{
+		ticketRepo: repository.NewTicketRepository(),
+		stopChan:   make(chan bool),
+	}
+}
+
+// StartSLAWorker periodically flags the tickets that breached or are close to breaching their SLA
+func (s *SLAService) StartSLAWorker() {
+	looptime := config.AppConfig.WorkerSLALooptime
+	if looptime <= 0 {
+		looptime = 5
+	}
+	warning := time.Duration(config.AppConfig.SLAWarningMinutes) * time.Minute
+
+	ticker := time.NewTicker(time.Duration(looptime) * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.CheckDeadlines(warning)
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// CheckDeadlines refreshes the SLA state of the open tickets
+func (s *SLAService) CheckDeadlines(warning time.Duration) {
+	breached, atRisk, err := s.ticketRepo.RefreshSLAStates(time.Now(), warning)
+	if err != nil {
+		logger.Error("SLA Worker: Failed to check ticket deadlines", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if breached > 0 || atRisk > 0 {
+		logger.Warning("SLA Worker: Tickets flagged", map[string]interface{}{
+			"breached": breached,
+			"at_risk":  atRisk,
+		})
+	}
+}
+
+// Stop the scheduler when needed (e.g., during application shutdown)
+func (s *SLAService) Stop() {
+	s.stopChan <- true
+}
@@ -1,6 +1,7 @@
 package workers
 
 import (
+	"hcall/api/archive"
 	"hcall/api/config"
 	"hcall/api/models"
 	"hcall/api/repository"
@@ -12,6 +13,7 @@ import (
 type TicketService struct {
 	ticketRepo *repository.TicketRepository
 	userRepo   *repository.UserRepository
+	archiver   *archive.Archiver
 	stopChan   chan bool
 }
 
@@ -19,6 +21,7 @@ func NewTicketService() *TicketService {
 	service := &TicketService{
 		ticketRepo: repository.NewTicketRepository(),
 		userRepo:   repository.NewUserRepository(),
+		archiver:   archive.NewArchiver(),
 		stopChan:   make(chan bool),
 	}
 	// Remove the automatic start
@@ -41,7 +44,7 @@ func (s *TicketService) StartTicketWorker() {
 			if err := s.RemoveTicketsWithStatus(Status, RemoveAfter); err != nil {
 				log.Printf("Error removing tickets: %v", err)
 			} else {
-				log.Println("Successfully removed concluded tickets")
+				log.Println("Successfully archived concluded tickets")
 			}
 		case <-s.stopChan:
 			return
@@ -50,11 +53,15 @@ func (s *TicketService) StartTicketWorker() {
 }
 
 func (s *TicketService) RemoveTicketsWithStatus(status string, remove_after int) error {
-	err := s.ticketRepo.RemoveTicketsWithStatus(models.TicketStatus(status), remove_after)
-	if err != nil {
-		return err
+	// Tickets created before the start of the day remove_after days ago are exported to the archive, then deleted
+	now := time.Now()
+	before := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, -remove_after)
+
+	archived, err := s.archiver.ArchiveTickets(models.TicketStatus(status), before)
+	if archived > 0 {
+		log.Printf("Archived %d tickets", archived)
 	}
-	return nil
+	return err
 }
 
 // Stop the scheduler when needed (e.g., during application shutdown)
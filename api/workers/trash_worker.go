@@ -0,0 +1,76 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"hcall/api/config"
+	"hcall/api/logger"
+	"hcall/api/repository"
+	"hcall/api/storage"
+)
+
+type TrashService struct {
+	ticketRepo *repository.TicketRepository
+	userRepo   *repository.UserRepository
+	stopChan   chan bool
+}
+
+func NewTrashService() *TrashService {
+	return &TrashService{
+		ticketRepo: repository.NewTicketRepository(),
+		userRepo:   repository.NewUserRepository(),
+		stopChan:   make(chan bool),
+	}
+}
+
+// StartTrashWorker periodically purges the tickets and users that stayed in the trash past the retention
+func (s *TrashService) StartTrashWorker() {
+	ticker := time.NewTicker(time.Duration(config.AppConfig.WorkerTrashLooptime) * time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.PurgeExpired()
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// PurgeExpired permanently deletes what was trashed before the retention period
+func (s *TrashService) PurgeExpired() {
+	before := time.Now().AddDate(0, 0, -config.AppConfig.TrashRetentionDays)
+
+	storageKeys, tickets, err := s.ticketRepo.PurgeTrashedTickets(before)
+	if err != nil {
+		logger.Error("Trash Worker: Failed to purge tickets", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// Images content lives in the blob store, drop it together with the tickets
+	storage.DeleteAll(context.Background(), storageKeys)
+
+	users, err := s.userRepo.PurgeTrashedUsers(before)
+	if err != nil {
+		logger.Error("Trash Worker: Failed to purge users", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if tickets > 0 || users > 0 {
+		logger.Info("Trash Worker: Trash purged", map[string]interface{}{
+			"tickets": tickets,
+			"users":   users,
+		})
+	}
+}
+
+// Stop the scheduler when needed (e.g., during application shutdown)
+func (s *TrashService) Stop() {
+	s.stopChan <- true
+}